@@ -70,3 +70,17 @@ if [ $? -ne 0 ]; then
     echo "リストアとマイグレーションに失敗しました。"
     exit 1
 fi
+
+# DBを再作成したため、バックエンドに埋め込まれたマイグレーションを再適用する
+echo "アプリケーションのマイグレーションを適用します..."
+# サーバーのバイナリの場所はイメージ(Dockerfile / Dockerfile.dev)ごとに違うため、コンテナのENTRYPOINTから求める
+server_bin=$(docker inspect -f '{{index .Config.Entrypoint 0}}' tuning-backend)
+if [ -z "$server_bin" ]; then
+    echo "バックエンドのコンテナからサーバーのバイナリが見つかりません。"
+    exit 1
+fi
+docker exec tuning-backend "$server_bin" -migrate
+if [ $? -ne 0 ]; then
+    echo "アプリケーションのマイグレーションに失敗しました。"
+    exit 1
+fi
@@ -0,0 +1,274 @@
+// 運用作業用のコマンド
+// 生のSQLを実行する代わりに、サーバーと同じリポジトリ・サービスを通して操作する
+//
+//	go run ./cmd/admin <command> [flags]
+//
+// 接続先などの設定はサーバーと同じ環境変数から読み込む
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/event"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/outbox"
+	"backend/internal/repository"
+	"backend/internal/server"
+	"backend/internal/service"
+)
+
+type command struct {
+	name    string
+	summary string
+	run     func(ctx context.Context, env *env, args []string) error
+}
+
+var commands = []command{
+	{"requeue-orders", "return orders stuck in 'delivering' to 'shipping'", requeueOrders},
+	{"invalidate-caches", "clear shared (redis) caches", invalidateCaches},
+	{"recompute-counts", "recount products and orders and refresh the product count cache", recomputeCounts},
+	{"rotate-robot-key", "generate a new robot API key and print the settings for the rotation", rotateRobotKey},
+}
+
+func main() {
+	os.Exit(run())
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: admin <command> [flags]")
+	fmt.Fprintln(os.Stderr, "\ncommands:")
+	for _, c := range commands {
+		fmt.Fprintf(os.Stderr, "  %-18s %s\n", c.name, c.summary)
+	}
+}
+
+func run() int {
+	if len(os.Args) < 2 {
+		usage()
+		return 2
+	}
+	var cmd *command
+	for i := range commands {
+		if commands[i].name == os.Args[1] {
+			cmd = &commands[i]
+		}
+	}
+	if cmd == nil {
+		usage()
+		return 2
+	}
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		return 1
+	}
+	logging.Init(cfg.LogLevel)
+
+	e := &env{cfg: cfg}
+	defer e.close()
+	if err := cmd.run(context.Background(), e, os.Args[2:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 2
+		}
+		slog.Error("Command failed", "command", cmd.name, "error", err)
+		return 1
+	}
+	return 0
+}
+
+// コマンドが必要とするものだけを遅延して初期化する
+type env struct {
+	cfg      *config.Config
+	store    *repository.Store
+	caches   *cache.Factory
+	closers  []func() error
+	adminSvc *service.AdminService
+}
+
+func (e *env) storeOrInit() (*repository.Store, error) {
+	if e.store != nil {
+		return e.store, nil
+	}
+	dbConn, err := db.InitDBConnection(e.cfg.DB)
+	if err != nil {
+		return nil, err
+	}
+	e.closers = append(e.closers, dbConn.Close)
+	caches := e.cacheFactory()
+	e.store = repository.NewStore(dbConn,
+		repository.WithDBTXWrappers(repository.WithQueryTimeout(e.cfg.DB.QueryTimeout)),
+		repository.WithProductCountCache(cache.New[cache.Entry[int]](caches, server.CacheProductCount), e.cfg.Cache.ProductCountTTL, e.cfg.Cache.ProductCountStale),
+	)
+	return e.store, nil
+}
+
+func (e *env) cacheFactory() *cache.Factory {
+	if e.caches == nil {
+		var closeCache func() error
+		e.caches, closeCache = server.NewCacheFactory(e.cfg.Cache)
+		e.closers = append(e.closers, closeCache)
+	}
+	return e.caches
+}
+
+func (e *env) admin() (*service.AdminService, error) {
+	if e.adminSvc != nil {
+		return e.adminSvc, nil
+	}
+	store, err := e.storeOrInit()
+	if err != nil {
+		return nil, err
+	}
+	// 変更はサーバーと同じくoutbox経由でWebhookに配信する
+	events := event.NewBus()
+	outbox.Subscribe(events)
+	e.adminSvc = service.NewAdminService(store, events)
+	return e.adminSvc, nil
+}
+
+func (e *env) close() {
+	for i := len(e.closers) - 1; i >= 0; i-- {
+		_ = e.closers[i]()
+	}
+}
+
+func requeueOrders(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("requeue-orders", flag.ContinueOnError)
+	ids := fs.String("ids", "", "comma separated order IDs to requeue")
+	olderThan := fs.Duration("older-than", 0, "requeue delivering orders created before this long ago")
+	limit := fs.Int("limit", 1000, "maximum number of orders found by -older-than")
+	dryRun := fs.Bool("dry-run", false, "only print the orders that would be requeued")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if (*ids == "") == (*olderThan == 0) {
+		return errors.New("specify exactly one of -ids or -older-than")
+	}
+
+	svc, err := e.admin()
+	if err != nil {
+		return err
+	}
+
+	var orderIDs []model.OrderID
+	if *ids != "" {
+		for _, s := range strings.Split(*ids, ",") {
+			id, err := model.ParseOrderID(strings.TrimSpace(s))
+			if err != nil {
+				return fmt.Errorf("invalid order ID %q", s)
+			}
+			orderIDs = append(orderIDs, id)
+		}
+	} else {
+		orderIDs, err = svc.FindStuckOrders(ctx, *olderThan, *limit)
+		if err != nil {
+			return err
+		}
+	}
+
+	if *dryRun {
+		fmt.Printf("%d order(s) would be requeued: %v\n", len(orderIDs), orderIDs)
+		return nil
+	}
+	requeued, err := svc.RequeueOrders(ctx, orderIDs)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("requeued %d of %d order(s): %v\n", len(requeued), len(orderIDs), requeued)
+	return nil
+}
+
+func invalidateCaches(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("invalidate-caches", flag.ContinueOnError)
+	name := fs.String("name", "", "cache to clear ("+strings.Join(server.CacheNames, ", ")+"); all if empty")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if cache.Backend(e.cfg.Cache.Backend) != cache.BackendRedis {
+		return errors.New("memory caches live in each server process; restart the servers or use POST /api/admin/catalog/invalidate")
+	}
+
+	names := server.CacheNames
+	if *name != "" {
+		names = []string{*name}
+	}
+	caches := e.cacheFactory()
+	for _, n := range names {
+		// 値の型はクリアに関係しないため any で開く
+		if err := cache.New[any](caches, n).Clear(ctx); err != nil {
+			return fmt.Errorf("%s: %w", n, err)
+		}
+		fmt.Printf("cleared %s\n", n)
+	}
+	return nil
+}
+
+func recomputeCounts(ctx context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("recompute-counts", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	svc, err := e.admin()
+	if err != nil {
+		return err
+	}
+
+	products, err := svc.RecomputeProductCount(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("products: %d\n", products)
+	if cache.Backend(e.cfg.Cache.Backend) != cache.BackendRedis {
+		fmt.Println("note: the cache backend is memory, so running servers keep their own counts until the TTL expires")
+	}
+
+	orders, err := svc.CountOrdersByStatus(ctx)
+	if err != nil {
+		return err
+	}
+	statuses := make([]string, 0, len(orders))
+	for status := range orders {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	for _, status := range statuses {
+		fmt.Printf("orders[%s]: %d\n", status, orders[status])
+	}
+	return nil
+}
+
+// キーはサーバーの設定(環境変数)で管理しているため、ここでは新しいキーの発行と手順の表示だけを行う
+func rotateRobotKey(_ context.Context, e *env, args []string) error {
+	fs := flag.NewFlagSet("rotate-robot-key", flag.ContinueOnError)
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return err
+	}
+	newKey := hex.EncodeToString(buf)
+
+	fmt.Println("1. Deploy the servers with both keys accepted:")
+	fmt.Printf("     ROBOT_API_KEY=%s\n", newKey)
+	fmt.Printf("     ROBOT_API_KEY_PREVIOUS=%s\n", e.cfg.Auth.RobotAPIKey)
+	fmt.Println("2. Switch the robots to the new key.")
+	fmt.Println("3. Unset ROBOT_API_KEY_PREVIOUS and deploy again.")
+	fmt.Printf("(generated at %s)\n", time.Now().UTC().Format(time.RFC3339))
+	return nil
+}
@@ -1,27 +1,57 @@
 package main
 
 import (
+	"backend/internal/config"
+	"backend/internal/logging"
 	"backend/internal/server"
 	"backend/internal/telemetry"
 	"context"
-	"log"
+	"flag"
+	"log/slog"
+	"os"
+	// 実行環境にtzdataがなくてもユーザーのタイムゾーンを解釈できるようにする
+	_ "time/tzdata"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	migrateOnly := flag.Bool("migrate", false, "apply pending schema migrations and exit")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		return 1
+	}
+	logging.Init(cfg.LogLevel)
+
+	if *migrateOnly {
+		if err := server.Migrate(cfg); err != nil {
+			slog.Error("Migration failed", "error", err)
+			return 1
+		}
+		return 0
+	}
+
 	shutdown, err := telemetry.Init(context.Background())
 	if err != nil {
-		log.Printf("telemetry init failed: %v, continuing without telemetry", err)
+		slog.Warn("telemetry init failed, continuing without telemetry", "error", err)
 	} else {
 		defer func() { _ = shutdown(context.Background()) }()
 	}
 
-	srv, dbConn, err := server.NewServer()
+	srv, err := server.NewServer(cfg)
 	if err != nil {
-		log.Fatalf("Failed to initialize server: %v", err)
-	}
-	if dbConn != nil {
-		defer dbConn.Close()
+		slog.Error("Failed to initialize server", "error", err)
+		return 1
 	}
 
-	srv.Run()
+	if err := srv.Run(); err != nil {
+		slog.Error("Server stopped with error", "error", err)
+		return 1
+	}
+	return 0
 }
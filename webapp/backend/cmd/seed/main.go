@@ -0,0 +1,297 @@
+// 負荷の再現用にユーザー・商品・セッション・注文のシードデータを投入する
+//
+//	go run ./cmd/seed -users 1000 -products 500 -orders 200000
+//
+// 接続先などの設定はサーバーと同じ環境変数 (DATABASE_URL など) から読み込む
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"log/slog"
+	"math/rand"
+	"os"
+	"time"
+
+	"backend/internal/config"
+	"backend/internal/db"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+type options struct {
+	users       int
+	products    int
+	orders      int
+	sessions    int
+	batch       int
+	password    string
+	userPrefix  string
+	days        int
+	shipping    float64
+	delivering  float64
+	sessionsOut string
+	seed        int64
+}
+
+func main() {
+	os.Exit(run())
+}
+
+func run() int {
+	var opts options
+	flag.IntVar(&opts.users, "users", 1000, "number of users to create")
+	flag.IntVar(&opts.products, "products", 500, "number of products to create")
+	flag.IntVar(&opts.orders, "orders", 100000, "number of orders to create")
+	flag.IntVar(&opts.sessions, "sessions", 100, "number of sessions to create (one per user, from the first user)")
+	flag.IntVar(&opts.batch, "batch", 1000, "rows per INSERT statement")
+	flag.StringVar(&opts.password, "password", "password", "password for every created user")
+	flag.StringVar(&opts.userPrefix, "user-prefix", "seed", "prefix of created user names")
+	flag.IntVar(&opts.days, "days", 365, "orders are spread over this many past days")
+	flag.Float64Var(&opts.shipping, "shipping", 0.2, "ratio of orders in 'shipping'")
+	flag.Float64Var(&opts.delivering, "delivering", 0.05, "ratio of orders in 'delivering' (the rest are 'completed')")
+	flag.StringVar(&opts.sessionsOut, "sessions-out", "", "write created sessions as CSV (user_name,session_id) to this file")
+	flag.Int64Var(&opts.seed, "seed", time.Now().UnixNano(), "random seed")
+	flag.Parse()
+
+	cfg, err := config.Load()
+	if err != nil {
+		slog.Error("Failed to load configuration", "error", err)
+		return 1
+	}
+	logging.Init(cfg.LogLevel)
+
+	if err := opts.validate(); err != nil {
+		slog.Error("Invalid options", "error", err)
+		return 2
+	}
+
+	dbConn, err := db.InitDBConnection(cfg.DB)
+	if err != nil {
+		slog.Error("Failed to connect to database", "error", err)
+		return 1
+	}
+	defer dbConn.Close()
+
+	s := &seeder{
+		opts:  opts,
+		store: repository.NewStore(dbConn),
+		rng:   rand.New(rand.NewSource(opts.seed)),
+	}
+	if err := s.run(context.Background()); err != nil {
+		slog.Error("Seeding failed", "error", err)
+		return 1
+	}
+	return 0
+}
+
+func (o options) validate() error {
+	switch {
+	case o.users < 0 || o.products < 0 || o.orders < 0 || o.sessions < 0:
+		return errors.New("counts must not be negative")
+	case o.batch <= 0:
+		return errors.New("-batch must be positive")
+	case o.orders > 0 && (o.users == 0 || o.products == 0):
+		return errors.New("orders need at least one user and one product")
+	case o.sessions > o.users:
+		return errors.New("-sessions must not exceed -users")
+	case o.shipping < 0 || o.delivering < 0 || o.shipping+o.delivering > 1:
+		return errors.New("-shipping and -delivering must be ratios whose sum is at most 1")
+	case o.days <= 0:
+		return errors.New("-days must be positive")
+	}
+	return nil
+}
+
+type seeder struct {
+	opts       options
+	store      *repository.Store
+	rng        *rand.Rand
+	userIDs    []model.UserID
+	userNames  []string
+	productIDs []model.ProductID
+	// productIDs と同じ順の重さと価値 (注文に写す)
+	productAttrs []model.ProductAttributes
+}
+
+func (s *seeder) run(ctx context.Context) error {
+	start := time.Now()
+	if err := s.seedUsers(ctx); err != nil {
+		return fmt.Errorf("users: %w", err)
+	}
+	if err := s.seedProducts(ctx); err != nil {
+		return fmt.Errorf("products: %w", err)
+	}
+	if err := s.seedSessions(ctx); err != nil {
+		return fmt.Errorf("sessions: %w", err)
+	}
+	if err := s.seedOrders(ctx); err != nil {
+		return fmt.Errorf("orders: %w", err)
+	}
+	slog.Info("Seeding completed", "duration", time.Since(start).String(), "seed", s.opts.seed)
+	if s.opts.products > 0 {
+		// 一覧のETagは商品の追加で変わるが、起動中のサーバーは総数をキャッシュしている
+		slog.Info("Products were added; running servers refresh product counts after the cache TTL, or call POST /api/admin/catalog/invalidate")
+	}
+	return nil
+}
+
+// bcryptは遅いため、全ユーザーで同じハッシュを使う
+func (s *seeder) seedUsers(ctx context.Context) error {
+	if s.opts.users == 0 {
+		return nil
+	}
+	hash, err := bcrypt.GenerateFromPassword([]byte(s.opts.password), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+
+	suffix := s.rng.Intn(1 << 20)
+	return inBatches(s.opts.users, s.opts.batch, func(from, to int) error {
+		users := make([]model.User, 0, to-from)
+		for i := from; i < to; i++ {
+			users = append(users, model.User{
+				UserName:     fmt.Sprintf("%s%05x-%06d", s.opts.userPrefix, suffix, i+1),
+				PasswordHash: string(hash),
+			})
+		}
+		ids, err := s.store.UserRepo.BulkCreate(ctx, users)
+		if err != nil {
+			return err
+		}
+		s.userIDs = append(s.userIDs, ids...)
+		for _, u := range users {
+			s.userNames = append(s.userNames, u.UserName)
+		}
+		slog.Info("Created users", "count", to)
+		return nil
+	})
+}
+
+var (
+	productAdjectives = []string{"軽量", "頑丈な", "高級", "お徳用", "コンパクト", "業務用", "限定", "折りたたみ式", "防水", "静音"}
+	productNouns      = []string{"ノート", "ボールペン", "マグカップ", "収納ボックス", "ケーブル", "モバイルバッテリー", "ブランケット", "水筒", "ハサミ", "スピーカー", "デスクライト", "リュック"}
+)
+
+func (s *seeder) seedProducts(ctx context.Context) error {
+	if s.opts.products == 0 {
+		return nil
+	}
+	return inBatches(s.opts.products, s.opts.batch, func(from, to int) error {
+		products := make([]model.Product, 0, to-from)
+		for i := from; i < to; i++ {
+			adj := productAdjectives[s.rng.Intn(len(productAdjectives))]
+			noun := productNouns[s.rng.Intn(len(productNouns))]
+			products = append(products, model.Product{
+				Name:        fmt.Sprintf("%s%s %d", adj, noun, i+1),
+				Value:       100 + s.rng.Intn(50)*100,
+				Weight:      1 + s.rng.Intn(50),
+				Image:       fmt.Sprintf("chello_%02d.png", 1+s.rng.Intn(3)),
+				Description: fmt.Sprintf("%sの%sです。シードデータとして生成されました。", adj, noun),
+			})
+		}
+		ids, err := s.store.ProductRepo.BulkCreate(ctx, products)
+		if err != nil {
+			return err
+		}
+		s.productIDs = append(s.productIDs, ids...)
+		for _, p := range products {
+			s.productAttrs = append(s.productAttrs, model.ProductAttributes{Weight: p.Weight, Value: p.Value})
+		}
+		slog.Info("Created products", "count", to)
+		return nil
+	})
+}
+
+func (s *seeder) seedSessions(ctx context.Context) error {
+	if s.opts.sessions == 0 {
+		return nil
+	}
+	var w *csv.Writer
+	if s.opts.sessionsOut != "" {
+		f, err := os.Create(s.opts.sessionsOut)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = csv.NewWriter(f)
+		defer w.Flush()
+	}
+
+	for i := 0; i < s.opts.sessions; i++ {
+		sessionID, _, err := s.store.SessionRepo.Create(ctx, s.userIDs[i], 30*24*time.Hour)
+		if err != nil {
+			return err
+		}
+		if w != nil {
+			if err := w.Write([]string{s.userNames[i], sessionID}); err != nil {
+				return err
+			}
+		}
+	}
+	slog.Info("Created sessions", "count", s.opts.sessions, "output", s.opts.sessionsOut)
+	return nil
+}
+
+func (s *seeder) seedOrders(ctx context.Context) error {
+	if s.opts.orders == 0 {
+		return nil
+	}
+	now := time.Now().UTC().Truncate(time.Second)
+	span := time.Duration(s.opts.days) * 24 * time.Hour
+
+	return inBatches(s.opts.orders, s.opts.batch, func(from, to int) error {
+		orders := make([]model.Order, 0, to-from)
+		for i := from; i < to; i++ {
+			createdAt := now.Add(-time.Duration(s.rng.Int63n(int64(span)))).Truncate(time.Second)
+			userID := s.userIDs[s.rng.Intn(len(s.userIDs))]
+			p := s.rng.Intn(len(s.productIDs))
+			order := model.Order{
+				UserID:    userID,
+				ProductID: s.productIDs[p],
+				Weight:    s.productAttrs[p].Weight,
+				Value:     s.productAttrs[p].Value,
+				CreatedAt: createdAt,
+			}
+			switch r := s.rng.Float64(); {
+			case r < s.opts.shipping:
+				order.ShippedStatus = "shipping"
+			case r < s.opts.shipping+s.opts.delivering:
+				order.ShippedStatus = "delivering"
+			default:
+				order.ShippedStatus = "completed"
+				arrivedAt := createdAt.Add(time.Duration(1+s.rng.Intn(72)) * time.Hour)
+				if arrivedAt.After(now) {
+					arrivedAt = now
+				}
+				order.ArrivedAt = sql.NullTime{Time: arrivedAt, Valid: true}
+			}
+			orders = append(orders, order)
+		}
+		if err := s.store.OrderRepo.Import(ctx, orders); err != nil {
+			return err
+		}
+		if to%(s.opts.batch*50) == 0 || to == s.opts.orders {
+			slog.Info("Created orders", "count", to)
+		}
+		return nil
+	})
+}
+
+// [0, total) を size ごとに区切って fn を呼ぶ
+func inBatches(total, size int, fn func(from, to int) error) error {
+	for from := 0; from < total; from += size {
+		to := min(from+size, total)
+		if err := fn(from, to); err != nil {
+			return err
+		}
+	}
+	return nil
+}
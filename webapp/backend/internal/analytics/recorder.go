@@ -0,0 +1,75 @@
+package analytics
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"backend/internal/event"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// 注文の作成と配送完了をイベントから1時間単位で数え、定期的にDBへ加算する
+// 注文のトランザクション内で同じ集計行を更新すると行ロックの待ちが発生するため、プロセス内で溜めてから書き込む
+// プロセスが異常終了した場合、最後の書き込み以降の件数は失われる
+type Recorder struct {
+	store *repository.Store
+
+	mu      sync.Mutex
+	pending map[time.Time]model.OrderVolume
+}
+
+func NewRecorder(store *repository.Store) *Recorder {
+	return &Recorder{store: store, pending: make(map[time.Time]model.OrderVolume)}
+}
+
+// コミットされた注文の作成と配送完了を数える
+func (r *Recorder) Subscribe(bus *event.Bus) {
+	event.On(bus, func(ctx context.Context, e event.OrdersCreated) {
+		r.add(model.OrderVolume{Start: currentHour(), OrdersCreated: len(e.OrderIDs)})
+	})
+	event.On(bus, func(ctx context.Context, e event.OrderStatusChanged) {
+		if e.NewStatus == "completed" {
+			r.add(model.OrderVolume{Start: currentHour(), DeliveriesCompleted: 1})
+		}
+	})
+}
+
+func currentHour() time.Time {
+	return time.Now().UTC().Truncate(time.Hour)
+}
+
+func (r *Recorder) add(deltas ...model.OrderVolume) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for _, d := range deltas {
+		v := r.pending[d.Start]
+		v.Start = d.Start
+		v.OrdersCreated += d.OrdersCreated
+		v.DeliveriesCompleted += d.DeliveriesCompleted
+		r.pending[d.Start] = v
+	}
+}
+
+// 溜めた件数をDBに加算する。失敗した場合は次回に持ち越す
+// スケジューラから定期的に呼び出し、停止時にも呼び出す
+func (r *Recorder) Flush(ctx context.Context) error {
+	r.mu.Lock()
+	pending := r.pending
+	r.pending = make(map[time.Time]model.OrderVolume)
+	r.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	deltas := make([]model.OrderVolume, 0, len(pending))
+	for _, v := range pending {
+		deltas = append(deltas, v)
+	}
+	if err := r.store.AnalyticsRepo.AddHourly(ctx, deltas); err != nil {
+		r.add(deltas...)
+		return err
+	}
+	return nil
+}
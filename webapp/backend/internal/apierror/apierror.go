@@ -0,0 +1,122 @@
+package apierror
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/internal/payment"
+	"backend/internal/repository"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// エラーの種類を表すコード
+// クライアントはメッセージではなくこの値で分岐する
+const (
+	CodeBadRequest       = "bad_request"
+	CodeValidation       = "validation_failed"
+	CodeUnauthorized     = "unauthorized"
+	CodeForbidden        = "forbidden"
+	CodeNotFound         = "not_found"
+	CodeConflict         = "conflict"
+	CodeUnprocessable    = "unprocessable_entity"
+	CodeOverloaded       = "overloaded"
+	CodeUnavailable      = "unavailable"
+	CodeTimeout          = "timeout"
+	CodeInternal         = "internal_error"
+	CodePayloadTooLarge  = "payload_too_large"
+	CodeMethodNotAllowed = "method_not_allowed"
+	CodePaymentDeclined  = "payment_declined"
+	// クライアントが応答を待たずに切断した (レスポンスは届かず、ログとメトリクスのためのもの)
+	CodeClientClosed = "client_closed_request"
+)
+
+// クライアントが切断したリクエストのステータス (nginx と同じ非標準の値)
+const StatusClientClosedRequest = 499
+
+// 入力の誤りがあったフィールド
+type Detail struct {
+	// JSON上の位置 (例: items[0].quantity)
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// エラーレスポンスの形式
+type Response struct {
+	Code      string   `json:"code"`
+	Message   string   `json:"message"`
+	RequestID string   `json:"request_id,omitempty"`
+	Details   []Detail `json:"details,omitempty"`
+}
+
+// サービス・リポジトリから返ったエラーをHTTPステータスとコードに変換する
+// ステータスの決定はこの関数に集約し、各ハンドラでは個別に判定しない
+func FromError(err error) (int, string) {
+	switch {
+	case errors.Is(err, repository.ErrNotFound):
+		return http.StatusNotFound, CodeNotFound
+	case errors.Is(err, repository.ErrConflict):
+		return http.StatusConflict, CodeConflict
+	case errors.Is(err, repository.ErrForeignKey):
+		return http.StatusUnprocessableEntity, CodeUnprocessable
+	case errors.Is(err, repository.ErrUnavailable), errors.Is(err, repository.ErrRetryable):
+		return http.StatusServiceUnavailable, CodeUnavailable
+	case errors.Is(err, payment.ErrDeclined):
+		return http.StatusPaymentRequired, CodePaymentDeclined
+	case errors.Is(err, context.DeadlineExceeded):
+		return http.StatusGatewayTimeout, CodeTimeout
+	default:
+		return http.StatusInternalServerError, CodeInternal
+	}
+}
+
+// エラーレスポンスを返す
+func Write(w http.ResponseWriter, r *http.Request, status int, code, message string, details ...Detail) {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Type", "application/json; charset=utf-8")
+	h.Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(Response{
+		Code:      code,
+		Message:   message,
+		RequestID: chimw.GetReqID(r.Context()),
+		Details:   details,
+	})
+}
+
+// err に応じたステータスでエラーレスポンスを返す
+// message はクライアント向けの文言で、err の内容は含めない
+// クライアントが切断した場合は、err の種類 (切断で失敗したクエリのエラーなど) によらず 499 にする
+func WriteError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	if ClientGone(r) {
+		Write(w, r, StatusClientClosedRequest, CodeClientClosed, message)
+		return
+	}
+	status, code := FromError(err)
+	// ロックの競合はやり直しても解消しなかったもの。少し待てば成功する可能性がある
+	if errors.Is(err, repository.ErrRetryable) {
+		w.Header().Set("Retry-After", "1")
+	}
+	Write(w, r, status, code, message)
+}
+
+// リクエストボディの読み込みに失敗した場合のエラーレスポンスを返す
+// http.MaxBytesReader の上限を超えた場合は413、それ以外は400にする
+func WriteBodyError(w http.ResponseWriter, r *http.Request, err error) {
+	var maxErr *http.MaxBytesError
+	if errors.As(err, &maxErr) {
+		Write(w, r, http.StatusRequestEntityTooLarge, CodePayloadTooLarge, "Request body too large",
+			Detail{Field: "body", Message: "must be at most " + strconv.FormatInt(maxErr.Limit, 10) + " bytes"})
+		return
+	}
+	Write(w, r, http.StatusBadRequest, CodeBadRequest, "Invalid request body")
+}
+
+// クライアントが切断してリクエストのコンテキストがキャンセルされたかどうか
+func ClientGone(r *http.Request) bool {
+	return errors.Is(r.Context().Err(), context.Canceled)
+}
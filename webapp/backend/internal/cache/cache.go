@@ -0,0 +1,78 @@
+package cache
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/metrics"
+)
+
+// キャッシュの共通インターフェース
+// バックエンド(メモリ・Redis)の障害はミスとして扱い、呼び出し側は常にDBへフォールバックできる
+type Cache[V any] interface {
+	Get(ctx context.Context, key string) (V, bool)
+	// ttl が0以下の場合は期限なし
+	Set(ctx context.Context, key string, value V, ttl time.Duration)
+	// key がない場合だけ保存し、保存したかどうかを返す (確認と保存はアトミックに行う)
+	// バックエンドの障害時は保存できたものとして true を返す
+	Add(ctx context.Context, key string, value V, ttl time.Duration) bool
+	Delete(ctx context.Context, key string)
+	// 全てのエントリを削除する
+	Clear(ctx context.Context) error
+}
+
+type Backend string
+
+const (
+	BackendMemory Backend = "memory"
+	BackendRedis  Backend = "redis"
+)
+
+// キャッシュの生成方法
+// 名前ごとに独立したキャッシュを作る (Redisではキーのプレフィックスになる)
+type Factory struct {
+	backend    Backend
+	maxEntries int
+	redis      RedisClient
+}
+
+// backend が redis の場合は client が必要
+// maxEntries はメモリキャッシュ1つあたりの上限
+func NewFactory(backend Backend, maxEntries int, client RedisClient) *Factory {
+	return &Factory{backend: backend, maxEntries: maxEntries, redis: client}
+}
+
+// 参照の結果などは name ごとにメトリクスに記録する (Instrument)
+func New[V any](f *Factory, name string) Cache[V] {
+	if f.backend == BackendRedis && f.redis != nil {
+		return Instrument[V](name, NewRedis[V](f.redis, name))
+	}
+	return Instrument[V](name, NewMemory[V](f.maxEntries))
+}
+
+// c のヒット・ミスを name ごとにメトリクスに記録する
+// メモリキャッシュの場合は追い出しとエントリ数も記録する (Redisのエントリ数は記録しない)
+func Instrument[V any](name string, c Cache[V]) Cache[V] {
+	if m, ok := c.(*Memory[V]); ok {
+		m.mu.Lock()
+		m.onEvict = func(reason string) { metrics.CacheEvictions.WithLabelValues(name, reason).Inc() }
+		m.mu.Unlock()
+		metrics.SetCacheSizeFunc(name, m.Len)
+	}
+	return &instrumented[V]{Cache: c, name: name}
+}
+
+type instrumented[V any] struct {
+	Cache[V]
+	name string
+}
+
+func (c *instrumented[V]) Get(ctx context.Context, key string) (V, bool) {
+	v, ok := c.Cache.Get(ctx, key)
+	if ok {
+		metrics.CacheHit(c.name)
+	} else {
+		metrics.CacheMiss(c.name)
+	}
+	return v, ok
+}
@@ -0,0 +1,135 @@
+package cache
+
+import (
+	"container/list"
+	"context"
+	"sync"
+	"time"
+)
+
+type memoryEntry[V any] struct {
+	key       string
+	value     V
+	expiresAt time.Time
+}
+
+// プロセス内のTTL付きLRUキャッシュ
+// maxEntries を超えると最も長く参照されていないエントリから削除する
+type Memory[V any] struct {
+	mu         sync.Mutex
+	maxEntries int
+	ll         *list.List
+	items      map[string]*list.Element
+	// エントリを追い出したときに呼ぶ (reason: capacity|expired)。Instrument で設定する
+	onEvict func(reason string)
+}
+
+// maxEntries が0以下の場合は上限なし
+func NewMemory[V any](maxEntries int) *Memory[V] {
+	return &Memory[V]{
+		maxEntries: maxEntries,
+		ll:         list.New(),
+		items:      make(map[string]*list.Element),
+	}
+}
+
+func (m *Memory[V]) Get(_ context.Context, key string) (V, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var zero V
+	el, ok := m.items[key]
+	if !ok {
+		return zero, false
+	}
+	entry := el.Value.(*memoryEntry[V])
+	if !entry.expiresAt.IsZero() && time.Now().After(entry.expiresAt) {
+		m.removeElement(el)
+		m.evicted("expired")
+		return zero, false
+	}
+	m.ll.MoveToFront(el)
+	return entry.value, true
+}
+
+func (m *Memory[V]) Set(_ context.Context, key string, value V, ttl time.Duration) {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		entry := el.Value.(*memoryEntry[V])
+		entry.value = value
+		entry.expiresAt = expiresAt
+		m.ll.MoveToFront(el)
+		return
+	}
+	m.items[key] = m.ll.PushFront(&memoryEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	if m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+		m.evicted("capacity")
+	}
+}
+
+func (m *Memory[V]) Add(_ context.Context, key string, value V, ttl time.Duration) bool {
+	var expiresAt time.Time
+	if ttl > 0 {
+		expiresAt = time.Now().Add(ttl)
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if el, ok := m.items[key]; ok {
+		entry := el.Value.(*memoryEntry[V])
+		if entry.expiresAt.IsZero() || !time.Now().After(entry.expiresAt) {
+			return false
+		}
+		m.removeElement(el)
+		m.evicted("expired")
+	}
+	m.items[key] = m.ll.PushFront(&memoryEntry[V]{key: key, value: value, expiresAt: expiresAt})
+	if m.maxEntries > 0 && m.ll.Len() > m.maxEntries {
+		m.removeElement(m.ll.Back())
+		m.evicted("capacity")
+	}
+	return true
+}
+
+func (m *Memory[V]) Delete(_ context.Context, key string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if el, ok := m.items[key]; ok {
+		m.removeElement(el)
+	}
+}
+
+func (m *Memory[V]) Clear(_ context.Context) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.ll.Init()
+	m.items = make(map[string]*list.Element)
+	return nil
+}
+
+// 保持しているエントリの数 (期限切れでまだ参照されていないものを含む)
+func (m *Memory[V]) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.ll.Len()
+}
+
+func (m *Memory[V]) evicted(reason string) {
+	if m.onEvict != nil {
+		m.onEvict(reason)
+	}
+}
+
+func (m *Memory[V]) removeElement(el *list.Element) {
+	m.ll.Remove(el)
+	delete(m.items, el.Value.(*memoryEntry[V]).key)
+}
@@ -0,0 +1,103 @@
+package cache
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Redisキャッシュで使うクライアント (*redis.Client, *redis.ClusterClient)
+type RedisClient interface {
+	redis.Cmdable
+}
+
+// Redisに値をJSONで保存するキャッシュ
+// 複数のバックエンド間でキャッシュを共有したい場合に使う
+type Redis[V any] struct {
+	client RedisClient
+	prefix string
+}
+
+func NewRedis[V any](client RedisClient, name string) *Redis[V] {
+	return &Redis[V]{client: client, prefix: name + ":"}
+}
+
+func (r *Redis[V]) Get(ctx context.Context, key string) (V, bool) {
+	var value V
+	body, err := r.client.Get(ctx, r.prefix+key).Bytes()
+	if err != nil {
+		if !errors.Is(err, redis.Nil) {
+			slog.Warn("redis cache get failed", "key", r.prefix+key, "error", err)
+		}
+		return value, false
+	}
+	if err := json.Unmarshal(body, &value); err != nil {
+		slog.Warn("redis cache decode failed", "key", r.prefix+key, "error", err)
+		return value, false
+	}
+	return value, true
+}
+
+func (r *Redis[V]) Set(ctx context.Context, key string, value V, ttl time.Duration) {
+	body, err := json.Marshal(value)
+	if err != nil {
+		slog.Warn("redis cache encode failed", "key", r.prefix+key, "error", err)
+		return
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	if err := r.client.Set(ctx, r.prefix+key, body, ttl).Err(); err != nil {
+		slog.Warn("redis cache set failed", "key", r.prefix+key, "error", err)
+	}
+}
+
+// SET NX で保存する
+func (r *Redis[V]) Add(ctx context.Context, key string, value V, ttl time.Duration) bool {
+	body, err := json.Marshal(value)
+	if err != nil {
+		slog.Warn("redis cache encode failed", "key", r.prefix+key, "error", err)
+		return true
+	}
+	if ttl < 0 {
+		ttl = 0
+	}
+	added, err := r.client.SetNX(ctx, r.prefix+key, body, ttl).Result()
+	if err != nil {
+		slog.Warn("redis cache add failed", "key", r.prefix+key, "error", err)
+		return true
+	}
+	return added
+}
+
+func (r *Redis[V]) Delete(ctx context.Context, key string) {
+	if err := r.client.Del(ctx, r.prefix+key).Err(); err != nil {
+		slog.Warn("redis cache delete failed", "key", r.prefix+key, "error", err)
+	}
+}
+
+// プレフィックスに一致するキーをSCANで探して削除する
+func (r *Redis[V]) Clear(ctx context.Context) error {
+	iter := r.client.Scan(ctx, 0, r.prefix+"*", 1000).Iterator()
+	var keys []string
+	for iter.Next(ctx) {
+		keys = append(keys, iter.Val())
+		if len(keys) >= 1000 {
+			if err := r.client.Del(ctx, keys...).Err(); err != nil {
+				return err
+			}
+			keys = keys[:0]
+		}
+	}
+	if err := iter.Err(); err != nil {
+		return err
+	}
+	if len(keys) > 0 {
+		return r.client.Del(ctx, keys...).Err()
+	}
+	return nil
+}
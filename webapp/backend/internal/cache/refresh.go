@@ -0,0 +1,107 @@
+package cache
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// Refresher が保存する値と、読み込み直しが必要になる時刻
+type Entry[V any] struct {
+	Value      V         `json:"value"`
+	FreshUntil time.Time `json:"fresh_until"`
+}
+
+// キャッシュにない値を読み込む関数
+type LoadFunc[V any] func(ctx context.Context) (V, error)
+
+// 読み込みの集約と stale-while-revalidate を行うキャッシュ
+// 同じキーの読み込みは同時に1つだけ実行し、待っている呼び出しには同じ結果を返す
+// ttl を過ぎた値も staleFor の間はそのまま返し、裏で1回だけ読み込み直す
+type Refresher[V any] struct {
+	cache    Cache[Entry[V]]
+	ttl      time.Duration
+	staleFor time.Duration
+	group    singleflight.Group
+	// Clear のたびに進める。Clear より前に始まった読み込みの結果は保存しない
+	gen atomic.Uint64
+}
+
+// ttl が0以下の場合は期限なし (読み込み直さない)
+func NewRefresher[V any](c Cache[Entry[V]], ttl, staleFor time.Duration) *Refresher[V] {
+	return &Refresher[V]{cache: c, ttl: ttl, staleFor: staleFor}
+}
+
+// key の値を返す。キャッシュになければ load で読み込んで保存する
+// 期限切れの値は読み込み直しを始めた上でそのまま返す
+func (r *Refresher[V]) Get(ctx context.Context, key string, load LoadFunc[V]) (V, error) {
+	if e, ok := r.cache.Get(ctx, key); ok {
+		if !e.FreshUntil.IsZero() && time.Now().After(e.FreshUntil) {
+			r.refresh(ctx, key, load)
+		}
+		return e.Value, nil
+	}
+
+	gen := r.gen.Load()
+	// 最初の呼び出し元がキャンセルしても、待っている他の呼び出しには結果を返す
+	v, err, _ := r.group.Do(flightKey(gen, key), func() (any, error) {
+		return r.load(context.WithoutCancel(ctx), gen, key, load)
+	})
+	if err != nil {
+		var zero V
+		return zero, err
+	}
+	return v.(V), nil
+}
+
+// キャッシュにある値を返す (期限切れでも読み込み直さない)
+func (r *Refresher[V]) Peek(ctx context.Context, key string) (V, bool) {
+	e, ok := r.cache.Get(ctx, key)
+	return e.Value, ok
+}
+
+// 全ての値を削除する
+func (r *Refresher[V]) Clear(ctx context.Context) error {
+	r.gen.Add(1)
+	return r.cache.Clear(ctx)
+}
+
+// 期限切れの値をバックグラウンドで読み込み直す
+// 同じキーを読み込み中であれば何もしない
+func (r *Refresher[V]) refresh(ctx context.Context, key string, load LoadFunc[V]) {
+	ctx = context.WithoutCancel(ctx)
+	gen := r.gen.Load()
+	r.group.DoChan(flightKey(gen, key), func() (any, error) {
+		v, err := r.load(ctx, gen, key, load)
+		if err != nil {
+			// 古い値を返し続け、次の呼び出しで再び読み込みを試みる
+			slog.Warn("cache refresh failed", "key", key, "error", err)
+		}
+		return v, err
+	})
+}
+
+func (r *Refresher[V]) load(ctx context.Context, gen uint64, key string, load LoadFunc[V]) (any, error) {
+	v, err := load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if r.gen.Load() == gen {
+		e := Entry[V]{Value: v}
+		ttl := time.Duration(0)
+		if r.ttl > 0 {
+			e.FreshUntil = time.Now().Add(r.ttl)
+			ttl = r.ttl + r.staleFor
+		}
+		r.cache.Set(ctx, key, e, ttl)
+	}
+	return v, nil
+}
+
+func flightKey(gen uint64, key string) string {
+	return strconv.FormatUint(gen, 10) + ":" + key
+}
@@ -0,0 +1,604 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// アプリケーション全体の設定
+// 環境変数から Load で読み込み、main で各コンポーネントに渡す
+type Config struct {
+	// 実行環境 (ENV または GO_ENV, default: local)
+	Env      string
+	LogLevel string
+	// MySQLを使わず、サンプルデータを入れたメモリ上のリポジトリで起動する (フロントエンドの開発用。ローカル環境のみ)
+	DevMode  bool
+	HTTP     HTTPConfig
+	DB       DBConfig
+	Auth     AuthConfig
+	Migrate  MigrateConfig
+	Outbox   OutboxConfig
+	Cache    CacheConfig
+	Flags    FlagsConfig
+	Robot    RobotConfig
+	Payment  PaymentConfig
+	Tracking TrackingConfig
+	Notify   NotificationConfig
+	Stock    StockConfig
+	Product  ProductConfig
+	// 注文数の時系列集計
+	Analytics AnalyticsConfig
+	// 注文の作成をまとめて書き込む (write-behind)
+	OrderWriter OrderWriterConfig
+	// 二重送信された注文の検出
+	DuplicateOrder DuplicateOrderConfig
+}
+
+type HTTPConfig struct {
+	Port string
+	// 停止時に処理中のリクエストの完了を待つ時間
+	ShutdownTimeout time.Duration
+	// HTTPの停止後、バックグラウンド処理の終了と停止時の処理(OnShutdown)のそれぞれを待つ時間
+	// リクエストの完了待ちで使い切られないよう ShutdownTimeout とは別に設ける
+	ShutdownHookTimeout time.Duration
+	// http.Server のタイムアウト (0: 無制限)
+	// WriteTimeout はサービス層のタイムアウト(120秒)より長くし、タイムアウトのエラーを返せるようにする
+	ReadHeaderTimeout time.Duration
+	ReadTimeout       time.Duration
+	WriteTimeout      time.Duration
+	IdleTimeout       time.Duration
+	MaxHeaderBytes    int
+	// ストリーミング・プロファイル取得など長く書き込み続けるエンドポイントの書き込み期限
+	StreamWriteTimeout time.Duration
+	// ルートグループごとの処理中リクエスト数の上限 (0: 無制限)
+	MaxInflightUser  int
+	MaxInflightRobot int
+	// 上限に達したときに空きを待つ時間
+	ShedQueueTimeout time.Duration
+	ShedRetryAfter   time.Duration
+}
+
+type DBConfig struct {
+	URL                string
+	MaxOpenConns       int
+	MaxIdleConns       int
+	ConnMaxLifetime    time.Duration
+	ConnMaxIdleTime    time.Duration
+	QueryTimeout       time.Duration
+	SlowQueryThreshold time.Duration
+	Breaker            BreakerConfig
+}
+
+type BreakerConfig struct {
+	Enabled        bool
+	Window         time.Duration
+	MinRequests    int
+	FailureRatio   float64
+	SlowThreshold  time.Duration
+	OpenDuration   time.Duration
+	HalfOpenProbes int
+}
+
+type AuthConfig struct {
+	RobotAPIKey string
+	// キーの切り替え中だけ受け付ける旧キー (未設定なら受け付けない)
+	RobotAPIKeyPrevious string
+	// 空の場合は管理用APIを公開しない
+	AdminAPIKey     string
+	SessionTTL      time.Duration
+	SessionCacheTTL time.Duration
+	// 期限切れのセッションを削除する間隔
+	SessionCleanupInterval time.Duration
+}
+
+type MigrateConfig struct {
+	// 起動時に未適用のマイグレーションを適用するか
+	OnStartup bool
+	Timeout   time.Duration
+}
+
+type RobotConfig struct {
+	// ロボットIDごとの担当倉庫のコード ("robot-001=main,robot-002=osaka" 形式)
+	HomeWarehouses map[string]string
+	// 配送計画の解き方 (auto: 表のサイズで選ぶ, dp: 常にDP, greedy: 常にGreedy)
+	PlanSolver string
+	// auto で、注文数 × 積載量 がこれを超える場合はGreedyで解く
+	PlanMaxDPCells int64
+	// Greedyで価値/重さの比が同じ注文の並べ方 (none, oldest, lighter)
+	PlanGreedyTieBreak string
+	// 配送できずに戻された回数がこれに達した注文は再配送せずに failed にする
+	MaxDeliveryAttempts int
+}
+
+type PaymentConfig struct {
+	// 決済事業者 (現在は stub のみ)
+	Provider string
+	// stub でこの金額を超える注文の決済を拒否する (0: 拒否しない)
+	StubDeclineAbove int
+}
+
+type NotificationConfig struct {
+	// 送信元のメールアドレス
+	From         string
+	PollInterval time.Duration
+	BatchSize    int
+	MaxAttempts  int
+	RetryBackoff time.Duration
+}
+
+type AnalyticsConfig struct {
+	// 集計した件数をDBに書き込む間隔
+	FlushInterval time.Duration
+}
+
+type OrderWriterConfig struct {
+	Enabled bool
+	// 溜めておける注文リクエストの数。一杯の場合はその場で作成する
+	BufferSize int
+	// 1つのトランザクションにまとめる注文リクエストの最大数
+	MaxBatch int
+	// 後続のリクエストを待ってまとめる最大の時間
+	FlushInterval time.Duration
+	// まとめた書き込み1回 (失敗時の作成し直しは1件ごと) にかける最大の時間
+	Timeout time.Duration
+}
+
+type DuplicateOrderConfig struct {
+	// 同じユーザーから同じ商品・数量の注文が来た場合に重複とみなす期間 (0: 検出しない)
+	Window time.Duration
+	// 重複した注文の扱い (reject: 作成しない, flag: 作成してヘッダで知らせる)
+	Action string
+}
+
+type ProductConfig struct {
+	// 検索の該当件数がこれを超える場合は総数を概数で返す (0: 常に正確に数える)
+	CountApproxThreshold int
+}
+
+type StockConfig struct {
+	// 在庫がこの数を下回ったらアラートを出す
+	LowThreshold  int
+	CheckInterval time.Duration
+}
+
+type TrackingConfig struct {
+	// 配送計画の1件あたりの所要時間の見積もり (到着予定時刻の計算に使う)
+	ETAPerStop time.Duration
+	// 注文の作成から配送完了までの目標時間 (SLAレポートで使う)
+	DeliverySLA time.Duration
+}
+
+type FlagsConfig struct {
+	// DBに行がないフラグの既定値 ("name=on,name2=25" 形式)
+	Defaults string
+	CacheTTL time.Duration
+}
+
+type CacheConfig struct {
+	// memory または redis
+	Backend string
+	// メモリキャッシュ1つあたりの最大エントリ数
+	MaxEntries      int
+	RedisAddr       string
+	RedisPassword   string
+	RedisDB         int
+	ProductCountTTL time.Duration
+	// 期限切れの商品総数を数え直す間、古い値を返し続ける時間
+	ProductCountStale time.Duration
+	// 管理画面のサマリーを使い回す時間
+	DashboardTTL time.Duration
+}
+
+type OutboxConfig struct {
+	// 空の場合はイベントをログに出すだけ
+	WebhookURL     string
+	WebhookTimeout time.Duration
+	PollInterval   time.Duration
+	BatchSize      int
+	MaxAttempts    int
+	RetryBackoff   time.Duration
+	// 配信中のイベントを他のリレーから隠す時間
+	Lease time.Duration
+}
+
+const (
+	defaultRobotAPIKey = "test-robot-key"
+)
+
+// 環境変数から設定を読み込み、検証する
+// 値の形式が不正な場合や必須の値がない場合は、全てのエラーをまとめて返す
+func Load() (*Config, error) {
+	l := &loader{}
+	cfg := &Config{
+		Env:      l.string("ENV", l.string("GO_ENV", "local")),
+		LogLevel: l.string("LOG_LEVEL", "info"),
+		DevMode:  l.bool("DEV_MODE", false),
+		HTTP: HTTPConfig{
+			Port:                l.string("PORT", "8080"),
+			ShutdownTimeout:     l.duration("SHUTDOWN_TIMEOUT", 30*time.Second),
+			ShutdownHookTimeout: l.duration("SHUTDOWN_HOOK_TIMEOUT", 10*time.Second),
+			ReadHeaderTimeout:   l.duration("HTTP_READ_HEADER_TIMEOUT", 5*time.Second),
+			ReadTimeout:         l.duration("HTTP_READ_TIMEOUT", 30*time.Second),
+			WriteTimeout:        l.duration("HTTP_WRITE_TIMEOUT", 150*time.Second),
+			IdleTimeout:         l.duration("HTTP_IDLE_TIMEOUT", 120*time.Second),
+			MaxHeaderBytes:      l.int("HTTP_MAX_HEADER_BYTES", 1<<20),
+			StreamWriteTimeout:  l.duration("HTTP_STREAM_WRITE_TIMEOUT", 30*time.Minute),
+			MaxInflightUser:     l.int("HTTP_MAX_INFLIGHT_USER", 256),
+			MaxInflightRobot:    l.int("HTTP_MAX_INFLIGHT_ROBOT", 64),
+			ShedQueueTimeout:    l.duration("HTTP_SHED_QUEUE_TIMEOUT", 100*time.Millisecond),
+			ShedRetryAfter:      l.duration("HTTP_SHED_RETRY_AFTER", time.Second),
+		},
+		DB: DBConfig{
+			URL:                l.string("DATABASE_URL", "user:password@tcp(db:4306)/hiroshimauniv2511-db"),
+			MaxOpenConns:       l.int("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:       l.int("DB_MAX_IDLE_CONNS", 10),
+			ConnMaxLifetime:    l.duration("DB_CONN_MAX_LIFETIME", 0),
+			ConnMaxIdleTime:    l.duration("DB_CONN_MAX_IDLE_TIME", 0),
+			QueryTimeout:       l.duration("DB_QUERY_TIMEOUT", 30*time.Second),
+			SlowQueryThreshold: l.duration("DB_SLOW_QUERY_THRESHOLD", 200*time.Millisecond),
+			Breaker: BreakerConfig{
+				Enabled:        l.bool("DB_BREAKER_ENABLED", true),
+				Window:         l.duration("DB_BREAKER_WINDOW", 10*time.Second),
+				MinRequests:    l.int("DB_BREAKER_MIN_REQUESTS", 20),
+				FailureRatio:   l.float("DB_BREAKER_FAILURE_RATIO", 0.5),
+				SlowThreshold:  l.duration("DB_BREAKER_SLOW_THRESHOLD", 0),
+				OpenDuration:   l.duration("DB_BREAKER_OPEN_DURATION", 5*time.Second),
+				HalfOpenProbes: l.int("DB_BREAKER_HALF_OPEN_PROBES", 3),
+			},
+		},
+		Auth: AuthConfig{
+			SessionTTL:             l.duration("SESSION_TTL", 24*time.Hour),
+			SessionCacheTTL:        l.duration("SESSION_CACHE_TTL", 60*time.Second),
+			SessionCleanupInterval: l.duration("SESSION_CLEANUP_INTERVAL", 10*time.Minute),
+		},
+		Migrate: MigrateConfig{
+			OnStartup: l.bool("MIGRATE_ON_STARTUP", true),
+			Timeout:   l.duration("MIGRATE_TIMEOUT", 60*time.Second),
+		},
+		Cache: CacheConfig{
+			Backend:           l.string("CACHE_BACKEND", "memory"),
+			MaxEntries:        l.int("CACHE_MAX_ENTRIES", 100_000),
+			RedisAddr:         l.string("REDIS_ADDR", ""),
+			RedisPassword:     l.string("REDIS_PASSWORD", ""),
+			RedisDB:           l.int("REDIS_DB", 0),
+			ProductCountTTL:   l.duration("PRODUCT_COUNT_CACHE_TTL", 60*time.Second),
+			ProductCountStale: l.duration("PRODUCT_COUNT_CACHE_STALE", 30*time.Second),
+			DashboardTTL:      l.duration("DASHBOARD_CACHE_TTL", 10*time.Second),
+		},
+		Robot: RobotConfig{
+			HomeWarehouses: l.stringMap("ROBOT_HOME_WAREHOUSES"),
+			PlanSolver:     l.string("ROBOT_PLAN_SOLVER", "auto"),
+			// DPの表は1セル1ビットのため、既定値で500KB程度 (bool の表だった頃の 500,000 セルと同程度のメモリ)
+			// 4,000,000 セルのDPは1回15ms程度
+			PlanMaxDPCells:      int64(l.int("ROBOT_PLAN_MAX_DP_CELLS", 4_000_000)),
+			PlanGreedyTieBreak:  l.string("ROBOT_PLAN_GREEDY_TIE_BREAK", "none"),
+			MaxDeliveryAttempts: l.int("ROBOT_MAX_DELIVERY_ATTEMPTS", 3),
+		},
+		Payment: PaymentConfig{
+			Provider:         l.string("PAYMENT_PROVIDER", "stub"),
+			StubDeclineAbove: l.int("PAYMENT_STUB_DECLINE_ABOVE", 0),
+		},
+		Notify: NotificationConfig{
+			From:         l.string("NOTIFY_FROM", "noreply@example.com"),
+			PollInterval: l.duration("NOTIFY_POLL_INTERVAL", 5*time.Second),
+			BatchSize:    l.int("NOTIFY_BATCH_SIZE", 100),
+			MaxAttempts:  l.int("NOTIFY_MAX_ATTEMPTS", 5),
+			RetryBackoff: l.duration("NOTIFY_RETRY_BACKOFF", 30*time.Second),
+		},
+		Analytics: AnalyticsConfig{
+			FlushInterval: l.duration("ANALYTICS_FLUSH_INTERVAL", 10*time.Second),
+		},
+		OrderWriter: OrderWriterConfig{
+			Enabled:       l.bool("ORDER_WRITE_BEHIND", false),
+			BufferSize:    l.int("ORDER_WRITE_BUFFER_SIZE", 1024),
+			MaxBatch:      l.int("ORDER_WRITE_MAX_BATCH", 100),
+			FlushInterval: l.duration("ORDER_WRITE_FLUSH_INTERVAL", 5*time.Millisecond),
+			Timeout:       l.duration("ORDER_WRITE_TIMEOUT", 30*time.Second),
+		},
+		DuplicateOrder: DuplicateOrderConfig{
+			Window: l.duration("ORDER_DUPLICATE_WINDOW", 0),
+			Action: l.string("ORDER_DUPLICATE_ACTION", "reject"),
+		},
+		Product: ProductConfig{
+			CountApproxThreshold: l.int("PRODUCT_COUNT_APPROX_THRESHOLD", 10000),
+		},
+		Stock: StockConfig{
+			LowThreshold:  l.int("LOW_STOCK_THRESHOLD", 10),
+			CheckInterval: l.duration("LOW_STOCK_CHECK_INTERVAL", time.Minute),
+		},
+		Tracking: TrackingConfig{
+			ETAPerStop:  l.duration("TRACKING_ETA_PER_STOP", 10*time.Minute),
+			DeliverySLA: l.duration("DELIVERY_SLA", 24*time.Hour),
+		},
+		Flags: FlagsConfig{
+			Defaults: l.string("FEATURE_FLAGS", ""),
+			CacheTTL: l.duration("FEATURE_FLAG_CACHE_TTL", 10*time.Second),
+		},
+		Outbox: OutboxConfig{
+			WebhookURL:     l.string("OUTBOX_WEBHOOK_URL", ""),
+			WebhookTimeout: l.duration("OUTBOX_WEBHOOK_TIMEOUT", 5*time.Second),
+			PollInterval:   l.duration("OUTBOX_POLL_INTERVAL", time.Second),
+			BatchSize:      l.int("OUTBOX_BATCH_SIZE", 100),
+			MaxAttempts:    l.int("OUTBOX_MAX_ATTEMPTS", 10),
+			RetryBackoff:   l.duration("OUTBOX_RETRY_BACKOFF", time.Second),
+			Lease:          l.duration("OUTBOX_LEASE", time.Minute),
+		},
+	}
+
+	cfg.Auth.RobotAPIKeyPrevious = l.string("ROBOT_API_KEY_PREVIOUS", "")
+
+	// ローカル環境以外ではロボットのAPIキーを必須とする
+	if cfg.IsLocal() {
+		cfg.Auth.RobotAPIKey = l.string("ROBOT_API_KEY", defaultRobotAPIKey)
+	} else {
+		cfg.Auth.RobotAPIKey = l.required("ROBOT_API_KEY")
+	}
+	// 管理用のAPIキーには既定値を設けない。未設定の場合は管理用APIを公開しない
+	cfg.Auth.AdminAPIKey = l.string("ADMIN_API_KEY", "")
+
+	l.check(cfg.validate())
+	if err := errors.Join(l.errs...); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+	return cfg, nil
+}
+
+func (c *Config) IsLocal() bool {
+	return c.Env == "local"
+}
+
+// ロボットのAPIキーがデフォルト値のままかどうか
+func (c *Config) UsesDefaultRobotAPIKey() bool {
+	return c.Auth.RobotAPIKey == defaultRobotAPIKey
+}
+
+func (c *Config) validate() error {
+	var errs []error
+	if c.DevMode && !c.IsLocal() {
+		errs = append(errs, fmt.Errorf("DEV_MODE: not allowed when ENV=%s", c.Env))
+	}
+	if n, err := strconv.Atoi(c.HTTP.Port); err != nil || n <= 0 || n > 65535 {
+		errs = append(errs, fmt.Errorf("PORT: %q is not a valid port", c.HTTP.Port))
+	}
+	for name, d := range map[string]time.Duration{
+		"HTTP_READ_HEADER_TIMEOUT":  c.HTTP.ReadHeaderTimeout,
+		"HTTP_READ_TIMEOUT":         c.HTTP.ReadTimeout,
+		"HTTP_WRITE_TIMEOUT":        c.HTTP.WriteTimeout,
+		"HTTP_IDLE_TIMEOUT":         c.HTTP.IdleTimeout,
+		"HTTP_STREAM_WRITE_TIMEOUT": c.HTTP.StreamWriteTimeout,
+	} {
+		if d < 0 {
+			errs = append(errs, fmt.Errorf("%s: must not be negative", name))
+		}
+	}
+	if c.HTTP.MaxHeaderBytes <= 0 {
+		errs = append(errs, errors.New("HTTP_MAX_HEADER_BYTES: must be positive"))
+	}
+	if c.DB.MaxOpenConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_OPEN_CONNS: must not be negative"))
+	}
+	if c.DB.MaxIdleConns < 0 {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS: must not be negative"))
+	}
+	if c.DB.MaxOpenConns > 0 && c.DB.MaxIdleConns > c.DB.MaxOpenConns {
+		errs = append(errs, errors.New("DB_MAX_IDLE_CONNS: must not exceed DB_MAX_OPEN_CONNS"))
+	}
+	if c.DB.Breaker.Enabled {
+		if c.DB.Breaker.FailureRatio <= 0 || c.DB.Breaker.FailureRatio > 1 {
+			errs = append(errs, errors.New("DB_BREAKER_FAILURE_RATIO: must be in (0, 1]"))
+		}
+		if c.DB.Breaker.HalfOpenProbes <= 0 {
+			errs = append(errs, errors.New("DB_BREAKER_HALF_OPEN_PROBES: must be positive"))
+		}
+	}
+	switch c.Cache.Backend {
+	case "memory":
+	case "redis":
+		if c.Cache.RedisAddr == "" {
+			errs = append(errs, errors.New("REDIS_ADDR: required when CACHE_BACKEND=redis"))
+		}
+	default:
+		errs = append(errs, fmt.Errorf("CACHE_BACKEND: unknown backend %q", c.Cache.Backend))
+	}
+	switch c.Payment.Provider {
+	case "stub":
+	default:
+		errs = append(errs, fmt.Errorf("PAYMENT_PROVIDER: unknown provider %q", c.Payment.Provider))
+	}
+	if c.Payment.StubDeclineAbove < 0 {
+		errs = append(errs, errors.New("PAYMENT_STUB_DECLINE_ABOVE: must not be negative"))
+	}
+	if c.Notify.PollInterval <= 0 {
+		errs = append(errs, errors.New("NOTIFY_POLL_INTERVAL: must be positive"))
+	}
+	if c.Notify.BatchSize <= 0 {
+		errs = append(errs, errors.New("NOTIFY_BATCH_SIZE: must be positive"))
+	}
+	if c.Tracking.ETAPerStop <= 0 {
+		errs = append(errs, errors.New("TRACKING_ETA_PER_STOP: must be positive"))
+	}
+	if c.Analytics.FlushInterval <= 0 {
+		errs = append(errs, errors.New("ANALYTICS_FLUSH_INTERVAL: must be positive"))
+	}
+	if c.Cache.ProductCountStale < 0 {
+		errs = append(errs, errors.New("PRODUCT_COUNT_CACHE_STALE: must not be negative"))
+	}
+	if c.Product.CountApproxThreshold < 0 {
+		errs = append(errs, errors.New("PRODUCT_COUNT_APPROX_THRESHOLD: must not be negative"))
+	}
+	if c.OrderWriter.Enabled {
+		if c.OrderWriter.BufferSize <= 0 {
+			errs = append(errs, errors.New("ORDER_WRITE_BUFFER_SIZE: must be positive"))
+		}
+		if c.OrderWriter.MaxBatch <= 0 {
+			errs = append(errs, errors.New("ORDER_WRITE_MAX_BATCH: must be positive"))
+		}
+		if c.OrderWriter.FlushInterval <= 0 {
+			errs = append(errs, errors.New("ORDER_WRITE_FLUSH_INTERVAL: must be positive"))
+		}
+		if c.OrderWriter.Timeout <= 0 {
+			errs = append(errs, errors.New("ORDER_WRITE_TIMEOUT: must be positive"))
+		}
+	}
+	if c.DuplicateOrder.Window < 0 {
+		errs = append(errs, errors.New("ORDER_DUPLICATE_WINDOW: must not be negative"))
+	}
+	switch c.DuplicateOrder.Action {
+	case "reject", "flag":
+	default:
+		errs = append(errs, fmt.Errorf("ORDER_DUPLICATE_ACTION: unknown action %q", c.DuplicateOrder.Action))
+	}
+	switch c.Robot.PlanSolver {
+	case "auto", "dp", "greedy":
+	default:
+		errs = append(errs, fmt.Errorf("ROBOT_PLAN_SOLVER: unknown solver %q", c.Robot.PlanSolver))
+	}
+	if c.Robot.PlanMaxDPCells <= 0 {
+		errs = append(errs, errors.New("ROBOT_PLAN_MAX_DP_CELLS: must be positive"))
+	}
+	switch c.Robot.PlanGreedyTieBreak {
+	case "none", "oldest", "lighter":
+	default:
+		errs = append(errs, fmt.Errorf("ROBOT_PLAN_GREEDY_TIE_BREAK: unknown tie-break %q", c.Robot.PlanGreedyTieBreak))
+	}
+	if c.Robot.MaxDeliveryAttempts < 1 {
+		errs = append(errs, errors.New("ROBOT_MAX_DELIVERY_ATTEMPTS: must be at least 1"))
+	}
+	if c.Stock.LowThreshold <= 0 {
+		errs = append(errs, errors.New("LOW_STOCK_THRESHOLD: must be positive"))
+	}
+	if c.Stock.CheckInterval <= 0 {
+		errs = append(errs, errors.New("LOW_STOCK_CHECK_INTERVAL: must be positive"))
+	}
+	if c.Tracking.DeliverySLA <= 0 {
+		errs = append(errs, errors.New("DELIVERY_SLA: must be positive"))
+	}
+	if c.HTTP.ShutdownHookTimeout <= 0 {
+		errs = append(errs, errors.New("SHUTDOWN_HOOK_TIMEOUT: must be positive"))
+	}
+	if c.Outbox.PollInterval <= 0 {
+		errs = append(errs, errors.New("OUTBOX_POLL_INTERVAL: must be positive"))
+	}
+	if c.Outbox.BatchSize <= 0 {
+		errs = append(errs, errors.New("OUTBOX_BATCH_SIZE: must be positive"))
+	}
+	if c.Outbox.MaxAttempts <= 0 {
+		errs = append(errs, errors.New("OUTBOX_MAX_ATTEMPTS: must be positive"))
+	}
+	if c.Outbox.Lease <= 0 {
+		errs = append(errs, errors.New("OUTBOX_LEASE: must be positive"))
+	}
+	if c.Auth.SessionCleanupInterval <= 0 {
+		errs = append(errs, errors.New("SESSION_CLEANUP_INTERVAL: must be positive"))
+	}
+	if c.Auth.SessionTTL <= 0 {
+		errs = append(errs, errors.New("SESSION_TTL: must be positive"))
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "warning", "error":
+	default:
+		errs = append(errs, fmt.Errorf("LOG_LEVEL: unknown level %q", c.LogLevel))
+	}
+	return errors.Join(errs...)
+}
+
+// 環境変数の読み込み中に発生したエラーを溜めておき、最後にまとめて返す
+type loader struct {
+	errs []error
+}
+
+func (l *loader) check(err error) {
+	if err != nil {
+		l.errs = append(l.errs, err)
+	}
+}
+
+func (l *loader) string(key, def string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return def
+}
+
+func (l *loader) required(key string) string {
+	v := os.Getenv(key)
+	if v == "" {
+		l.errs = append(l.errs, fmt.Errorf("%s: required", key))
+	}
+	return v
+}
+
+func (l *loader) int(key string, def int) int {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not an integer", key, v))
+		return def
+	}
+	return n
+}
+
+// "key=value,key2=value2" 形式の値を読み込む
+func (l *loader) stringMap(key string) map[string]string {
+	m := map[string]string{}
+	v := os.Getenv(key)
+	if v == "" {
+		return m
+	}
+	for _, pair := range strings.Split(v, ",") {
+		k, val, ok := strings.Cut(strings.TrimSpace(pair), "=")
+		k, val = strings.TrimSpace(k), strings.TrimSpace(val)
+		if !ok || k == "" || val == "" {
+			l.errs = append(l.errs, fmt.Errorf("%s: %q is not a key=value pair", key, pair))
+			continue
+		}
+		m[k] = val
+	}
+	return m
+}
+
+func (l *loader) float(key string, def float64) float64 {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a number", key, v))
+		return def
+	}
+	return f
+}
+
+func (l *loader) bool(key string, def bool) bool {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a boolean", key, v))
+		return def
+	}
+	return b
+}
+
+func (l *loader) duration(key string, def time.Duration) time.Duration {
+	v := os.Getenv(key)
+	if v == "" {
+		return def
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		l.errs = append(l.errs, fmt.Errorf("%s: %q is not a duration", key, v))
+		return def
+	}
+	return d
+}
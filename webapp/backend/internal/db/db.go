@@ -1,45 +1,56 @@
 package db
 
 import (
+	"backend/internal/config"
 	"backend/internal/telemetry"
 	"context"
 	"fmt"
-	"log"
-	"os"
+	"log/slog"
 	"time"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 	"github.com/jmoiron/sqlx"
 )
 
-func InitDBConnection() (*sqlx.DB, error) {
-	dbUrl := os.Getenv("DATABASE_URL")
-	if dbUrl == "" {
-		dbUrl = "user:password@tcp(db:4306)/hiroshimauniv2511-db"
+func InitDBConnection(cfg config.DBConfig) (*sqlx.DB, error) {
+	// 日時はUTCで保存・解釈する
+	// time_zone はセッションの NOW() などをサーバーの設定に依らずUTCにするため
+	dsn := fmt.Sprintf("%s?charset=utf8mb4&parseTime=True&loc=UTC&time_zone=%%27%%2B00%%3A00%%27", cfg.URL)
+	// DSNにはパスワードが含まれるため、接続先のホストとDB名だけをログに出す
+	if parsed, err := mysql.ParseDSN(dsn); err == nil {
+		slog.Info("Connecting to database", "addr", parsed.Addr, "db", parsed.DBName)
+	} else {
+		slog.Info("Connecting to database")
 	}
-	dsn := fmt.Sprintf("%s?charset=utf8mb4&parseTime=True&loc=UTC", dbUrl)
-	log.Printf(dsn)
 
 	driverName := telemetry.WrapSQLDriver("mysql")
 	dbConn, err := sqlx.Open(driverName, dsn)
 	if err != nil {
-		log.Printf("Failed to open database connection: %v", err)
+		slog.Error("Failed to open database connection", "error", err)
 		return nil, fmt.Errorf("failed to open database connection: %w", err)
 	}
 
+	// Ping より前に設定し、最初の接続からプール設定を反映させる
+	dbConn.SetMaxOpenConns(cfg.MaxOpenConns)
+	dbConn.SetMaxIdleConns(cfg.MaxIdleConns)
+	dbConn.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	dbConn.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+	slog.Info("DB pool configured",
+		"max_open", cfg.MaxOpenConns,
+		"max_idle", cfg.MaxIdleConns,
+		"max_lifetime", cfg.ConnMaxLifetime.String(),
+		"max_idle_time", cfg.ConnMaxIdleTime.String(),
+	)
+
 	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 	defer cancel()
 	err = dbConn.PingContext(ctx)
 	if err != nil {
 		dbConn.Close()
-		log.Printf("Failed to connect to database: %v", err)
+		slog.Error("Failed to connect to database", "error", err)
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
-	log.Println("Successfully connected to MySQL!")
-
-	dbConn.SetMaxOpenConns(25)
-	dbConn.SetMaxIdleConns(10)
-	dbConn.SetConnMaxLifetime(0)
+	slog.Info("Successfully connected to MySQL!")
 
 	return dbConn, nil
 }
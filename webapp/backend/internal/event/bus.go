@@ -0,0 +1,110 @@
+package event
+
+import (
+	"context"
+	"sync"
+
+	"backend/internal/logging"
+	"backend/internal/repository"
+)
+
+// コミット後に呼ばれる購読者
+// 失敗しても発行元には影響しないため、エラーは購読者側で処理する
+type Handler func(ctx context.Context, e Event)
+
+// 発行元と同じトランザクション内で呼ばれる購読者
+// エラーを返すと発行元のトランザクションごと失敗する
+type TxHandler func(ctx context.Context, tx *repository.Store, e Event) error
+
+type subscription[H any] struct {
+	types   map[string]bool
+	handler H
+}
+
+func (s subscription[H]) matches(e Event) bool {
+	return len(s.types) == 0 || s.types[e.Type()]
+}
+
+// プロセス内のイベントバス
+// サービスはイベントを発行するだけにし、Webhook配信やキャッシュの破棄などの反応は購読者として登録する
+type Bus struct {
+	mu     sync.RWMutex
+	subs   []subscription[Handler]
+	txSubs []subscription[TxHandler]
+}
+
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+func typeSet(types []string) map[string]bool {
+	if len(types) == 0 {
+		return nil
+	}
+	set := make(map[string]bool, len(types))
+	for _, t := range types {
+		set[t] = true
+	}
+	return set
+}
+
+// types のイベントを購読する (省略時は全てのイベント)
+func (b *Bus) Subscribe(h Handler, types ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.subs = append(b.subs, subscription[Handler]{types: typeSet(types), handler: h})
+}
+
+// types のイベントを発行元のトランザクション内で購読する (省略時は全てのイベント)
+func (b *Bus) SubscribeTx(h TxHandler, types ...string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.txSubs = append(b.txSubs, subscription[TxHandler]{types: typeSet(types), handler: h})
+}
+
+// 型を指定して購読する
+func On[E Event](b *Bus, fn func(ctx context.Context, e E)) {
+	var zero E
+	b.Subscribe(func(ctx context.Context, e Event) {
+		if typed, ok := e.(E); ok {
+			fn(ctx, typed)
+		}
+	}, zero.Type())
+}
+
+// イベントを発行する
+// tx がトランザクション内のStoreであれば、トランザクション内の購読者をその場で呼び、
+// それ以外の購読者はコミット後に呼ぶ (ロールバックされた場合は呼ばない)
+func (b *Bus) Publish(ctx context.Context, tx *repository.Store, e Event) error {
+	b.mu.RLock()
+	txSubs := b.txSubs
+	subs := b.subs
+	b.mu.RUnlock()
+
+	for _, s := range txSubs {
+		if !s.matches(e) {
+			continue
+		}
+		if err := s.handler(ctx, tx, e); err != nil {
+			return err
+		}
+	}
+
+	tx.AfterCommit(func() {
+		for _, s := range subs {
+			if s.matches(e) {
+				dispatch(ctx, s.handler, e)
+			}
+		}
+	})
+	return nil
+}
+
+func dispatch(ctx context.Context, h Handler, e Event) {
+	defer func() {
+		if rec := recover(); rec != nil {
+			logging.FromContext(ctx).Error("Event handler panicked", "event_type", e.Type(), "panic", rec)
+		}
+	}()
+	h(ctx, e)
+}
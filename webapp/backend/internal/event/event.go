@@ -0,0 +1,60 @@
+package event
+
+import "backend/internal/model"
+
+// ドメインイベント
+// Type はoutbox経由でWebhookに配信する際のイベント種別にもなるため、既存の値は変更しないこと
+type Event interface {
+	Type() string
+	// イベントの対象 (ユーザーID・注文IDなど)
+	AggregateID() string
+}
+
+const (
+	TypeOrdersCreated      = "orders.created"
+	TypeOrderStatusChanged = "order.status_changed"
+	// 配送計画の作成。注文が配送中になったことを表すため、配信上の種別は orders.delivering のまま
+	TypePlanGenerated = "orders.delivering"
+	TypeStockLow      = "stock.low"
+)
+
+// ユーザーが注文を作成した
+type OrdersCreated struct {
+	UserID model.UserID `json:"user_id"`
+	// Webhookの配信形式に合わせて文字列のまま
+	OrderIDs []string `json:"order_ids"`
+}
+
+func (OrdersCreated) Type() string          { return TypeOrdersCreated }
+func (e OrdersCreated) AggregateID() string { return e.UserID.String() }
+
+// 注文のステータスが変わった
+type OrderStatusChanged struct {
+	OrderID   model.OrderID `json:"order_id"`
+	NewStatus string        `json:"new_status"`
+}
+
+func (OrderStatusChanged) Type() string          { return TypeOrderStatusChanged }
+func (e OrderStatusChanged) AggregateID() string { return e.OrderID.String() }
+
+// ロボットの配送計画を作成し、対象の注文を配送中にした
+type PlanGenerated struct {
+	RobotID     string          `json:"robot_id"`
+	OrderIDs    []model.OrderID `json:"order_ids"`
+	TotalWeight int             `json:"total_weight"`
+	TotalValue  int             `json:"total_value"`
+}
+
+func (PlanGenerated) Type() string          { return TypePlanGenerated }
+func (e PlanGenerated) AggregateID() string { return e.RobotID }
+
+// 倉庫の商品の在庫が閾値を下回った
+type StockLow struct {
+	ProductID   model.ProductID `json:"product_id"`
+	WarehouseID int             `json:"warehouse_id"`
+	Quantity    int             `json:"quantity"`
+	Threshold   int             `json:"threshold"`
+}
+
+func (StockLow) Type() string          { return TypeStockLow }
+func (e StockLow) AggregateID() string { return e.ProductID.String() }
@@ -0,0 +1,154 @@
+package featureflag
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// サービスが参照するフラグ
+const (
+	// 配送計画をDPではなく常にGreedyで作る (対象はロボットID)
+	RobotPlanGreedy = "robot_plan_greedy"
+	// ORDER_WRITE_BEHIND が有効な場合に、注文をまとめて書き込む (対象はユーザーID)
+	// FEATURE_FLAGS で指定しなければ全ユーザーで有効
+	OrderWriteBehind = "order_write_behind"
+)
+
+var ErrInvalidFlag = errors.New("invalid feature flag")
+
+// フィーチャーフラグの参照・更新
+// DBの値を優先し、DBに行がなければ環境変数で与えた既定値を使う
+// 参照結果は ttl の間キャッシュされるため、更新が全インスタンスに反映されるまで最大 ttl かかる
+type Flags struct {
+	repo     *repository.FeatureFlagRepository
+	defaults map[string]model.FeatureFlag
+	cache    cache.Cache[model.FeatureFlag]
+	ttl      time.Duration
+}
+
+func New(repo *repository.FeatureFlagRepository, defaults map[string]model.FeatureFlag, c cache.Cache[model.FeatureFlag], ttl time.Duration) *Flags {
+	if defaults == nil {
+		defaults = map[string]model.FeatureFlag{}
+	}
+	return &Flags{repo: repo, defaults: defaults, cache: c, ttl: ttl}
+}
+
+// "name=on,name2=off,name3=25" 形式の既定値を解析する
+// 数値は有効にする割合(%)を表す
+func ParseDefaults(spec string) (map[string]model.FeatureFlag, error) {
+	defaults := map[string]model.FeatureFlag{}
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(item, "=")
+		name = strings.TrimSpace(name)
+		if !ok || name == "" {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidFlag, item)
+		}
+		flag := model.FeatureFlag{Name: name, RolloutPercent: 100}
+		switch value = strings.TrimSpace(value); value {
+		case "on", "true", "1":
+			flag.Enabled = true
+		case "off", "false", "0":
+		default:
+			percent, err := strconv.Atoi(strings.TrimSuffix(value, "%"))
+			if err != nil || percent < 0 || percent > 100 {
+				return nil, fmt.Errorf("%w: %q", ErrInvalidFlag, item)
+			}
+			flag.Enabled = percent > 0
+			flag.RolloutPercent = percent
+		}
+		defaults[name] = flag
+	}
+	return defaults, nil
+}
+
+// subject (ユーザーIDやロボットIDなど) に対してフラグが有効かどうか
+// 同じ subject には常に同じ結果を返すため、割合を上げても既に有効な対象は有効のままになる
+func (f *Flags) Enabled(ctx context.Context, name, subject string) bool {
+	flag := f.lookup(ctx, name)
+	if !flag.Enabled || flag.RolloutPercent <= 0 {
+		return false
+	}
+	if flag.RolloutPercent >= 100 {
+		return true
+	}
+	return bucket(name, subject) < flag.RolloutPercent
+}
+
+func (f *Flags) lookup(ctx context.Context, name string) model.FeatureFlag {
+	if flag, ok := f.cache.Get(ctx, name); ok {
+		return flag
+	}
+	flag, err := f.repo.Get(ctx, name)
+	switch {
+	case err == nil:
+	case errors.Is(err, repository.ErrNotFound):
+		flag = f.defaults[name]
+		flag.Name = name
+	default:
+		// DBに問題がある間は既定値で動かし、キャッシュはしない
+		logging.FromContext(ctx).Warn("Failed to load feature flag, using default", "flag", name, "error", err)
+		flag = f.defaults[name]
+		flag.Name = name
+		return flag
+	}
+	f.cache.Set(ctx, name, flag, f.ttl)
+	return flag
+}
+
+// DBと既定値を合わせた全フラグ
+func (f *Flags) List(ctx context.Context) ([]model.FeatureFlag, error) {
+	stored, err := f.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	merged := make(map[string]model.FeatureFlag, len(stored)+len(f.defaults))
+	for name, flag := range f.defaults {
+		merged[name] = flag
+	}
+	for _, flag := range stored {
+		merged[flag.Name] = flag
+	}
+	flags := make([]model.FeatureFlag, 0, len(merged))
+	for _, flag := range merged {
+		flags = append(flags, flag)
+	}
+	sort.Slice(flags, func(i, j int) bool { return flags[i].Name < flags[j].Name })
+	return flags, nil
+}
+
+// フラグを更新する
+func (f *Flags) Set(ctx context.Context, flag model.FeatureFlag) error {
+	if flag.Name == "" || len(flag.Name) > 64 || flag.RolloutPercent < 0 || flag.RolloutPercent > 100 {
+		return ErrInvalidFlag
+	}
+	if err := f.repo.Upsert(ctx, flag); err != nil {
+		return err
+	}
+	f.cache.Delete(ctx, flag.Name)
+	logging.FromContext(ctx).Info("Feature flag updated",
+		"flag", flag.Name, "enabled", flag.Enabled, "rollout_percent", flag.RolloutPercent)
+	return nil
+}
+
+func bucket(name, subject string) int {
+	h := fnv.New32a()
+	h.Write([]byte(name))
+	h.Write([]byte{':'})
+	h.Write([]byte(subject))
+	return int(h.Sum32() % 100)
+}
@@ -0,0 +1,61 @@
+package graphql
+
+import (
+	_ "embed"
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/service"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+//go:embed schema.graphql
+var schemaSDL string
+
+// リクエストボディの上限
+const maxBodyBytes = 1 << 20
+
+// 注文履歴の画面を1回のリクエストで組み立てられるよう、ユーザー向けAPIをGraphQLで提供する
+// ユーザーの認証はルーティング側のミドルウェアで行う
+type Handler struct {
+	schema *graphqlgo.Schema
+}
+
+func NewHandler(orders *service.OrderService, products *service.ProductService, users *service.UserService) *Handler {
+	resolver := &rootResolver{orders: orders, products: products, users: users}
+	schema := graphqlgo.MustParseSchema(schemaSDL, resolver,
+		graphqlgo.MaxDepth(8),
+		graphqlgo.MaxParallelism(8),
+	)
+	return &Handler{schema: schema}
+}
+
+type request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName"`
+	Variables     map[string]any `json:"variables"`
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+	if err != nil {
+		apierror.WriteBodyError(w, r, err)
+		return
+	}
+	var req request
+	if err := json.Unmarshal(raw, &req); err != nil || req.Query == "" {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+		return
+	}
+
+	resp := h.schema.Exec(r.Context(), req.Query, req.OperationName, req.Variables)
+	if len(resp.Errors) > 0 {
+		logging.FromContext(r.Context()).Info("GraphQL query returned errors", "operation", req.OperationName, "errors", len(resp.Errors))
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,340 @@
+package graphql
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service"
+
+	graphqlgo "github.com/graph-gophers/graphql-go"
+)
+
+// クライアントに内部のエラーを見せないために返すエラー
+var errInternal = errors.New("internal error")
+
+type rootResolver struct {
+	orders   *service.OrderService
+	products *service.ProductService
+	users    *service.UserService
+}
+
+// 一覧の条件 (REST APIの ListRequest と同じ意味)
+type listArgs struct {
+	Page       *int32
+	PageSize   *int32
+	Search     *string
+	SearchType *string
+	SortField  *string
+	SortOrder  *string
+}
+
+func (a listArgs) request(spec model.ListSpec) (model.ListRequest, error) {
+	var req model.ListRequest
+	if a.Page != nil {
+		req.Page = int(*a.Page)
+	}
+	if a.PageSize != nil {
+		req.PageSize = int(*a.PageSize)
+	}
+	if a.Search != nil {
+		req.Search = *a.Search
+	}
+	if a.SearchType != nil {
+		req.Type = *a.SearchType
+	}
+	if a.SortField != nil {
+		req.SortField = *a.SortField
+	}
+	if a.SortOrder != nil {
+		req.SortOrder = *a.SortOrder
+	}
+	if err := req.Validate(spec); err != nil {
+		return req, err
+	}
+	return req, nil
+}
+
+// リクエストのユーザーと、日時の表示に使うタイムゾーン
+func (r *rootResolver) user(ctx context.Context) (model.UserID, *time.Location, error) {
+	userID, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		return 0, nil, errInternal
+	}
+	loc, err := r.users.Location(ctx, userID)
+	if err != nil {
+		return 0, nil, publicError(ctx, "Location", err)
+	}
+	return userID, loc, nil
+}
+
+// 一覧の条件の誤りはそのまま返し、それ以外はログに出して内部エラーにする
+func publicError(ctx context.Context, op string, err error) error {
+	var listErr *model.ListRequestError
+	if errors.As(err, &listErr) {
+		return listErr
+	}
+	logging.FromContext(ctx).Error("GraphQL resolver failed", "op", op, "error", err)
+	return errInternal
+}
+
+func (r *rootResolver) Me(ctx context.Context) (*userResolver, error) {
+	userID, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		return nil, errInternal
+	}
+	profile, err := r.users.Profile(ctx, userID)
+	if err != nil {
+		return nil, publicError(ctx, "Profile", err)
+	}
+	return &userResolver{profile: profile}, nil
+}
+
+func (r *rootResolver) Orders(ctx context.Context, args listArgs) (*orderConnectionResolver, error) {
+	req, err := args.request(model.OrderListSpec)
+	if err != nil {
+		return nil, err
+	}
+	userID, loc, err := r.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	orders, total, err := r.orders.FetchOrders(ctx, userID, req)
+	if err != nil {
+		return nil, publicError(ctx, "FetchOrders", err)
+	}
+
+	loader := &productLoader{products: r.products}
+	nodes := make([]*orderResolver, len(orders))
+	for i, o := range orders {
+		loader.ids = append(loader.ids, o.ProductID)
+		nodes[i] = &orderResolver{order: o, loc: loc, loader: loader}
+	}
+	return &orderConnectionResolver{page: newPage(req, total), nodes: nodes}, nil
+}
+
+func (r *rootResolver) Order(ctx context.Context, args struct{ ID graphqlgo.ID }) (*orderResolver, error) {
+	orderID, err := model.ParseOrderID(string(args.ID))
+	if err != nil {
+		return nil, nil
+	}
+	userID, loc, err := r.user(ctx)
+	if err != nil {
+		return nil, err
+	}
+	order, err := r.orders.GetOrder(ctx, userID, orderID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, publicError(ctx, "GetOrder", err)
+	}
+	loader := &productLoader{products: r.products, ids: []model.ProductID{order.ProductID}}
+	return &orderResolver{order: order, loc: loc, loader: loader}, nil
+}
+
+func (r *rootResolver) OrderStats(ctx context.Context) (*orderStatsResolver, error) {
+	userID, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		return nil, errInternal
+	}
+	counts, err := r.orders.CountByStatus(ctx, userID)
+	if err != nil {
+		return nil, publicError(ctx, "CountByStatus", err)
+	}
+	return &orderStatsResolver{counts: counts}, nil
+}
+
+func (r *rootResolver) Products(ctx context.Context, args struct {
+	Page      *int32
+	PageSize  *int32
+	Search    *string
+	SortField *string
+	SortOrder *string
+	Exact     *bool
+}) (*productConnectionResolver, error) {
+	req, err := listArgs{
+		Page:      args.Page,
+		PageSize:  args.PageSize,
+		Search:    args.Search,
+		SortField: args.SortField,
+		SortOrder: args.SortOrder,
+	}.request(model.ProductListSpec)
+	if err != nil {
+		return nil, err
+	}
+	req.Exact = args.Exact != nil && *args.Exact
+	userID, ok := middleware.GetUserFromContext(ctx)
+	if !ok {
+		return nil, errInternal
+	}
+	products, total, err := r.products.FetchProducts(ctx, userID, req)
+	if err != nil {
+		return nil, publicError(ctx, "FetchProducts", err)
+	}
+	nodes := make([]*productResolver, len(products))
+	for i := range products {
+		nodes[i] = &productResolver{product: products[i]}
+	}
+	return &productConnectionResolver{page: newPage(req, total.Count), nodes: nodes, approximate: total.Approximate}, nil
+}
+
+// 注文の商品を、最初に要求されたときに一覧の分をまとめて取得する
+// 商品を要求しないクエリでは取得しない
+type productLoader struct {
+	products *service.ProductService
+	ids      []model.ProductID
+
+	once   sync.Once
+	byID   map[model.ProductID]model.Product
+	loaded error
+}
+
+func (l *productLoader) load(ctx context.Context, productID model.ProductID) (*model.Product, error) {
+	l.once.Do(func() {
+		products, err := l.products.GetProductsByIDs(ctx, l.ids)
+		if err != nil {
+			l.loaded = publicError(ctx, "GetProductsByIDs", err)
+			return
+		}
+		l.byID = make(map[model.ProductID]model.Product, len(products))
+		for _, p := range products {
+			l.byID[p.ProductID] = p
+		}
+	})
+	if l.loaded != nil {
+		return nil, l.loaded
+	}
+	p, ok := l.byID[productID]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+type page struct {
+	total    int
+	page     int
+	pageSize int
+}
+
+func newPage(req model.ListRequest, total int) page {
+	return page{total: total, page: req.Page, pageSize: req.PageSize}
+}
+
+func (p page) TotalCount() int32 { return int32(p.total) }
+func (p page) Page() int32       { return int32(p.page) }
+func (p page) PageSize() int32   { return int32(p.pageSize) }
+func (p page) HasNextPage() bool { return p.page*p.pageSize < p.total }
+
+type orderConnectionResolver struct {
+	page
+	nodes []*orderResolver
+}
+
+func (c *orderConnectionResolver) Nodes() []*orderResolver { return c.nodes }
+
+type productConnectionResolver struct {
+	page
+	nodes       []*productResolver
+	approximate bool
+}
+
+func (c *productConnectionResolver) TotalCountApproximate() bool { return c.approximate }
+
+// 概数の場合は総数より後にも続きがありうる
+func (c *productConnectionResolver) HasNextPage() bool {
+	return c.page.HasNextPage() || (c.approximate && len(c.nodes) == c.pageSize)
+}
+
+func (c *productConnectionResolver) Nodes() []*productResolver { return c.nodes }
+
+type userResolver struct {
+	profile model.UserProfile
+}
+
+func (u *userResolver) ID() graphqlgo.ID { return graphqlgo.ID(u.profile.UserID.String()) }
+func (u *userResolver) UserName() string { return u.profile.UserName }
+func (u *userResolver) Timezone() string { return u.profile.Timezone }
+
+type orderResolver struct {
+	order  model.Order
+	loc    *time.Location
+	loader *productLoader
+}
+
+func (o *orderResolver) ID() graphqlgo.ID {
+	return graphqlgo.ID(o.order.OrderID.String())
+}
+func (o *orderResolver) Status() string  { return o.order.ShippedStatus }
+func (o *orderResolver) Discount() int32 { return int32(o.order.Discount) }
+func (o *orderResolver) Weight() int32   { return int32(o.order.Weight) }
+func (o *orderResolver) Value() int32    { return int32(o.order.Value) }
+
+func (o *orderResolver) CreatedAt() string {
+	return o.order.CreatedAt.In(o.loc).Format(time.RFC3339)
+}
+
+func (o *orderResolver) ArrivedAt() *string {
+	if !o.order.ArrivedAt.Valid {
+		return nil
+	}
+	s := o.order.ArrivedAt.Time.In(o.loc).Format(time.RFC3339)
+	return &s
+}
+
+// 商品が削除されている場合は null
+func (o *orderResolver) Product(ctx context.Context) (*productResolver, error) {
+	p, err := o.loader.load(ctx, o.order.ProductID)
+	if err != nil || p == nil {
+		return nil, err
+	}
+	return &productResolver{product: *p}, nil
+}
+
+type orderStatsResolver struct {
+	counts map[string]int
+}
+
+func (s *orderStatsResolver) Total() int32 {
+	var total int
+	for _, n := range s.counts {
+		total += n
+	}
+	return int32(total)
+}
+
+// ステータス名の順に返す
+func (s *orderStatsResolver) ByStatus() []*statusCountResolver {
+	result := make([]*statusCountResolver, 0, len(s.counts))
+	for status, n := range s.counts {
+		result = append(result, &statusCountResolver{status: status, count: n})
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].status < result[j].status })
+	return result
+}
+
+type statusCountResolver struct {
+	status string
+	count  int
+}
+
+func (s *statusCountResolver) Status() string { return s.status }
+func (s *statusCountResolver) Count() int32   { return int32(s.count) }
+
+type productResolver struct {
+	product model.Product
+}
+
+func (p *productResolver) ID() graphqlgo.ID    { return graphqlgo.ID(p.product.ProductID.String()) }
+func (p *productResolver) Name() string        { return p.product.Name }
+func (p *productResolver) Value() int32        { return int32(p.product.Value) }
+func (p *productResolver) Weight() int32       { return int32(p.product.Weight) }
+func (p *productResolver) Image() string       { return p.product.Image }
+func (p *productResolver) Description() string { return p.product.Description }
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type AddressHandler struct {
+	AddressSvc *service.AddressService
+}
+
+func NewAddressHandler(svc *service.AddressService) *AddressHandler {
+	return &AddressHandler{AddressSvc: svc}
+}
+
+// 配送先の一覧を取得
+func (h *AddressHandler) List(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	addresses, err := h.AddressSvc.List(r.Context(), userID)
+	if err != nil {
+		logFailure(r, "Failed to list addresses", "op", "ListAddresses", "error", err)
+		writeError(w, r, err, "Failed to list addresses")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(addresses)
+}
+
+// 配送先を作成
+func (h *AddressHandler) Create(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+	req, ok := openapi.Body[model.AddressRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	address, err := h.AddressSvc.Create(r.Context(), userID, req)
+	if err != nil {
+		if errors.Is(err, service.ErrTooManyAddresses) {
+			apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeUnprocessable,
+				"Address limit reached (max "+strconv.Itoa(service.MaxAddressesPerUser)+")")
+			return
+		}
+		logFailure(r, "Failed to create address", "op", "CreateAddress", "error", err)
+		writeError(w, r, err, "Failed to create address")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(address)
+}
+
+// 配送先を更新
+func (h *AddressHandler) Update(w http.ResponseWriter, r *http.Request) {
+	userID, addressID, ok := addressTarget(w, r)
+	if !ok {
+		return
+	}
+	req, ok := openapi.Body[model.AddressRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	address, err := h.AddressSvc.Update(r.Context(), userID, addressID, req)
+	if err != nil {
+		writeError(w, r, err, "Failed to update address")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(address)
+}
+
+// 配送先を削除
+func (h *AddressHandler) Delete(w http.ResponseWriter, r *http.Request) {
+	userID, addressID, ok := addressTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.AddressSvc.Delete(r.Context(), userID, addressID); err != nil {
+		writeError(w, r, err, "Failed to delete address")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 既定の配送先を変更
+func (h *AddressHandler) SetDefault(w http.ResponseWriter, r *http.Request) {
+	userID, addressID, ok := addressTarget(w, r)
+	if !ok {
+		return
+	}
+
+	if err := h.AddressSvc.SetDefault(r.Context(), userID, addressID); err != nil {
+		writeError(w, r, err, "Failed to set default address")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ログイン中のユーザーとURLの配送先IDを取り出す
+func addressTarget(w http.ResponseWriter, r *http.Request) (model.UserID, int64, bool) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return 0, 0, false
+	}
+	addressID, err := strconv.ParseInt(chi.URLParam(r, "addressID"), 10, 64)
+	if err != nil {
+		writeBadRequest(w, r, "Invalid address ID")
+		return 0, 0, false
+	}
+	return userID, addressID, true
+}
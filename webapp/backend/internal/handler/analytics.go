@@ -0,0 +1,58 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+type AnalyticsHandler struct {
+	AnalyticsSvc *service.AnalyticsService
+}
+
+func NewAnalyticsHandler(svc *service.AnalyticsService) *AnalyticsHandler {
+	return &AnalyticsHandler{AnalyticsSvc: svc}
+}
+
+// 期間ごとの注文数と配送完了数を取得
+func (h *AnalyticsHandler) Orders(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	granularity := query.Get("granularity")
+	if granularity == "" {
+		granularity = service.GranularityHour
+	}
+	var from, to time.Time
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &from}, {"to", &to}} {
+		v := query.Get(p.name)
+		if v == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			writeBadRequest(w, r, "Query parameter '"+p.name+"' must be an RFC 3339 date-time")
+			return
+		}
+		*p.dst = t
+	}
+
+	volumes, err := h.AnalyticsSvc.OrderVolume(r.Context(), granularity, from, to)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidRange) {
+			writeBadRequest(w, r, "Invalid range")
+			return
+		}
+		logFailure(r, "Failed to fetch order volume", "op", "OrderVolume", "error", err)
+		writeError(w, r, err, "Failed to fetch order volume")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(model.OrderVolumeSeries{Granularity: granularity, Series: volumes})
+}
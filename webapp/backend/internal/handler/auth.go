@@ -3,10 +3,12 @@ package handler
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
 
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/model"
+	"backend/internal/openapi"
 	"backend/internal/service"
 )
 
@@ -20,20 +22,20 @@ func NewAuthHandler(authSvc *service.AuthService) *AuthHandler {
 
 // ログイン時にセッションを発行し、Cookieにセットする
 func (h *AuthHandler) Login(w http.ResponseWriter, r *http.Request) {
-	log.Println("-> Received request for /api/login")
+	logging.FromContext(r.Context()).Debug("Received request for /api/login")
 
-	var req model.LoginRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := openapi.Body[model.LoginRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
 		return
 	}
 
 	sessionID, expiresAt, err := h.AuthSvc.Login(r.Context(), req.UserName, req.Password)
 	if err != nil {
 		if errors.Is(err, service.ErrUserNotFound) || errors.Is(err, service.ErrInvalidPassword) {
-			http.Error(w, "Unauthorized: Invalid credentials", http.StatusUnauthorized)
+			apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized: Invalid credentials")
 		} else {
-			http.Error(w, "Internal server error", http.StatusInternalServerError)
+			writeError(w, r, err, "Internal server error")
 		}
 		return
 	}
@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/payment"
+	"backend/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type CartHandler struct {
+	CartSvc *service.CartService
+}
+
+func NewCartHandler(svc *service.CartService) *CartHandler {
+	return &CartHandler{CartSvc: svc}
+}
+
+// カートの中身を取得
+func (h *CartHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	cart, err := h.CartSvc.Get(r.Context(), userID)
+	if err != nil {
+		logFailure(r, "Failed to fetch cart", "op", "GetCart", "error", err)
+		writeError(w, r, err, "Failed to fetch cart")
+		return
+	}
+	writeCart(w, http.StatusOK, cart)
+}
+
+// 商品をカートに追加
+func (h *CartHandler) AddItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	req, ok := openapi.Body[model.AddCartItemRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	cart, err := h.CartSvc.AddItem(r.Context(), userID, req.ProductID, req.Quantity)
+	if err != nil {
+		h.writeCartError(w, r, err, "AddCartItem", "Failed to add item to cart")
+		return
+	}
+	writeCart(w, http.StatusOK, cart)
+}
+
+// カート内の商品の数量を変更 (0の場合は削除)
+func (h *CartHandler) UpdateItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+	productID, err := model.ParseProductID(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
+	}
+
+	req, ok := openapi.Body[model.UpdateCartItemRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	cart, err := h.CartSvc.UpdateItem(r.Context(), userID, productID, req.Quantity)
+	if err != nil {
+		h.writeCartError(w, r, err, "UpdateCartItem", "Failed to update cart item")
+		return
+	}
+	writeCart(w, http.StatusOK, cart)
+}
+
+// カートから商品を削除
+func (h *CartHandler) RemoveItem(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+	productID, err := model.ParseProductID(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
+	}
+
+	cart, err := h.CartSvc.RemoveItem(r.Context(), userID, productID)
+	if err != nil {
+		h.writeCartError(w, r, err, "RemoveCartItem", "Failed to remove cart item")
+		return
+	}
+	writeCart(w, http.StatusOK, cart)
+}
+
+// カートの中身で注文を確定する
+// クーポン・配送時の備考・配送先はクエリパラメータ coupon_code, note, address_id で指定する
+func (h *CartHandler) Checkout(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	query := r.URL.Query()
+	opts := model.OrderOptions{
+		CouponCode: query.Get("coupon_code"),
+		Note:       query.Get("note"),
+	}
+	if v := query.Get("address_id"); v != "" {
+		addressID, err := strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			writeBadRequest(w, r, "Invalid address ID")
+			return
+		}
+		opts.AddressID = addressID
+	}
+	orderIDs, err := h.CartSvc.Checkout(r.Context(), userID, opts)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidAddress) {
+			apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "Address not found",
+				apierror.Detail{Field: "address_id", Message: "must be one of your addresses"})
+			return
+		}
+		var couponErr *service.CouponError
+		if errors.As(err, &couponErr) {
+			apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "Coupon cannot be applied",
+				apierror.Detail{Field: "coupon_code", Message: couponErr.Reason})
+			return
+		}
+		var stockErr *service.OutOfStockError
+		if errors.As(err, &stockErr) {
+			apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Insufficient stock",
+				apierror.Detail{Field: "items", Message: fmt.Sprintf("not enough stock for product_id %v", stockErr.ProductIDs)})
+			return
+		}
+		if errors.Is(err, payment.ErrDeclined) {
+			logging.FromContext(r.Context()).Info("Payment declined", "error", err)
+			writeError(w, r, err, "Payment declined")
+			return
+		}
+		if errors.Is(err, service.ErrCartEmpty) {
+			apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "Cart is empty")
+			return
+		}
+		logFailure(r, "Failed to checkout", "op", "Checkout", "error", err)
+		writeError(w, r, err, "Failed to process order request")
+		return
+	}
+
+	// 注文作成APIと同じ形で返す
+	response := map[string]interface{}{
+		"message":   "Orders created successfully",
+		"order_ids": model.OrderIDStrings(orderIDs),
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(response)
+}
+
+func (h *CartHandler) writeCartError(w http.ResponseWriter, r *http.Request, err error, op, message string) {
+	if errors.Is(err, service.ErrInvalidCartQuantity) {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid quantity",
+			apierror.Detail{Field: "quantity", Message: "must be between 1 and " + strconv.Itoa(service.MaxCartItemQuantity) + " in total"})
+		return
+	}
+	logFailure(r, message, "op", op, "error", err)
+	writeError(w, r, err, message)
+}
+
+func writeCart(w http.ResponseWriter, status int, cart model.Cart) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(cart)
+}
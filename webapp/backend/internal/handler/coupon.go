@@ -0,0 +1,55 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+)
+
+type CouponHandler struct {
+	CouponSvc *service.CouponService
+}
+
+func NewCouponHandler(svc *service.CouponService) *CouponHandler {
+	return &CouponHandler{CouponSvc: svc}
+}
+
+// クーポンの一覧を取得
+func (h *CouponHandler) List(w http.ResponseWriter, r *http.Request) {
+	coupons, err := h.CouponSvc.List(r.Context())
+	if err != nil {
+		logFailure(r, "Failed to list coupons", "op", "ListCoupons", "error", err)
+		writeError(w, r, err, "Failed to list coupons")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(coupons)
+}
+
+// クーポンを作成
+func (h *CouponHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateCouponRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	coupon, err := h.CouponSvc.Create(r.Context(), req)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCoupon) {
+			writeBadRequest(w, r, "Invalid coupon code, discount type or value")
+			return
+		}
+		logFailure(r, "Failed to create coupon", "op", "CreateCoupon", "error", err)
+		writeError(w, r, err, "Failed to create coupon")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(coupon)
+}
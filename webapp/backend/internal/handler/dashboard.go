@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend/internal/service"
+)
+
+type DashboardHandler struct {
+	DashboardSvc *service.DashboardService
+}
+
+func NewDashboardHandler(svc *service.DashboardService) *DashboardHandler {
+	return &DashboardHandler{DashboardSvc: svc}
+}
+
+// 注文と配送の状況のサマリーを取得
+func (h *DashboardHandler) Summary(w http.ResponseWriter, r *http.Request) {
+	summary, err := h.DashboardSvc.Summary(r.Context())
+	if err != nil {
+		logFailure(r, "Failed to build dashboard summary", "op", "DashboardSummary", "error", err)
+		writeError(w, r, err, "Failed to build dashboard summary")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(summary)
+}
@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"net/http"
+
+	"backend/internal/apierror"
+	"backend/internal/logging"
+)
+
+// エラーに応じたステータスでレスポンスを返す
+// ステータスとコードの決定は apierror.FromError に集約している
+func writeError(w http.ResponseWriter, r *http.Request, err error, message string) {
+	apierror.WriteError(w, r, err, message)
+}
+
+// 処理の失敗をログに残す
+// クライアントの切断で中断した場合は障害ではないため、エラーではなく情報として残す
+func logFailure(r *http.Request, msg string, args ...any) {
+	logger := logging.FromContext(r.Context())
+	if apierror.ClientGone(r) {
+		logger.Info("Request aborted by client disconnect", append([]any{"failure", msg}, args...)...)
+		return
+	}
+	logger.Error(msg, args...)
+}
+
+func writeBadRequest(w http.ResponseWriter, r *http.Request, message string) {
+	apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, message)
+}
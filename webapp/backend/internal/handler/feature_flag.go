@@ -0,0 +1,65 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/featureflag"
+	"backend/internal/model"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type FeatureFlagHandler struct {
+	Flags *featureflag.Flags
+}
+
+func NewFeatureFlagHandler(flags *featureflag.Flags) *FeatureFlagHandler {
+	return &FeatureFlagHandler{Flags: flags}
+}
+
+// フラグの一覧を取得
+func (h *FeatureFlagHandler) List(w http.ResponseWriter, r *http.Request) {
+	flags, err := h.Flags.List(r.Context())
+	if err != nil {
+		logFailure(r, "Failed to list feature flags", "op", "ListFeatureFlags", "error", err)
+		writeError(w, r, err, "Failed to list feature flags")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(flags)
+}
+
+// フラグを切り替える
+// rollout_percent を省略した場合は対象全体(100%)になる
+func (h *FeatureFlagHandler) Update(w http.ResponseWriter, r *http.Request) {
+	var req model.UpdateFeatureFlagRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	flag := model.FeatureFlag{
+		Name:           chi.URLParam(r, "name"),
+		Enabled:        req.Enabled,
+		RolloutPercent: 100,
+	}
+	if req.RolloutPercent != nil {
+		flag.RolloutPercent = *req.RolloutPercent
+	}
+
+	if err := h.Flags.Set(r.Context(), flag); err != nil {
+		if errors.Is(err, featureflag.ErrInvalidFlag) {
+			writeBadRequest(w, r, "Invalid flag name or rollout_percent")
+			return
+		}
+		logFailure(r, "Failed to update feature flag",
+			"op", "UpdateFeatureFlag", "flag", flag.Name, "error", err)
+		writeError(w, r, err, "Failed to update feature flag")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
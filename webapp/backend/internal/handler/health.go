@@ -0,0 +1,64 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// レディネス判定に使うチェック
+// Check がエラーを返した場合、そのバックエンドにはトラフィックを流さない
+type ReadinessCheck struct {
+	Name  string
+	Check func(ctx context.Context) error
+}
+
+type HealthHandler struct {
+	checks  []ReadinessCheck
+	timeout time.Duration
+}
+
+// timeout は全チェック合計の制限時間
+func NewHealthHandler(timeout time.Duration, checks ...ReadinessCheck) *HealthHandler {
+	return &HealthHandler{checks: checks, timeout: timeout}
+}
+
+// プロセスが生きていれば常に200を返す (liveness)
+func (h *HealthHandler) Liveness(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// 全てのチェックが通れば200、1つでも失敗すれば503を返す (readiness)
+func (h *HealthHandler) Readiness(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(r.Context(), h.timeout)
+	defer cancel()
+
+	status := http.StatusOK
+	results := make(map[string]string, len(h.checks))
+	for _, c := range h.checks {
+		if err := c.Check(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			results[c.Name] = err.Error()
+			continue
+		}
+		results[c.Name] = "ok"
+	}
+
+	resp := struct {
+		Status string            `json:"status"`
+		Checks map[string]string `json:"checks"`
+	}{
+		Status: "ok",
+		Checks: results,
+	}
+	if status != http.StatusOK {
+		resp.Status = "unavailable"
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(resp)
+}
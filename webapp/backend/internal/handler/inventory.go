@@ -0,0 +1,29 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"backend/internal/service"
+)
+
+type InventoryHandler struct {
+	InventorySvc *service.InventoryService
+}
+
+func NewInventoryHandler(svc *service.InventoryService) *InventoryHandler {
+	return &InventoryHandler{InventorySvc: svc}
+}
+
+// 在庫が閾値を下回っている商品の一覧を取得
+func (h *InventoryHandler) LowStock(w http.ResponseWriter, r *http.Request) {
+	stocks, err := h.InventorySvc.ListLowStock(r.Context())
+	if err != nil {
+		logFailure(r, "Failed to list low stock products", "op", "ListLowStock", "error", err)
+		writeError(w, r, err, "Failed to list low stock products")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(stocks)
+}
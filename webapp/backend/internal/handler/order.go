@@ -1,70 +1,121 @@
 package handler
 
 import (
+	"backend/internal/apierror"
 	"backend/internal/middleware"
 	"backend/internal/model"
 	"backend/internal/service"
 	"encoding/json"
-	"log"
 	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type OrderHandler struct {
 	OrderSvc *service.OrderService
+	UserSvc  *service.UserService
 }
 
-func NewOrderHandler(svc *service.OrderService) *OrderHandler {
-	return &OrderHandler{OrderSvc: svc}
+func NewOrderHandler(svc *service.OrderService, userSvc *service.UserService) *OrderHandler {
+	return &OrderHandler{OrderSvc: svc, UserSvc: userSvc}
 }
 
-// 注文履歴一覧を取得
+// 注文履歴一覧を取得 (v1)
 func (h *OrderHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, listFormatV1)
+}
+
+// 注文履歴一覧を取得 (v2)
+// レスポンスにページ情報と次ページのカーソルを含め、日時はユーザーのタイムゾーンで返す
+func (h *OrderHandler) ListV2(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, listFormatV2)
+}
+
+func (h *OrderHandler) list(w http.ResponseWriter, r *http.Request, format listFormat) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
 		return
 	}
 
-	var req model.ListRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := listRequest(w, r, format, model.OrderListSpec)
+	if !ok {
 		return
 	}
 
-	// デフォルト値の設定
-	if req.Page <= 0 {
-		req.Page = 1
-	}
-	if req.PageSize <= 0 {
-		req.PageSize = 20
+	orders, total, err := h.OrderSvc.FetchOrders(r.Context(), userID, req)
+	if err != nil {
+		logFailure(r, "Failed to fetch orders", "op", "FetchOrders", "error", err)
+		writeError(w, r, err, "Failed to fetch orders")
+		return
 	}
-	if req.SortField == "" {
-		req.SortField = "order_id"
+
+	// v1はこれまで通りJSTの日時をタイムゾーンなし(Z)で返し、重さと価値は含めない (0)
+	if format == listFormatV1 {
+		for i := range orders {
+			orders[i].Weight, orders[i].Value = 0, 0
+			legacyTimes(&orders[i])
+		}
+	} else if !h.localize(w, r, userID, orders) {
+		return
 	}
-	if req.SortOrder == "" {
-		req.SortOrder = "desc"
+
+	writeList(w, format, orders, model.ListTotal{Count: total}, req)
+}
+
+// 注文の詳細を取得 (v2)
+func (h *OrderHandler) Get(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
 	}
-	if req.Type != "" && req.Type != "partial" && req.Type != "prefix" {
-		req.Type = "partial"
+	orderID, err := model.ParseOrderID(chi.URLParam(r, "orderID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid order ID")
+		return
 	}
-	// ページネーション用のオフセットを計算
-	req.Offset = (req.Page - 1) * req.PageSize
 
-	orders, total, err := h.OrderSvc.FetchOrders(r.Context(), userID, req)
+	order, err := h.OrderSvc.GetOrder(r.Context(), userID, orderID)
 	if err != nil {
-		log.Printf("Failed to fetch orders for user %d: %v", userID, err)
-		http.Error(w, "Failed to fetch orders", http.StatusInternalServerError)
+		writeError(w, r, err, "Order not found")
 		return
 	}
-
-	resp := struct {
-		Data  []model.Order `json:"data"`
-		Total int           `json:"total"`
-	}{
-		Data:  orders,
-		Total: total,
+	orders := []model.Order{order}
+	if !h.localize(w, r, userID, orders) {
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(orders[0])
+}
+
+// v1の日時の形式
+// UTCに揃える前はJSTの日時をそのままDBに保存し、UTCとして読み出して返していたため、同じ値になるよう9時間進める
+const legacyOffset = 9 * time.Hour
+
+func legacyTimes(order *model.Order) {
+	order.CreatedAt = order.CreatedAt.UTC().Add(legacyOffset)
+	if order.ArrivedAt.Valid {
+		order.ArrivedAt.Time = order.ArrivedAt.Time.UTC().Add(legacyOffset)
+	}
+}
+
+// 注文の日時をユーザーのタイムゾーンに変換する
+// 失敗した場合はエラーレスポンスを返して false を返す
+func (h *OrderHandler) localize(w http.ResponseWriter, r *http.Request, userID model.UserID, orders []model.Order) bool {
+	loc, err := h.UserSvc.Location(r.Context(), userID)
+	if err != nil {
+		logFailure(r, "Failed to fetch user timezone", "op", "Location", "error", err)
+		writeError(w, r, err, "Failed to fetch orders")
+		return false
+	}
+	for i := range orders {
+		orders[i].CreatedAt = orders[i].CreatedAt.In(loc)
+		if orders[i].ArrivedAt.Valid {
+			orders[i].ArrivedAt.Time = orders[i].ArrivedAt.Time.In(loc)
+		}
+	}
+	return true
 }
@@ -0,0 +1,175 @@
+package handler
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"backend/internal/apierror"
+	"backend/internal/jsonenc"
+	"backend/internal/model"
+	"backend/internal/openapi"
+)
+
+// 一覧レスポンスの形式
+type listFormat int
+
+const (
+	// v1: {data, total}
+	listFormatV1 listFormat = iota
+	// v2: {data, total, page, page_size, next_cursor}
+	listFormatV2
+)
+
+// v2の一覧レスポンス
+type page[T any] struct {
+	Data  []T `json:"data"`
+	Total int `json:"total"`
+	// true の場合、total は総数の下限 (該当件数が多く数え切らなかった)
+	TotalApproximate bool `json:"total_approximate,omitempty"`
+	Page             int  `json:"page"`
+	PageSize         int  `json:"page_size"`
+	// 次のページがない場合は null
+	NextCursor *string `json:"next_cursor"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+const cursorPrefix = "o:"
+
+// 次のページの位置を表すカーソル
+// 中身は不透明な値として扱い、クライアントはそのまま次のリクエストの cursor に渡す
+func encodeCursor(offset int) string {
+	return base64.RawURLEncoding.EncodeToString([]byte(cursorPrefix + strconv.Itoa(offset)))
+}
+
+func decodeCursor(cursor string) (int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || !strings.HasPrefix(string(raw), cursorPrefix) {
+		return 0, errInvalidCursor
+	}
+	offset, err := strconv.Atoi(strings.TrimPrefix(string(raw), cursorPrefix))
+	if err != nil || offset < 0 {
+		return 0, errInvalidCursor
+	}
+	return offset, nil
+}
+
+// カーソルが指定されていればページ番号より優先してオフセットを決める
+func applyCursor(req *model.ListRequest) error {
+	if req.Cursor == "" {
+		return nil
+	}
+	offset, err := decodeCursor(req.Cursor)
+	if err != nil {
+		return err
+	}
+	req.Offset = offset
+	req.Page = offset/req.PageSize + 1
+	return nil
+}
+
+// 検証済みのリクエストボディから一覧の条件を取り出し、既定値の補完とカーソルの適用を行う
+// v1は従来どおり不正な値を既定値に置き換え、v2は条件が不正な場合にエラーレスポンスを返して false を返す
+func listRequest(w http.ResponseWriter, r *http.Request, format listFormat, spec model.ListSpec) (model.ListRequest, bool) {
+	// ボディはルーティングで openapi.ValidateBody により検証済み
+	req, ok := openapi.Body[model.ListRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return req, false
+	}
+
+	validate := req.Validate
+	if format == listFormatV1 {
+		validate = req.ValidateV1
+	}
+	if err := validate(spec); err != nil {
+		var listErr *model.ListRequestError
+		if errors.As(err, &listErr) {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid request",
+				apierror.Detail{Field: listErr.Field, Message: listErr.Message})
+			return req, false
+		}
+		writeBadRequest(w, r, "Invalid request body")
+		return req, false
+	}
+	if err := applyCursor(&req); err != nil {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid request",
+			apierror.Detail{Field: "cursor", Message: "is invalid"})
+		return req, false
+	}
+	return req, true
+}
+
+// 一覧レスポンスを返す
+// どの形式でも総数を X-Total-Count ヘッダに入れる (概数の場合は X-Total-Count-Approximate: true も付ける)
+func writeList[T any](w http.ResponseWriter, format listFormat, items []T, total model.ListTotal, req model.ListRequest) {
+	w.Header().Set("X-Total-Count", strconv.Itoa(total.Count))
+	if total.Approximate {
+		w.Header().Set("X-Total-Count-Approximate", "true")
+	}
+	w.Header().Set("Content-Type", "application/json")
+
+	// 要素が jsonenc.Appender を実装していればリフレクションを使わずに書き出す (件数が多いと encoding/json では遅いため)
+	if format == listFormatV1 {
+		if jsonenc.Supports[T]() {
+			jsonenc.Write(w, func(dst []byte) []byte {
+				dst = append(dst, `{"data":`...)
+				dst = jsonenc.AppendSlice(dst, items)
+				dst = append(dst, `,"total":`...)
+				dst = jsonenc.AppendInt(dst, total.Count)
+				return append(dst, '}')
+			})
+			return
+		}
+		json.NewEncoder(w).Encode(struct {
+			Data  []T `json:"data"`
+			Total int `json:"total"`
+		}{Data: items, Total: total.Count})
+		return
+	}
+
+	resp := page[T]{
+		Data:             items,
+		Total:            total.Count,
+		TotalApproximate: total.Approximate,
+		Page:             req.Page,
+		PageSize:         req.PageSize,
+	}
+	if resp.Data == nil {
+		resp.Data = []T{}
+	}
+	// 概数の場合は総数より後にも続きがありうるため、ページが埋まっていれば次のカーソルを返す
+	next := req.Offset + len(items)
+	if len(items) > 0 && (next < total.Count || (total.Approximate && len(items) == req.PageSize)) {
+		cursor := encodeCursor(next)
+		resp.NextCursor = &cursor
+	}
+	if jsonenc.Supports[T]() {
+		jsonenc.Write(w, func(dst []byte) []byte {
+			dst = append(dst, `{"data":`...)
+			dst = jsonenc.AppendSlice(dst, resp.Data)
+			dst = append(dst, `,"total":`...)
+			dst = jsonenc.AppendInt(dst, resp.Total)
+			if resp.TotalApproximate {
+				dst = append(dst, `,"total_approximate":true`...)
+			}
+			dst = append(dst, `,"page":`...)
+			dst = jsonenc.AppendInt(dst, resp.Page)
+			dst = append(dst, `,"page_size":`...)
+			dst = jsonenc.AppendInt(dst, resp.PageSize)
+			dst = append(dst, `,"next_cursor":`...)
+			if resp.NextCursor == nil {
+				dst = append(dst, "null"...)
+			} else {
+				dst = jsonenc.AppendString(dst, *resp.NextCursor)
+			}
+			return append(dst, '}')
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(resp)
+}
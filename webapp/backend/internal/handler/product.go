@@ -1,16 +1,22 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/middleware"
 	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/payment"
 	"backend/internal/service"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/go-chi/chi/v5"
 )
 
 type ProductHandler struct {
@@ -21,96 +27,209 @@ func NewProductHandler(svc *service.ProductService) *ProductHandler {
 	return &ProductHandler{ProductSvc: svc}
 }
 
-// 商品一覧を取得
+// 商品一覧を取得 (v1)
 func (h *ProductHandler) List(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, listFormatV1)
+}
+
+// 商品一覧を取得 (v2)
+// レスポンスにページ情報と次ページのカーソルを含める
+// 画像と説明文は含めないため、必要な場合は Get で商品ごとに取得する
+func (h *ProductHandler) ListV2(w http.ResponseWriter, r *http.Request) {
+	h.list(w, r, listFormatV2)
+}
+
+func (h *ProductHandler) list(w http.ResponseWriter, r *http.Request, format listFormat) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found in context")
 		return
 	}
 
-	var req model.ListRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := listRequest(w, r, format, model.ProductListSpec)
+	if !ok {
 		return
 	}
+	// v1のレスポンスは従来どおり常に正確な総数を返す
+	if format == listFormatV1 {
+		req.Exact = true
+	}
 
-	if req.Page <= 0 {
-		req.Page = 1
+	// 商品が変わっていなければ一覧を取得せずに304を返す
+	if etag := h.ProductSvc.ListETag(r.Context(), req); etag != "" {
+		w.Header().Set("ETag", etag)
+		w.Header().Set("Cache-Control", "private, no-cache")
+		if etagMatches(r, etag) {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
 	}
-	if req.PageSize <= 0 {
-		req.PageSize = 20
+
+	if format == listFormatV1 {
+		products, total, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
+		if err != nil {
+			logFailure(r, "Failed to fetch products", "op", "FetchProducts", "error", err)
+			writeError(w, r, err, "Failed to fetch products")
+			return
+		}
+		writeList(w, format, products, total, req)
+		return
 	}
-	if req.SortField == "" {
-		req.SortField = "product_id"
+
+	products, total, err := h.ProductSvc.FetchProductSummaries(r.Context(), req)
+	if err != nil {
+		logFailure(r, "Failed to fetch products", "op", "FetchProductSummaries", "error", err)
+		writeError(w, r, err, "Failed to fetch products")
+		return
 	}
-	if req.SortOrder == "" {
-		req.SortOrder = "asc"
+	writeList(w, format, products, total, req)
+}
+
+// 商品の詳細を取得 (v2)
+// 一覧に含めない画像と説明文を返す
+func (h *ProductHandler) Get(w http.ResponseWriter, r *http.Request) {
+	productID, err := model.ParseProductID(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
 	}
-	req.Offset = (req.Page - 1) * req.PageSize
 
-	products, total, err := h.ProductSvc.FetchProducts(r.Context(), userID, req)
+	product, err := h.ProductSvc.GetProduct(r.Context(), productID)
 	if err != nil {
-		log.Printf("Failed to fetch products for user %d: %v", userID, err)
-		http.Error(w, "Failed to fetch products", http.StatusInternalServerError)
+		writeError(w, r, err, "Product not found")
 		return
 	}
 
-	resp := struct {
-		Data  []model.Product `json:"data"`
-		Total int             `json:"total"`
-	}{
-		Data:  products,
-		Total: total,
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(product)
+}
+
+// 一度に指定できる商品IDの数
+const maxBatchProductIDs = 100
+
+// カンマ区切りで指定した商品をまとめて取得 (指定した順に返す。存在しない商品は含めない)
+func (h *ProductHandler) GetBatch(w http.ResponseWriter, r *http.Request) {
+	// ids の有無はルーティングで openapi.ValidateQuery により検証済み
+	raw := strings.Split(r.URL.Query().Get("ids"), ",")
+	if len(raw) > maxBatchProductIDs {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid request",
+			apierror.Detail{Field: "ids", Message: fmt.Sprintf("must contain at most %d IDs", maxBatchProductIDs)})
+		return
+	}
+	productIDs := make([]model.ProductID, len(raw))
+	for i, s := range raw {
+		id, err := model.ParseProductID(strings.TrimSpace(s))
+		if err != nil || id <= 0 {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid request",
+				apierror.Detail{Field: "ids", Message: "must be a comma-separated list of product IDs"})
+			return
+		}
+		productIDs[i] = id
+	}
+
+	products, err := h.ProductSvc.GetProductsInOrder(r.Context(), productIDs)
+	if err != nil {
+		writeError(w, r, err, "Failed to get products")
+		return
 	}
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(resp)
+	json.NewEncoder(w).Encode(products)
+}
+
+// 商品データを直接変更した後に呼び出し、一覧のETagと総数・重さと価値のキャッシュを無効にする
+func (h *ProductHandler) InvalidateCatalog(w http.ResponseWriter, r *http.Request) {
+	version, err := h.ProductSvc.BumpCatalogVersion(r.Context())
+	if err != nil {
+		logFailure(r, "Failed to bump catalog version", "op", "InvalidateCatalog", "error", err)
+		writeError(w, r, err, "Failed to invalidate catalog")
+		return
+	}
+	logging.FromContext(r.Context()).Info("Catalog version bumped", "op", "InvalidateCatalog", "catalog_version", version)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int64{"catalog_version": version})
 }
 
 // 注文を作成
 func (h *ProductHandler) CreateOrders(w http.ResponseWriter, r *http.Request) {
 	userID, ok := middleware.GetUserFromContext(r.Context())
 	if !ok {
-		http.Error(w, "User not found in context", http.StatusInternalServerError)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found in context")
 		return
 	}
 
-	var req model.CreateOrderRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := openapi.Body[model.CreateOrderRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
 		return
 	}
 
-	insertedOrderIDs, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items)
+	insertedOrderIDs, duplicate, err := h.ProductSvc.CreateOrders(r.Context(), userID, req.Items, model.OrderOptions{
+		CouponCode: req.CouponCode,
+		Note:       req.Note,
+		AddressID:  req.AddressID,
+	})
 	if err != nil {
-		log.Printf("Failed to create orders: %v", err)
-		http.Error(w, "Failed to process order request", http.StatusInternalServerError)
+		var dupErr *service.DuplicateOrderError
+		if errors.As(err, &dupErr) {
+			apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "The same order was placed moments ago")
+			return
+		}
+		if errors.Is(err, service.ErrInvalidAddress) {
+			apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "Address not found",
+				apierror.Detail{Field: "address_id", Message: "must be one of your addresses"})
+			return
+		}
+		var couponErr *service.CouponError
+		if errors.As(err, &couponErr) {
+			apierror.Write(w, r, http.StatusUnprocessableEntity, apierror.CodeUnprocessable, "Coupon cannot be applied",
+				apierror.Detail{Field: "coupon_code", Message: couponErr.Reason})
+			return
+		}
+		var stockErr *service.OutOfStockError
+		if errors.As(err, &stockErr) {
+			apierror.Write(w, r, http.StatusConflict, apierror.CodeConflict, "Insufficient stock",
+				apierror.Detail{Field: "items", Message: fmt.Sprintf("not enough stock for product_id %v", stockErr.ProductIDs)})
+			return
+		}
+		if errors.Is(err, payment.ErrDeclined) {
+			logging.FromContext(r.Context()).Info("Payment declined", "error", err)
+			writeError(w, r, err, "Payment declined")
+			return
+		}
+		logFailure(r, "Failed to create orders", "op", "CreateOrders", "error", err)
+		writeError(w, r, err, "Failed to process order request")
 		return
 	}
 
 	response := map[string]interface{}{
 		"message":   "Orders created successfully",
-		"order_ids": insertedOrderIDs,
+		"order_ids": model.OrderIDStrings(insertedOrderIDs),
 	}
 	w.Header().Set("Content-Type", "application/json")
+	// 直前に同じ注文があったが、設定により作成した
+	if duplicate {
+		w.Header().Set("X-Possible-Duplicate", "true")
+	}
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(response)
 }
 
 func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
-	fmt.Printf("画像リクエスト受信: %s\n", r.URL.String())
+	logger := logging.FromContext(r.Context()).With("op", "GetImage")
+	logger.Debug("画像リクエスト受信", "url", r.URL.String())
 	imagePath := r.URL.Query().Get("path")
 	if imagePath == "" {
-		fmt.Println("画像パスが空です")
-		http.Error(w, "画像パスが指定されていません", http.StatusBadRequest)
+		logger.Info("画像パスが空です")
+		writeBadRequest(w, r, "画像パスが指定されていません")
 		return
 	}
 
 	imagePath = filepath.Clean(imagePath)
 	if filepath.IsAbs(imagePath) || strings.Contains(imagePath, "..") {
-		fmt.Printf("無効なパス: %s\n", imagePath)
-		http.Error(w, "無効なパスです", http.StatusBadRequest)
+		logger.Info("無効なパス", "path", imagePath)
+		writeBadRequest(w, r, "無効なパスです")
 		return
 	}
 
@@ -118,8 +237,8 @@ func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
 	fullPath := filepath.Join(baseImageDir, imagePath)
 
 	if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-		fmt.Printf("画像ファイルが見つかりません: %s\n", fullPath)
-		http.Error(w, "画像が見つかりません", http.StatusNotFound)
+		logger.Info("画像ファイルが見つかりません", "path", fullPath)
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "画像が見つかりません")
 		return
 	}
 
@@ -144,8 +263,8 @@ func (h *ProductHandler) GetImage(w http.ResponseWriter, r *http.Request) {
 
 	data, err := os.ReadFile(fullPath)
 	if err != nil {
-		fmt.Printf("画像ファイルの読み込みに失敗: %s\n", fullPath)
-		http.Error(w, "画像の読み込みに失敗しました", http.StatusInternalServerError)
+		logger.Error("画像ファイルの読み込みに失敗", "path", fullPath, "error", err)
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "画像の読み込みに失敗しました")
 		return
 	}
 
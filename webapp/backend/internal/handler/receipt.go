@@ -0,0 +1,133 @@
+package handler
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// 印刷用の領収書
+var receiptTemplate = template.Must(template.New("receipt").Funcs(template.FuncMap{
+	"yen": formatYen,
+}).Parse(`<!DOCTYPE html>
+<html lang="ja">
+<head>
+<meta charset="utf-8">
+<title>領収書 注文番号 {{.Receipt.OrderID}}</title>
+<style>
+body { font-family: sans-serif; max-width: 40em; margin: 2em auto; color: #222; }
+h1 { text-align: center; letter-spacing: 0.5em; }
+table { width: 100%; border-collapse: collapse; margin: 1em 0; }
+th, td { padding: 0.4em; border-bottom: 1px solid #ccc; text-align: left; }
+td.amount { text-align: right; }
+tr.total td { font-weight: bold; border-top: 2px solid #222; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>領収書</h1>
+<table>
+<tr><th>注文番号</th><td>{{.Receipt.OrderID}}</td></tr>
+<tr><th>注文日時</th><td>{{.OrderedAt}}</td></tr>
+<tr><th>発送日時</th><td>{{with .DispatchedAt}}{{.}}{{else}}未発送{{end}}</td></tr>
+<tr><th>配達日時</th><td>{{with .DeliveredAt}}{{.}}{{else}}未配達{{end}}</td></tr>
+<tr><th>配送状況</th><td>{{.Receipt.ShippedStatus}}</td></tr>
+</table>
+<table>
+<tr><th>商品</th><td class="amount">金額</td></tr>
+<tr><td>{{.Receipt.ProductName}}</td><td class="amount">{{yen .Receipt.Price}}</td></tr>
+{{- if .Receipt.Discount}}
+<tr><td>割引</td><td class="amount">-{{yen .Receipt.Discount}}</td></tr>
+{{- end}}
+<tr class="total"><td>合計</td><td class="amount">{{yen .Receipt.Total}}</td></tr>
+</table>
+<p>発行日時: {{.IssuedAt}}</p>
+</body>
+</html>
+`))
+
+type receiptView struct {
+	Receipt      model.Receipt
+	OrderedAt    string
+	DispatchedAt string
+	DeliveredAt  string
+	IssuedAt     string
+}
+
+const receiptTimeLayout = "2006-01-02 15:04 MST"
+
+// 注文の領収書を印刷用のHTMLで取得 (v2)
+// 日時はユーザーのタイムゾーンで表示する
+func (h *OrderHandler) Receipt(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+	orderID, err := model.ParseOrderID(chi.URLParam(r, "orderID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid order ID")
+		return
+	}
+
+	receipt, err := h.OrderSvc.GetReceipt(r.Context(), userID, orderID)
+	if err != nil {
+		writeError(w, r, err, "Order not found")
+		return
+	}
+	loc, err := h.UserSvc.Location(r.Context(), userID)
+	if err != nil {
+		logFailure(r, "Failed to fetch user timezone", "op", "Location", "error", err)
+		writeError(w, r, err, "Failed to generate receipt")
+		return
+	}
+
+	view := receiptView{
+		Receipt:   receipt,
+		OrderedAt: receipt.OrderedAt.In(loc).Format(receiptTimeLayout),
+		IssuedAt:  time.Now().In(loc).Format(receiptTimeLayout),
+	}
+	if receipt.DispatchedAt != nil {
+		view.DispatchedAt = receipt.DispatchedAt.In(loc).Format(receiptTimeLayout)
+	}
+	if receipt.DeliveredAt != nil {
+		view.DeliveredAt = receipt.DeliveredAt.In(loc).Format(receiptTimeLayout)
+	}
+
+	// 途中で失敗した場合にエラーレスポンスを返せるよう、書き出す前に全体を生成する
+	var buf bytes.Buffer
+	if err := receiptTemplate.Execute(&buf, view); err != nil {
+		logFailure(r, "Failed to render receipt", "op", "Receipt", "error", err)
+		writeError(w, r, err, "Failed to generate receipt")
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "private, no-store")
+	_, _ = w.Write(buf.Bytes())
+}
+
+// 1234567 -> "¥1,234,567"
+func formatYen(amount int) string {
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	digits := strconv.Itoa(amount)
+	var b []byte
+	for i := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b = append(b, ',')
+		}
+		b = append(b, digits[i])
+	}
+	return sign + "¥" + string(b)
+}
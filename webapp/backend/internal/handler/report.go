@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"backend/internal/service"
+)
+
+// SLAレポートの既定の集計日数
+const defaultReportDays = 7
+
+type ReportHandler struct {
+	ReportSvc *service.ReportService
+}
+
+func NewReportHandler(svc *service.ReportService) *ReportHandler {
+	return &ReportHandler{ReportSvc: svc}
+}
+
+// 配送SLAを超えた注文のレポートを取得
+func (h *ReportHandler) SLA(w http.ResponseWriter, r *http.Request) {
+	days := defaultReportDays
+	if v := r.URL.Query().Get("days"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			writeBadRequest(w, r, "Query parameter 'days' must be an integer")
+			return
+		}
+		days = n
+	}
+
+	report, err := h.ReportSvc.SLAReport(r.Context(), days)
+	if err != nil {
+		logFailure(r, "Failed to build SLA report", "op", "SLAReport", "error", err)
+		writeError(w, r, err, "Failed to build SLA report")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(report)
+}
@@ -1,10 +1,13 @@
 package handler
 
 import (
+	"backend/internal/apierror"
+	"backend/internal/logging"
 	"backend/internal/model"
+	"backend/internal/openapi"
 	"backend/internal/service"
 	"encoding/json"
-	"log"
+	"fmt"
 	"net/http"
 	"strconv"
 )
@@ -20,22 +23,23 @@ func NewRobotHandler(robotSvc *service.RobotService) *RobotHandler {
 // 配送計画を取得
 func (h *RobotHandler) GetDeliveryPlan(w http.ResponseWriter, r *http.Request) {
 	robotID := "robot-001"
+	ctx := logging.With(r.Context(), "robot_id", robotID)
 
 	capacityStr := r.URL.Query().Get("capacity")
 	if capacityStr == "" {
-		http.Error(w, "Query parameter 'capacity' is required", http.StatusBadRequest)
+		writeBadRequest(w, r, "Query parameter 'capacity' is required")
 		return
 	}
 	capacity, err := strconv.Atoi(capacityStr)
 	if err != nil {
-		http.Error(w, "Query parameter 'capacity' must be an integer", http.StatusBadRequest)
+		writeBadRequest(w, r, "Query parameter 'capacity' must be an integer")
 		return
 	}
 
-	plan, err := h.RobotSvc.GenerateDeliveryPlan(r.Context(), robotID, capacity)
+	plan, err := h.RobotSvc.GenerateDeliveryPlan(ctx, robotID, capacity)
 	if err != nil {
-		log.Printf("Failed to generate delivery plan: %v", err)
-		http.Error(w, "Failed to create delivery plan", http.StatusInternalServerError)
+		logFailure(r.WithContext(ctx), "Failed to generate delivery plan", "op", "GenerateDeliveryPlan", "error", err)
+		writeError(w, r, err, "Failed to create delivery plan")
 		return
 	}
 
@@ -45,19 +49,95 @@ func (h *RobotHandler) GetDeliveryPlan(w http.ResponseWriter, r *http.Request) {
 
 // 配送完了時に注文ステータスを更新
 func (h *RobotHandler) UpdateOrderStatus(w http.ResponseWriter, r *http.Request) {
-	var req model.UpdateOrderStatusRequest
-	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	req, ok := openapi.Body[model.UpdateOrderStatusRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
 		return
 	}
 
 	err := h.RobotSvc.UpdateOrderStatus(r.Context(), req.OrderID, req.NewStatus)
 	if err != nil {
-		log.Printf("Failed to update order status for order %d: %v", req.OrderID, err)
-		http.Error(w, "Failed to update order status", http.StatusInternalServerError)
+		logFailure(r, "Failed to update order status",
+			"op", "UpdateOrderStatus", "order_id", req.OrderID, "error", err)
+		writeError(w, r, err, "Failed to update order status")
 		return
 	}
 
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Order status updated"))
 }
+
+// 一度に戻せる注文の数
+const maxReturnOrders = 1000
+
+// 配送できなかった注文を理由とともに戻す
+func (h *RobotHandler) ReturnOrders(w http.ResponseWriter, r *http.Request) {
+	robotID := "robot-001"
+	ctx := logging.With(r.Context(), "robot_id", robotID)
+
+	req, ok := openapi.Body[model.ReturnOrdersRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+	if len(req.Orders) > maxReturnOrders {
+		apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid request",
+			apierror.Detail{Field: "orders", Message: fmt.Sprintf("must contain at most %d orders", maxReturnOrders)})
+		return
+	}
+
+	result, err := h.RobotSvc.ReturnOrders(ctx, robotID, req.Orders)
+	if err != nil {
+		logFailure(r.WithContext(ctx), "Failed to return orders", "op", "ReturnOrders", "orders", len(req.Orders), "error", err)
+		writeError(w, r, err, "Failed to return orders")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(result)
+}
+
+// 配送待ちの注文を NDJSON (1行に1件) で注文ID順に返す
+// after に前回受け取った最後の注文IDを指定すると続きから取得できる
+// 送信の途中でエラーになった場合は接続を切るため、クライアントは受け取れた最後の注文IDから再開する
+func (h *RobotHandler) StreamShippingOrders(w http.ResponseWriter, r *http.Request) {
+	var afterID model.OrderID
+	if v := r.URL.Query().Get("after"); v != "" {
+		id, err := model.ParseOrderID(v)
+		if err != nil || id < 0 {
+			writeBadRequest(w, r, "Query parameter 'after' must be a non-negative integer")
+			return
+		}
+		afterID = id
+	}
+	zone := r.URL.Query().Get("zone")
+
+	rc := http.NewResponseController(w)
+	enc := json.NewEncoder(w)
+	var sent int
+	err := h.RobotSvc.EachShippingCandidate(r.Context(), afterID, zone, func(page []model.ShippingCandidate) error {
+		if sent == 0 {
+			w.Header().Set("Content-Type", "application/x-ndjson")
+		}
+		for _, c := range page {
+			if err := enc.Encode(c); err != nil {
+				return err
+			}
+		}
+		sent += len(page)
+		return rc.Flush()
+	})
+	if err != nil {
+		logFailure(r, "Failed to stream shipping orders",
+			"op", "StreamShippingOrders", "sent", sent, "error", err)
+		if sent == 0 {
+			writeError(w, r, err, "Failed to fetch shipping orders")
+			return
+		}
+		panic(http.ErrAbortHandler)
+	}
+	if sent == 0 {
+		w.Header().Set("Content-Type", "application/x-ndjson")
+		w.WriteHeader(http.StatusOK)
+	}
+}
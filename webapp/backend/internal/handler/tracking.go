@@ -0,0 +1,85 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type TrackingHandler struct {
+	TrackingSvc *service.TrackingService
+}
+
+func NewTrackingHandler(svc *service.TrackingService) *TrackingHandler {
+	return &TrackingHandler{TrackingSvc: svc}
+}
+
+// トークンから配送状況を取得 (ログイン不要)
+func (h *TrackingHandler) Track(w http.ResponseWriter, r *http.Request) {
+	info, err := h.TrackingSvc.Track(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		writeError(w, r, err, "Tracking information not found")
+		return
+	}
+
+	// 配送状況は変わり続けるため共有キャッシュには載せない
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(info)
+}
+
+// 自分の注文の追跡トークンを取得
+func (h *TrackingHandler) OrderTracking(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+	orderID, err := model.ParseOrderID(chi.URLParam(r, "orderID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid order ID")
+		return
+	}
+
+	token, err := h.TrackingSvc.TokenForOrder(r.Context(), userID, orderID)
+	if err != nil {
+		writeError(w, r, err, "Tracking information not found")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"token": token,
+		"url":   "/api/track/" + token,
+	})
+}
+
+// ロボットの現在位置を報告
+func (h *TrackingHandler) ReportLocation(w http.ResponseWriter, r *http.Request) {
+	robotID := "robot-001"
+	req, ok := openapi.Body[model.ReportLocationRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	if err := h.TrackingSvc.ReportLocation(r.Context(), robotID, req.Latitude, req.Longitude); err != nil {
+		if errors.Is(err, service.ErrInvalidLocation) {
+			writeBadRequest(w, r, "latitude/longitude out of range")
+			return
+		}
+		logFailure(r, "Failed to save robot location", "op", "ReportLocation", "robot_id", robotID, "error", err)
+		writeError(w, r, err, "Failed to save robot location")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,115 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/apierror"
+	"backend/internal/middleware"
+	"backend/internal/model"
+	"backend/internal/openapi"
+	"backend/internal/service"
+)
+
+type UserHandler struct {
+	UserSvc *service.UserService
+}
+
+func NewUserHandler(svc *service.UserService) *UserHandler {
+	return &UserHandler{UserSvc: svc}
+}
+
+// ログイン中のユーザー情報を取得
+func (h *UserHandler) Me(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	profile, err := h.UserSvc.Profile(r.Context(), userID)
+	if err != nil {
+		logFailure(r, "Failed to fetch user", "op", "Profile", "error", err)
+		writeError(w, r, err, "Failed to fetch user")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(profile)
+}
+
+// 表示用のタイムゾーンを更新
+func (h *UserHandler) UpdateTimezone(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	req, ok := openapi.Body[model.UpdateTimezoneRequest](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	if err := h.UserSvc.UpdateTimezone(r.Context(), userID, req.Timezone); err != nil {
+		if errors.Is(err, service.ErrInvalidTimezone) {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Unknown timezone",
+				apierror.Detail{Field: "timezone", Message: "must be an IANA time zone name"})
+			return
+		}
+		logFailure(r, "Failed to update timezone", "op", "UpdateTimezone", "error", err)
+		writeError(w, r, err, "Failed to update timezone")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// 通知設定を取得
+func (h *UserHandler) NotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	prefs, err := h.UserSvc.NotificationPreferences(r.Context(), userID)
+	if err != nil {
+		logFailure(r, "Failed to fetch notification preferences", "op", "NotificationPreferences", "error", err)
+		writeError(w, r, err, "Failed to fetch notification preferences")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(prefs)
+}
+
+// 通知設定を更新
+func (h *UserHandler) UpdateNotificationPreferences(w http.ResponseWriter, r *http.Request) {
+	userID, ok := middleware.GetUserFromContext(r.Context())
+	if !ok {
+		apierror.Write(w, r, http.StatusInternalServerError, apierror.CodeInternal, "User not found")
+		return
+	}
+
+	req, ok := openapi.Body[model.NotificationPreferences](r.Context())
+	if !ok {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	if err := h.UserSvc.UpdateNotificationPreferences(r.Context(), userID, req); err != nil {
+		if errors.Is(err, service.ErrInvalidEmail) {
+			apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid email address",
+				apierror.Detail{Field: "email", Message: "must be a valid email address"})
+			return
+		}
+		logFailure(r, "Failed to update notification preferences", "op", "UpdateNotificationPreferences", "error", err)
+		writeError(w, r, err, "Failed to update notification preferences")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"backend/internal/model"
+	"backend/internal/service"
+
+	"github.com/go-chi/chi/v5"
+)
+
+type WarehouseHandler struct {
+	WarehouseSvc *service.WarehouseService
+}
+
+func NewWarehouseHandler(svc *service.WarehouseService) *WarehouseHandler {
+	return &WarehouseHandler{WarehouseSvc: svc}
+}
+
+// 倉庫の一覧を取得
+func (h *WarehouseHandler) List(w http.ResponseWriter, r *http.Request) {
+	warehouses, err := h.WarehouseSvc.List(r.Context())
+	if err != nil {
+		logFailure(r, "Failed to list warehouses", "op", "ListWarehouses", "error", err)
+		writeError(w, r, err, "Failed to list warehouses")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(warehouses)
+}
+
+// 倉庫を作成
+func (h *WarehouseHandler) Create(w http.ResponseWriter, r *http.Request) {
+	var req model.CreateWarehouseRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	warehouse, err := h.WarehouseSvc.Create(r.Context(), req.Code, req.Name)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidWarehouse) {
+			writeBadRequest(w, r, "Invalid warehouse code or name")
+			return
+		}
+		logFailure(r, "Failed to create warehouse", "op", "CreateWarehouse", "error", err)
+		writeError(w, r, err, "Failed to create warehouse")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusCreated)
+	json.NewEncoder(w).Encode(warehouse)
+}
+
+// 倉庫における商品の在庫数を設定
+func (h *WarehouseHandler) SetStock(w http.ResponseWriter, r *http.Request) {
+	productID, err := model.ParseProductID(chi.URLParam(r, "productID"))
+	if err != nil {
+		writeBadRequest(w, r, "Invalid product ID")
+		return
+	}
+	var req model.UpdateStockRequest
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		writeBadRequest(w, r, "Invalid request body")
+		return
+	}
+
+	if err := h.WarehouseSvc.SetStock(r.Context(), chi.URLParam(r, "code"), productID, req.Quantity); err != nil {
+		if errors.Is(err, service.ErrInvalidWarehouse) {
+			writeBadRequest(w, r, "quantity must not be negative")
+			return
+		}
+		logFailure(r, "Failed to set stock", "op", "SetStock", "error", err)
+		writeError(w, r, err, "Failed to set stock")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,144 @@
+// 一覧レスポンスなど件数の多いJSONを、リフレクションを使わずに書き出すための補助
+// 出力は encoding/json (json.NewEncoder の既定の設定、go.mod の toolchain の版) と1バイトも違わないようにすること
+package jsonenc
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+// encoding/json と同じJSONを dst に追記できる型
+type Appender interface {
+	AppendJSON(dst []byte) []byte
+}
+
+var bufPool = sync.Pool{
+	New: func() any {
+		b := make([]byte, 0, 64<<10)
+		return &b
+	},
+}
+
+// 大きすぎるバッファはプールに戻さない
+const maxPooledBytes = 1 << 20
+
+// fn で組み立てたJSONを末尾に改行を付けて w に書き出す (json.Encoder.Encode と同じ形)
+// 組み立て用のバッファはリクエスト間で使い回す
+func Write(w io.Writer, fn func(dst []byte) []byte) error {
+	bp := bufPool.Get().(*[]byte)
+	b := fn((*bp)[:0])
+	b = append(b, '\n')
+	_, err := w.Write(b)
+	if cap(b) <= maxPooledBytes {
+		*bp = b[:0]
+		bufPool.Put(bp)
+	}
+	return err
+}
+
+// T が Appender を実装しているか (ポインタのメソッドを含む)
+func Supports[T any]() bool {
+	_, ok := any(new(T)).(Appender)
+	return ok
+}
+
+// 要素ごとに AppendJSON を呼んで配列を追記する。T は Supports[T]() を満たすこと
+// nil のスライスは encoding/json と同じく null になる
+func AppendSlice[T any](dst []byte, items []T) []byte {
+	if items == nil {
+		return append(dst, "null"...)
+	}
+	dst = append(dst, '[')
+	for i := range items {
+		if i > 0 {
+			dst = append(dst, ',')
+		}
+		// 要素のコピーをインターフェースに入れると1件ごとに確保が起きるため、ポインタで呼ぶ
+		dst = any(&items[i]).(Appender).AppendJSON(dst)
+	}
+	return append(dst, ']')
+}
+
+func AppendInt[I ~int | ~int64](dst []byte, v I) []byte {
+	return strconv.AppendInt(dst, int64(v), 10)
+}
+
+func AppendBool(dst []byte, v bool) []byte {
+	return strconv.AppendBool(dst, v)
+}
+
+// time.Time.MarshalJSON と同じ形式
+func AppendTime(dst []byte, t time.Time) []byte {
+	dst = append(dst, '"')
+	dst = t.AppendFormat(dst, time.RFC3339Nano)
+	return append(dst, '"')
+}
+
+const hex = "0123456789abcdef"
+
+// 不正なUTF-8のバイトの書き出し方
+// encoding/json の版によって "\ufffd" とエスケープする場合とU+FFFDをそのまま書く場合があるため、実際の出力に合わせる
+var invalidUTF8 = func() []byte {
+	b, err := json.Marshal("\xff")
+	if err != nil || len(b) < 2 {
+		return []byte(`\ufffd`)
+	}
+	return b[1 : len(b)-1]
+}()
+
+// encoding/json の文字列のエスケープ (HTMLの特殊文字もエスケープする既定の設定) と同じ
+func AppendString(dst []byte, s string) []byte {
+	dst = append(dst, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if b := s[i]; b < utf8.RuneSelf {
+			if b >= 0x20 && b != '"' && b != '\\' && b != '<' && b != '>' && b != '&' {
+				i++
+				continue
+			}
+			dst = append(dst, s[start:i]...)
+			switch b {
+			case '\\', '"':
+				dst = append(dst, '\\', b)
+			case '\b':
+				dst = append(dst, '\\', 'b')
+			case '\f':
+				dst = append(dst, '\\', 'f')
+			case '\n':
+				dst = append(dst, '\\', 'n')
+			case '\r':
+				dst = append(dst, '\\', 'r')
+			case '\t':
+				dst = append(dst, '\\', 't')
+			default:
+				dst = append(dst, '\\', 'u', '0', '0', hex[b>>4], hex[b&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		c, size := utf8.DecodeRuneInString(s[i:])
+		if c == utf8.RuneError && size == 1 {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, invalidUTF8...)
+			i += size
+			start = i
+			continue
+		}
+		// U+2028 と U+2029 はJavaScriptの文字列に含められないためエスケープする
+		if c == '\u2028' || c == '\u2029' {
+			dst = append(dst, s[start:i]...)
+			dst = append(dst, '\\', 'u', '2', '0', '2', hex[c&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	dst = append(dst, s[start:]...)
+	return append(dst, '"')
+}
@@ -0,0 +1,190 @@
+package metrics
+
+import (
+	"database/sql"
+	"net/http"
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+var (
+	// ルート・ステータスごとのHTTPリクエスト数
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_total",
+		Help: "Number of HTTP requests by method, route and status.",
+	}, []string{"method", "route", "status"})
+
+	// ルートごとのHTTPレイテンシ
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_request_duration_seconds",
+		Help:    "HTTP request latency by method and route.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"method", "route"})
+
+	// ルートごとのレスポンスボディのサイズ
+	HTTPResponseSize = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_response_size_bytes",
+		Help:    "HTTP response body size by method and route.",
+		Buckets: prometheus.ExponentialBuckets(256, 4, 8),
+	}, []string{"method", "route"})
+
+	// 処理中にクライアントが切断したリクエスト数
+	HTTPClientDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_client_disconnects_total",
+		Help: "HTTP requests whose client disconnected before the response completed, by method and route.",
+	}, []string{"method", "route"})
+
+	// 同時実行数制限の対象となるルートグループごとの処理中リクエスト数
+	HTTPInflight = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "http_inflight_requests",
+		Help: "In-flight HTTP requests by route group.",
+	}, []string{"group"})
+
+	HTTPShed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_requests_shed_total",
+		Help: "HTTP requests rejected by the concurrency limiter, by route group.",
+	}, []string{"group"})
+
+	// DBTX経由のクエリレイテンシ
+	DBQueryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "db_query_duration_seconds",
+		Help:    "Database query latency by operation and outcome.",
+		Buckets: []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10},
+	}, []string{"op", "status"})
+
+	// DBのサーキットブレーカーの状態 (0: closed, 1: half-open, 2: open)
+	DBCircuitState = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "db_circuit_breaker_state",
+		Help: "Database circuit breaker state (0=closed, 1=half-open, 2=open).",
+	})
+
+	DBCircuitRejections = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "db_circuit_breaker_rejections_total",
+		Help: "Queries rejected because the database circuit breaker was open.",
+	})
+
+	// コミットされたドメインイベントの数
+	EventsPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "domain_events_published_total",
+		Help: "Domain events published on the in-process bus, by type.",
+	}, []string{"type"})
+
+	// 期限切れで削除したセッションの数
+	SessionsPurged = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "sessions_purged_total",
+		Help: "Expired sessions deleted by the session cleanup job.",
+	})
+
+	// まとめて書き込んだ注文リクエストの数 (1トランザクションあたり)
+	OrderWriteBatchSize = promauto.NewHistogram(prometheus.HistogramOpts{
+		Name:    "order_write_batch_requests",
+		Help:    "Order creation requests written in one batched transaction.",
+		Buckets: prometheus.ExponentialBuckets(1, 2, 10),
+	})
+
+	// まとめて書き込めず、その場で作成した注文リクエストの数 (reason: buffer_full|batch_failed)
+	OrderWriteFallbacks = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_write_fallbacks_total",
+		Help: "Order creation requests written synchronously instead of batched, by reason.",
+	}, []string{"reason"})
+
+	// 二重送信とみなした注文リクエストの数 (action: reject|flag)
+	DuplicateOrders = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "duplicate_orders_total",
+		Help: "Order requests detected as duplicates of a recent identical order, by action taken.",
+	}, []string{"action"})
+
+	// ロボットが配送できずに戻した注文の数 (outcome: requeued|failed)
+	OrderReturns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "order_returns_total",
+		Help: "Orders returned by robots as undeliverable, by reason and resulting outcome.",
+	}, []string{"reason", "outcome"})
+
+	// キャッシュのヒット・ミス数
+	CacheRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_requests_total",
+		Help: "Cache lookups by cache name and result (hit or miss).",
+	}, []string{"cache", "result"})
+
+	// メモリキャッシュから追い出したエントリの数 (reason: capacity|expired)
+	CacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "cache_evictions_total",
+		Help: "Entries evicted from in-memory caches, by cache name and reason (capacity or expired).",
+	}, []string{"cache", "reason"})
+
+	// バックグラウンドジョブの実行回数 (result: success|error|panic|skipped)
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "job_runs_total",
+		Help: "Background job runs by job name and result.",
+	}, []string{"job", "result"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "job_duration_seconds",
+		Help:    "Background job run duration.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"job"})
+
+	JobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "job_last_success_timestamp_seconds",
+		Help: "Unix time of the last successful run of each background job.",
+	}, []string{"job"})
+)
+
+// キャッシュヒットを記録する
+func CacheHit(cache string) {
+	CacheRequests.WithLabelValues(cache, "hit").Inc()
+}
+
+// キャッシュミスを記録する
+func CacheMiss(cache string) {
+	CacheRequests.WithLabelValues(cache, "miss").Inc()
+}
+
+// キャッシュごとのエントリ数。スクレイプのたびに登録された関数で数える
+var cacheSizes = &cacheSizeCollector{
+	desc:  prometheus.NewDesc("cache_entries", "Entries currently held by each in-memory cache.", []string{"cache"}, nil),
+	funcs: make(map[string]func() int),
+}
+
+func init() {
+	prometheus.MustRegister(cacheSizes)
+}
+
+// name のキャッシュのエントリ数を返す関数を登録する (同じ名前で登録し直した場合は置き換える)
+func SetCacheSizeFunc(name string, fn func() int) {
+	cacheSizes.mu.Lock()
+	defer cacheSizes.mu.Unlock()
+	cacheSizes.funcs[name] = fn
+}
+
+type cacheSizeCollector struct {
+	desc  *prometheus.Desc
+	mu    sync.Mutex
+	funcs map[string]func() int
+}
+
+func (c *cacheSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.desc
+}
+
+func (c *cacheSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for name, fn := range c.funcs {
+		ch <- prometheus.MustNewConstMetric(c.desc, prometheus.GaugeValue, float64(fn()), name)
+	}
+}
+
+// コネクションプールの統計(sql.DBStats)を公開する
+func RegisterDBStats(db *sql.DB, dbName string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, dbName))
+}
+
+// /metrics 用のハンドラ
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
@@ -2,11 +2,15 @@ package middleware
 
 import (
 	"context"
-	"log"
+	"crypto/subtle"
+	"errors"
 	"net/http"
-	"sync"
 	"time"
 
+	"backend/internal/apierror"
+	"backend/internal/cache"
+	"backend/internal/logging"
+	"backend/internal/model"
 	"backend/internal/repository"
 )
 
@@ -14,72 +18,22 @@ type contextKey string
 
 const userContextKey contextKey = "user"
 
-// セッションキャッシュエントリ
-type sessionCacheEntry struct {
-	userID    int
-	expiresAt time.Time
-}
-
-// シンプルなセッションキャッシュ
-type SessionCache struct {
-	sync.RWMutex
-	cache map[string]sessionCacheEntry
-}
-
-var sessionCache = &SessionCache{
-	cache: make(map[string]sessionCacheEntry),
-}
-
-// キャッシュから取得（期限切れは自動削除）
-func (s *SessionCache) Get(sessionID string) (int, bool) {
-	s.RLock()
-	entry, ok := s.cache[sessionID]
-	s.RUnlock()
-
-	if !ok {
-		return 0, false
-	}
-
-	// 期限切れチェック
-	if time.Now().After(entry.expiresAt) {
-		s.Delete(sessionID)
-		return 0, false
-	}
-
-	return entry.userID, true
-}
-
-// キャッシュに保存
-func (s *SessionCache) Set(sessionID string, userID int, ttl time.Duration) {
-	s.Lock()
-	defer s.Unlock()
-	s.cache[sessionID] = sessionCacheEntry{
-		userID:    userID,
-		expiresAt: time.Now().Add(ttl),
-	}
-}
-
-// キャッシュから削除
-func (s *SessionCache) Delete(sessionID string) {
-	s.Lock()
-	defer s.Unlock()
-	delete(s.cache, sessionID)
-}
-
-func UserAuthMiddleware(sessionRepo *repository.SessionRepository) func(http.Handler) http.Handler {
+// セッションIDとユーザーIDの対応を sessionCache に cacheTTL の間キャッシュする
+func UserAuthMiddleware(sessionRepo repository.Sessions, sessionCache cache.Cache[model.UserID], cacheTTL time.Duration) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			cookie, err := r.Cookie("session_id")
 			if err != nil {
-				log.Printf("Error retrieving session cookie: %v", err)
-				http.Error(w, "Unauthorized: No session cookie", http.StatusUnauthorized)
+				logging.FromContext(r.Context()).Info("Error retrieving session cookie", "error", err)
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized: No session cookie")
 				return
 			}
 			sessionID := cookie.Value
 
 			// キャッシュをチェック
-			if userID, ok := sessionCache.Get(sessionID); ok {
+			if userID, ok := sessionCache.Get(r.Context(), sessionID); ok {
 				ctx := context.WithValue(r.Context(), userContextKey, userID)
+				ctx = logging.With(ctx, "user_id", userID)
 				next.ServeHTTP(w, r.WithContext(ctx))
 				return
 			}
@@ -87,27 +41,62 @@ func UserAuthMiddleware(sessionRepo *repository.SessionRepository) func(http.Han
 			// キャッシュミス時はDBから取得
 			userID, err := sessionRepo.FindUserBySessionID(r.Context(), sessionID)
 			if err != nil {
-				log.Printf("Error finding user by session ID: %v", err)
-				http.Error(w, "Unauthorized: Invalid session", http.StatusUnauthorized)
+				logging.FromContext(r.Context()).Info("Error finding user by session ID", "error", err)
+				if errors.Is(err, repository.ErrUnavailable) {
+					apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeUnavailable, "Service temporarily unavailable")
+					return
+				}
+				apierror.Write(w, r, http.StatusUnauthorized, apierror.CodeUnauthorized, "Unauthorized: Invalid session")
 				return
 			}
 
 			// キャッシュに保存
-			sessionCache.Set(sessionID, userID, 60*time.Second)
+			sessionCache.Set(r.Context(), sessionID, userID, cacheTTL)
 
 			ctx := context.WithValue(r.Context(), userContextKey, userID)
+			ctx = logging.With(ctx, "user_id", userID)
 			next.ServeHTTP(w, r.WithContext(ctx))
 		})
 	}
 }
 
-func RobotAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
+// validAPIKeys のいずれかに一致すれば許可する (空文字列は無視する)
+// キーの切り替え中は新旧両方のキーを渡す
+func RobotAuthMiddleware(validAPIKeys ...string) func(http.Handler) http.Handler {
 	return func(next http.Handler) http.Handler {
 		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 			apiKey := r.Header.Get("X-API-KEY")
 
-			if apiKey == "" || apiKey != validAPIKey {
-				http.Error(w, "Forbidden: Invalid or missing API key", http.StatusForbidden)
+			if !matchesAPIKey(apiKey, validAPIKeys) {
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Forbidden: Invalid or missing API key")
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func matchesAPIKey(apiKey string, validAPIKeys []string) bool {
+	if apiKey == "" {
+		return false
+	}
+	matched := false
+	for _, key := range validAPIKeys {
+		if key != "" && subtle.ConstantTimeCompare([]byte(apiKey), []byte(key)) == 1 {
+			matched = true
+		}
+	}
+	return matched
+}
+
+// 管理用API(プロファイリングなど)の認証
+func AdminAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			apiKey := r.Header.Get("X-ADMIN-API-KEY")
+
+			if !matchesAPIKey(apiKey, []string{validAPIKey}) {
+				apierror.Write(w, r, http.StatusForbidden, apierror.CodeForbidden, "Forbidden: Invalid or missing admin API key")
 				return
 			}
 			next.ServeHTTP(w, r)
@@ -117,7 +106,7 @@ func RobotAuthMiddleware(validAPIKey string) func(http.Handler) http.Handler {
 
 // コンテキストからユーザー情報を取得
 // ユーザ情報はUserAuthMiddleware
-func GetUserFromContext(ctx context.Context) (int, bool) {
-	userID, ok := ctx.Value(userContextKey).(int)
+func GetUserFromContext(ctx context.Context) (model.UserID, bool) {
+	userID, ok := ctx.Value(userContextKey).(model.UserID)
 	return userID, ok
 }
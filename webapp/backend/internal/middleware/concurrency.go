@@ -0,0 +1,65 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/internal/apierror"
+	"backend/internal/logging"
+	"backend/internal/metrics"
+)
+
+// 処理中のリクエスト数を limit までに制限する
+// 空きがなければ queueTimeout まで待ち、それでも空かなければ 503 と Retry-After を返して負荷を落とす
+// limit が0以下の場合は制限しない
+func ConcurrencyLimit(group string, limit int, queueTimeout, retryAfter time.Duration) func(http.Handler) http.Handler {
+	// 同じグループに属するルートで上限を共有する
+	slots := make(chan struct{}, max(limit, 0))
+	inflight := metrics.HTTPInflight.WithLabelValues(group)
+	shed := metrics.HTTPShed.WithLabelValues(group)
+	retryAfterSec := strconv.Itoa(int((retryAfter + time.Second - 1) / time.Second))
+
+	return func(next http.Handler) http.Handler {
+		if limit <= 0 {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !acquire(r, slots, queueTimeout) {
+				shed.Inc()
+				logging.FromContext(r.Context()).Warn("Request shed due to concurrency limit", "group", group, "limit", limit)
+				w.Header().Set("Retry-After", retryAfterSec)
+				apierror.Write(w, r, http.StatusServiceUnavailable, apierror.CodeOverloaded, "Service overloaded, please retry later")
+				return
+			}
+			inflight.Inc()
+			defer func() {
+				inflight.Dec()
+				<-slots
+			}()
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func acquire(r *http.Request, slots chan struct{}, queueTimeout time.Duration) bool {
+	select {
+	case slots <- struct{}{}:
+		return true
+	default:
+	}
+	if queueTimeout <= 0 {
+		return false
+	}
+
+	timer := time.NewTimer(queueTimeout)
+	defer timer.Stop()
+	select {
+	case slots <- struct{}{}:
+		return true
+	case <-timer.C:
+		return false
+	case <-r.Context().Done():
+		return false
+	}
+}
@@ -0,0 +1,18 @@
+package middleware
+
+import (
+	"net/http"
+)
+
+// 旧バージョンのAPIであることをレスポンスヘッダで通知する
+// successor には移行先のパス (例: /api/v2) を指定する
+func Deprecated(successor string) func(http.Handler) http.Handler {
+	link := "<" + successor + `>; rel="successor-version"`
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Deprecation", "true")
+			w.Header().Add("Link", link)
+			next.ServeHTTP(w, r)
+		})
+	}
+}
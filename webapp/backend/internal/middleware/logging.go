@@ -0,0 +1,22 @@
+package middleware
+
+import (
+	"net/http"
+
+	"backend/internal/logging"
+
+	chimw "github.com/go-chi/chi/v5/middleware"
+)
+
+// リクエストIDをロガーに付与し、レスポンスヘッダーにも返す
+// chimw.RequestID の後に適用すること
+func RequestLogger(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reqID := chimw.GetReqID(r.Context())
+		if reqID != "" {
+			w.Header().Set(chimw.RequestIDHeader, reqID)
+		}
+		ctx := logging.With(r.Context(), "request_id", reqID)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
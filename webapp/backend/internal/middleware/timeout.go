@@ -0,0 +1,25 @@
+package middleware
+
+import (
+	"net/http"
+	"time"
+
+	"backend/internal/logging"
+)
+
+// http.Server の WriteTimeout より長くかかるレスポンス (ストリーミング・プロファイルの取得など) の
+// 書き込み期限を d 後まで延ばす。d が0以下の場合は期限をなくす
+func WriteTimeout(d time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			var deadline time.Time
+			if d > 0 {
+				deadline = time.Now().Add(d)
+			}
+			if err := http.NewResponseController(w).SetWriteDeadline(deadline); err != nil {
+				logging.FromContext(r.Context()).Warn("Failed to extend write deadline", "error", err)
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package migration
+
+import (
+	"context"
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// sql/ 配下のマイグレーションはバイナリに埋め込まれる
+// ファイル名は "<バージョン>_<名前>.sql" とし、バージョンの昇順に適用する
+//
+//go:embed sql/*.sql
+var files embed.FS
+
+// 同時に起動した複数のバックエンドが二重に適用しないためのロック名
+const lockName = "schema_migrations"
+
+type Migration struct {
+	Version int
+	Name    string
+	SQL     string
+}
+
+// 埋め込まれたマイグレーションをバージョン順に読み込む
+func Load() ([]Migration, error) {
+	entries, err := fs.ReadDir(files, "sql")
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]Migration, 0, len(entries))
+	seen := make(map[int]string, len(entries))
+	for _, e := range entries {
+		name := e.Name()
+		base := strings.TrimSuffix(name, ".sql")
+		versionStr, label, ok := strings.Cut(base, "_")
+		if !ok {
+			return nil, fmt.Errorf("migration %s: file name must be <version>_<name>.sql", name)
+		}
+		version, err := strconv.Atoi(versionStr)
+		if err != nil {
+			return nil, fmt.Errorf("migration %s: invalid version: %w", name, err)
+		}
+		if prev, dup := seen[version]; dup {
+			return nil, fmt.Errorf("migration %s: version %d already used by %s", name, version, prev)
+		}
+		seen[version] = name
+		body, err := files.ReadFile(path.Join("sql", name))
+		if err != nil {
+			return nil, err
+		}
+		migrations = append(migrations, Migration{Version: version, Name: label, SQL: string(body)})
+	}
+	sort.Slice(migrations, func(i, j int) bool { return migrations[i].Version < migrations[j].Version })
+	return migrations, nil
+}
+
+func ensureTable(ctx context.Context, db sqlx.ExecerContext) error {
+	_, err := db.ExecContext(ctx, `
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version INT UNSIGNED NOT NULL PRIMARY KEY,
+			name VARCHAR(255) NOT NULL,
+			applied_at DATETIME NOT NULL
+		)`)
+	return err
+}
+
+func appliedVersions(ctx context.Context, db sqlx.QueryerContext) (map[int]bool, error) {
+	var versions []int
+	if err := sqlx.SelectContext(ctx, db, &versions, "SELECT version FROM schema_migrations"); err != nil {
+		return nil, err
+	}
+	applied := make(map[int]bool, len(versions))
+	for _, v := range versions {
+		applied[v] = true
+	}
+	return applied, nil
+}
+
+// 未適用のマイグレーションを返す
+// 管理テーブルがまだ無い場合はエラーになる
+func Pending(ctx context.Context, db *sqlx.DB) ([]Migration, error) {
+	migrations, err := Load()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := appliedVersions(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if !applied[m.Version] {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// 未適用のマイグレーションを順に適用し、適用した件数を返す
+// MySQLのDDLは暗黙コミットされるため、1ファイルの途中で失敗した場合はそれまでの文が残る
+func Up(ctx context.Context, db *sqlx.DB) (int, error) {
+	conn, err := db.Connx(ctx)
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+
+	var locked int
+	if err := conn.GetContext(ctx, &locked, "SELECT GET_LOCK(?, 60)", lockName); err != nil {
+		return 0, fmt.Errorf("acquire migration lock: %w", err)
+	}
+	if locked != 1 {
+		return 0, fmt.Errorf("acquire migration lock: timed out")
+	}
+	defer conn.ExecContext(context.Background(), "SELECT RELEASE_LOCK(?)", lockName)
+
+	migrations, err := Load()
+	if err != nil {
+		return 0, err
+	}
+	if err := ensureTable(ctx, conn); err != nil {
+		return 0, err
+	}
+	applied, err := appliedVersions(ctx, conn)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, m := range migrations {
+		if applied[m.Version] {
+			continue
+		}
+		for _, stmt := range splitStatements(m.SQL) {
+			if _, err := conn.ExecContext(ctx, stmt); err != nil {
+				return count, fmt.Errorf("migration %d_%s: %w", m.Version, m.Name, err)
+			}
+		}
+		if _, err := conn.ExecContext(ctx,
+			"INSERT INTO schema_migrations (version, name, applied_at) VALUES (?, ?, UTC_TIMESTAMP())",
+			m.Version, m.Name,
+		); err != nil {
+			return count, fmt.Errorf("migration %d_%s: record: %w", m.Version, m.Name, err)
+		}
+		slog.Info("Applied migration", "version", m.Version, "name", m.Name)
+		count++
+	}
+	return count, nil
+}
+
+// DSNで multiStatements を有効にしていないため、1文ずつに分割して実行する
+// "--" で始まる行はコメントとして除去する。文字列リテラル中の ";" には対応しない
+func splitStatements(body string) []string {
+	var b strings.Builder
+	for _, line := range strings.Split(body, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "--") {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteString("\n")
+	}
+	var stmts []string
+	for _, stmt := range strings.Split(b.String(), ";") {
+		if stmt = strings.TrimSpace(stmt); stmt != "" {
+			stmts = append(stmts, stmt)
+		}
+	}
+	return stmts
+}
@@ -0,0 +1,3 @@
+-- 既存のテーブル(users, products, orders, user_sessions)は mysql/init/init.sql で作成され、
+-- インデックスなどのチューニングは mysql/migration/ 配下のSQLで適用される。
+-- 以降、アプリケーションの機能追加に必要なスキーマ変更はこのディレクトリに追加する。
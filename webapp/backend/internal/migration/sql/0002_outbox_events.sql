@@ -0,0 +1,13 @@
+-- 状態変更と同じトランザクションで書き込み、リレーが非同期に配信するイベント
+CREATE TABLE IF NOT EXISTS outbox_events (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    event_type VARCHAR(100) NOT NULL,
+    aggregate_id VARCHAR(64) NOT NULL,
+    payload JSON NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    created_at DATETIME(6) NOT NULL,
+    next_attempt_at DATETIME(6) NOT NULL,
+    delivered_at DATETIME(6) NULL,
+    INDEX idx_outbox_pending (delivered_at, next_attempt_at, id)
+);
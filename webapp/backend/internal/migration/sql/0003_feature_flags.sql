@@ -0,0 +1,8 @@
+-- 実行時に切り替えるフィーチャーフラグ
+-- 行がないフラグは環境変数 FEATURE_FLAGS の値(未指定なら無効)になる
+CREATE TABLE IF NOT EXISTS feature_flags (
+    name VARCHAR(64) PRIMARY KEY,
+    enabled TINYINT(1) NOT NULL DEFAULT 0,
+    rollout_percent INT NOT NULL DEFAULT 100,
+    updated_at DATETIME(6) NOT NULL
+);
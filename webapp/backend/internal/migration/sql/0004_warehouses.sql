@@ -0,0 +1,24 @@
+-- 倉庫と倉庫ごとの在庫
+-- 既存の注文は全て既定の倉庫(warehouse_id = 1)に属する
+CREATE TABLE IF NOT EXISTS warehouses (
+    warehouse_id INT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    name VARCHAR(255) NOT NULL,
+    UNIQUE KEY uq_warehouses_code (code)
+);
+
+INSERT IGNORE INTO warehouses (warehouse_id, code, name) VALUES (1, 'main', 'メイン倉庫');
+
+-- 行がない商品は在庫を管理せず、注文は既定の倉庫に割り当てる
+CREATE TABLE IF NOT EXISTS product_stocks (
+    product_id INT UNSIGNED NOT NULL,
+    warehouse_id INT UNSIGNED NOT NULL,
+    quantity INT UNSIGNED NOT NULL,
+    PRIMARY KEY (product_id, warehouse_id),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE,
+    FOREIGN KEY (warehouse_id) REFERENCES warehouses(warehouse_id)
+);
+
+ALTER TABLE orders
+    ADD COLUMN warehouse_id INT UNSIGNED NOT NULL DEFAULT 1,
+    ADD INDEX idx_orders_warehouse_status (warehouse_id, shipped_status);
@@ -0,0 +1,10 @@
+-- 日時の表示に使うユーザーごとのタイムゾーン (IANA名)
+-- DBには常にUTCで保存し、v2のレスポンスでのみこのタイムゾーンに変換して返す
+ALTER TABLE users ADD COLUMN timezone VARCHAR(64) NOT NULL DEFAULT 'UTC';
+
+-- この時点までの注文の日時はJST(TZ=Asia/Tokyo)で書かれている。0021 でUTCに変換する範囲として最後の注文IDを残す
+CREATE TABLE IF NOT EXISTS utc_switch (
+    id TINYINT UNSIGNED PRIMARY KEY,
+    last_jst_order_id BIGINT UNSIGNED NOT NULL
+);
+INSERT IGNORE INTO utc_switch (id, last_jst_order_id) SELECT 1, COALESCE(MAX(order_id), 0) FROM orders;
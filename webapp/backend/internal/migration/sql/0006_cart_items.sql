@@ -0,0 +1,10 @@
+-- ユーザーごとのカート
+-- 注文確定(チェックアウト)時に注文へ変換し、行を削除する
+CREATE TABLE IF NOT EXISTS cart_items (
+    user_id INT UNSIGNED NOT NULL,
+    product_id INT UNSIGNED NOT NULL,
+    quantity INT UNSIGNED NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (user_id, product_id),
+    FOREIGN KEY (product_id) REFERENCES products(product_id) ON DELETE CASCADE
+);
@@ -0,0 +1,16 @@
+-- 注文作成時の決済
+-- 注文と同じトランザクションで記録するため、決済に失敗した注文は残らない
+CREATE TABLE IF NOT EXISTS payments (
+    payment_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    provider VARCHAR(32) NOT NULL,
+    authorization_id VARCHAR(128) NOT NULL,
+    amount INT UNSIGNED NOT NULL,
+    -- authorized, captured, voided
+    status VARCHAR(16) NOT NULL,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    KEY idx_payments_user (user_id)
+);
+
+ALTER TABLE orders ADD COLUMN payment_id BIGINT UNSIGNED NULL;
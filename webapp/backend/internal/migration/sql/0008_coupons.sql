@@ -0,0 +1,29 @@
+-- クーポン
+-- max_redemptions / max_per_user が0の場合は回数を制限しない
+CREATE TABLE IF NOT EXISTS coupons (
+    code VARCHAR(32) PRIMARY KEY,
+    -- percent: 合計金額の value% を割り引く, fixed: value 円を割り引く
+    discount_type VARCHAR(16) NOT NULL,
+    value INT UNSIGNED NOT NULL,
+    expires_at DATETIME(6) NULL,
+    max_redemptions INT UNSIGNED NOT NULL DEFAULT 0,
+    max_per_user INT UNSIGNED NOT NULL DEFAULT 0,
+    redeemed_count INT UNSIGNED NOT NULL DEFAULT 0,
+    created_at DATETIME(6) NOT NULL
+);
+
+CREATE TABLE IF NOT EXISTS coupon_redemptions (
+    redemption_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    code VARCHAR(32) NOT NULL,
+    user_id INT UNSIGNED NOT NULL,
+    payment_id BIGINT UNSIGNED NOT NULL,
+    discount INT UNSIGNED NOT NULL,
+    redeemed_at DATETIME(6) NOT NULL,
+    KEY idx_coupon_redemptions_code_user (code, user_id),
+    FOREIGN KEY (code) REFERENCES coupons(code)
+);
+
+-- 注文1件あたりの割引額 (商品の価格から差し引いた額が実際の支払額)
+ALTER TABLE orders
+    ADD COLUMN discount INT UNSIGNED NOT NULL DEFAULT 0,
+    ADD COLUMN coupon_code VARCHAR(32) NULL;
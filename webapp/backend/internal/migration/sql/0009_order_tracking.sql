@@ -0,0 +1,18 @@
+-- 配送状況の追跡
+-- トークンは配送計画の作成時に注文ごとに発行し、ログインなしで追跡ページを開くために使う
+CREATE TABLE IF NOT EXISTS order_tracking (
+    order_id BIGINT UNSIGNED PRIMARY KEY,
+    token CHAR(32) NOT NULL,
+    robot_id VARCHAR(64) NOT NULL,
+    planned_at DATETIME(6) NOT NULL,
+    eta DATETIME(6) NOT NULL,
+    UNIQUE KEY uq_order_tracking_token (token)
+);
+
+-- ロボットが最後に報告した位置
+CREATE TABLE IF NOT EXISTS robot_locations (
+    robot_id VARCHAR(64) PRIMARY KEY,
+    latitude DOUBLE NOT NULL,
+    longitude DOUBLE NOT NULL,
+    reported_at DATETIME(6) NOT NULL
+);
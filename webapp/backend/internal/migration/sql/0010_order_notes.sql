@@ -0,0 +1,2 @@
+-- 配送時の備考 ("置き配希望" など)
+ALTER TABLE orders ADD COLUMN note VARCHAR(500) NULL;
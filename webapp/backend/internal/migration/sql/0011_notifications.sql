@@ -0,0 +1,24 @@
+-- ユーザーごとの通知設定
+-- 行がない、または email が NULL のユーザーには通知しない
+CREATE TABLE IF NOT EXISTS notification_preferences (
+    user_id INT UNSIGNED PRIMARY KEY,
+    email VARCHAR(255) NULL,
+    order_arrived TINYINT(1) NOT NULL DEFAULT 1,
+    updated_at DATETIME(6) NOT NULL
+);
+
+-- 送信待ちの通知
+-- 注文ステータスの更新と同じトランザクションで書き込み、ディスパッチャが非同期に送信する
+CREATE TABLE IF NOT EXISTS notifications (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    kind VARCHAR(32) NOT NULL,
+    order_id BIGINT UNSIGNED NOT NULL,
+    recipient VARCHAR(255) NOT NULL,
+    attempts INT UNSIGNED NOT NULL DEFAULT 0,
+    last_error TEXT NULL,
+    created_at DATETIME(6) NOT NULL,
+    next_attempt_at DATETIME(6) NOT NULL,
+    sent_at DATETIME(6) NULL,
+    INDEX idx_notifications_pending (sent_at, next_attempt_at, id)
+);
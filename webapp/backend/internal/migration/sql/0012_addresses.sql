@@ -0,0 +1,21 @@
+-- ユーザーごとの配送先
+-- is_default はユーザーごとに高々1件。注文時に配送先を省略した場合に使う
+CREATE TABLE IF NOT EXISTS addresses (
+    address_id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    user_id INT UNSIGNED NOT NULL,
+    label VARCHAR(64) NOT NULL DEFAULT '',
+    recipient VARCHAR(255) NOT NULL,
+    postal_code VARCHAR(16) NOT NULL,
+    prefecture VARCHAR(32) NOT NULL,
+    city VARCHAR(255) NOT NULL,
+    line1 VARCHAR(255) NOT NULL,
+    line2 VARCHAR(255) NOT NULL DEFAULT '',
+    phone VARCHAR(32) NOT NULL DEFAULT '',
+    is_default TINYINT(1) NOT NULL DEFAULT 0,
+    created_at DATETIME(6) NOT NULL,
+    updated_at DATETIME(6) NOT NULL,
+    KEY idx_addresses_user (user_id, is_default)
+);
+
+-- 配送先を削除しても注文は残すため外部キーは張らない
+ALTER TABLE orders ADD COLUMN address_id BIGINT UNSIGNED NULL;
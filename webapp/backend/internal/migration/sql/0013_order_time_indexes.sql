@@ -0,0 +1,4 @@
+-- 管理画面の集計(直近の作成件数・配送完了件数・平均配送時間)用
+ALTER TABLE orders
+    ADD INDEX idx_orders_created_at (created_at),
+    ADD INDEX idx_orders_arrived_at (arrived_at);
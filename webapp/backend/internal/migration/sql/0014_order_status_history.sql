@@ -0,0 +1,10 @@
+-- 注文ステータスの変更履歴
+-- orders.arrived_at はロボットの完了報告では更新されないため、配送完了時刻はここから求める
+CREATE TABLE IF NOT EXISTS order_status_history (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT UNSIGNED NOT NULL,
+    status VARCHAR(50) NOT NULL,
+    changed_at DATETIME(6) NOT NULL,
+    INDEX idx_order_status_history_order (order_id, status),
+    INDEX idx_order_status_history_status (status, changed_at)
+);
@@ -0,0 +1,9 @@
+-- 在庫が閾値を下回っている間だけ行を持つ
+-- 同じ在庫について何度もアラートを出さないために使い、閾値以上に戻ったら削除する
+CREATE TABLE IF NOT EXISTS low_stock_alerts (
+    product_id INT UNSIGNED NOT NULL,
+    warehouse_id INT UNSIGNED NOT NULL,
+    quantity INT UNSIGNED NOT NULL,
+    alerted_at DATETIME(6) NOT NULL,
+    PRIMARY KEY (product_id, warehouse_id)
+);
@@ -0,0 +1,23 @@
+-- 1時間ごとの注文数と配送完了数 (UTC)
+-- アプリケーションがイベントから集計した差分を定期的に加算する
+CREATE TABLE IF NOT EXISTS order_hourly_stats (
+    hour DATETIME NOT NULL PRIMARY KEY,
+    orders_created INT UNSIGNED NOT NULL DEFAULT 0,
+    deliveries_completed INT UNSIGNED NOT NULL DEFAULT 0
+);
+
+-- 既存の注文の分を埋めておく
+INSERT INTO order_hourly_stats (hour, orders_created)
+SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00') AS h, COUNT(*)
+FROM orders
+GROUP BY h
+ON DUPLICATE KEY UPDATE orders_created = VALUES(orders_created);
+
+INSERT INTO order_hourly_stats (hour, deliveries_completed)
+SELECT h, n FROM (
+    SELECT DATE_FORMAT(arrived_at, '%Y-%m-%d %H:00:00') AS h, COUNT(*) AS n
+    FROM orders
+    WHERE arrived_at IS NOT NULL
+    GROUP BY h
+) delivered
+ON DUPLICATE KEY UPDATE deliveries_completed = VALUES(deliveries_completed);
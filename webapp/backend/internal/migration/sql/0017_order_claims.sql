@@ -0,0 +1,6 @@
+-- 配送計画で注文を確保したロボットと計画
+-- 計画ごとに一意な plan_id を付けて1つのUPDATEで確保し、確保できた注文だけを読み戻す
+ALTER TABLE orders
+    ADD COLUMN robot_id VARCHAR(64) NULL,
+    ADD COLUMN plan_id CHAR(36) NULL,
+    ADD INDEX idx_orders_plan_id (plan_id);
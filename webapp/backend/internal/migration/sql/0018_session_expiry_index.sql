@@ -0,0 +1,3 @@
+-- 期限切れのセッションを定期的に削除するジョブ用
+ALTER TABLE user_sessions
+    ADD INDEX idx_user_sessions_expires_at (expires_at);
@@ -0,0 +1,13 @@
+-- 注文時点の商品の重さと価値
+-- 商品を変更しても、作成済みの注文の配送計画や履歴・集計が変わらないように注文に写しておく
+ALTER TABLE orders
+    ADD COLUMN weight INT UNSIGNED NULL,
+    ADD COLUMN value INT UNSIGNED NULL;
+
+UPDATE orders o
+JOIN products p ON p.product_id = o.product_id
+SET o.weight = p.weight, o.value = p.value;
+
+ALTER TABLE orders
+    MODIFY COLUMN weight INT UNSIGNED NOT NULL,
+    MODIFY COLUMN value INT UNSIGNED NOT NULL;
@@ -0,0 +1,11 @@
+-- ロボットが配送できずに戻した注文と理由
+-- 1回の配送の試行ごとに1行。注文ごとの行数が試行回数になる
+CREATE TABLE IF NOT EXISTS order_returns (
+    id BIGINT UNSIGNED AUTO_INCREMENT PRIMARY KEY,
+    order_id BIGINT UNSIGNED NOT NULL,
+    robot_id VARCHAR(64) NOT NULL,
+    reason VARCHAR(32) NOT NULL,
+    returned_at DATETIME(6) NOT NULL,
+    INDEX idx_order_returns_order_id (order_id),
+    INDEX idx_order_returns_returned_at (returned_at)
+);
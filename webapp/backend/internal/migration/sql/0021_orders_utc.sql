@@ -0,0 +1,52 @@
+-- 0005 より前の注文日時はJST(TZ=Asia/Tokyo)で書かれているため、UTCに変換する
+-- 対象はリストアしたダンプのデータと NOW() で作成された注文で、0005 の適用時に utc_switch に残した注文IDまで
+-- order_status_history は 0005 より後に作られたため、changed_at は最初からUTCで変換は不要
+-- utc_switch を残す前に 0005 を適用した環境では、0005 の適用時刻(UTC)より前の日時の注文までとする
+-- (JSTの値はUTCより9時間進んでいるため、適用直前の9時間に作成された注文は変換されない)
+CREATE TABLE IF NOT EXISTS utc_switch (
+    id TINYINT UNSIGNED PRIMARY KEY,
+    last_jst_order_id BIGINT UNSIGNED NOT NULL
+);
+INSERT IGNORE INTO utc_switch (id, last_jst_order_id)
+SELECT 1, COALESCE(MAX(order_id), 0) FROM orders
+WHERE created_at < (SELECT applied_at FROM schema_migrations WHERE version = 5);
+
+-- 0016 で order_hourly_stats に埋めた件数はJSTの時刻で数えているため、変換する注文の分を引いてから数え直す
+UPDATE order_hourly_stats s
+JOIN (
+    SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00') AS h, COUNT(*) AS n
+    FROM orders
+    WHERE order_id <= (SELECT last_jst_order_id FROM utc_switch WHERE id = 1)
+    GROUP BY h
+) c ON s.hour = c.h
+SET s.orders_created = GREATEST(s.orders_created, c.n) - c.n;
+
+UPDATE order_hourly_stats s
+JOIN (
+    SELECT DATE_FORMAT(arrived_at, '%Y-%m-%d %H:00:00') AS h, COUNT(*) AS n
+    FROM orders
+    WHERE order_id <= (SELECT last_jst_order_id FROM utc_switch WHERE id = 1) AND arrived_at IS NOT NULL
+    GROUP BY h
+) c ON s.hour = c.h
+SET s.deliveries_completed = GREATEST(s.deliveries_completed, c.n) - c.n;
+
+UPDATE orders
+SET created_at = CONVERT_TZ(created_at, '+09:00', '+00:00'),
+    arrived_at = CONVERT_TZ(arrived_at, '+09:00', '+00:00')
+WHERE order_id <= (SELECT last_jst_order_id FROM utc_switch WHERE id = 1);
+
+INSERT INTO order_hourly_stats (hour, orders_created)
+SELECT DATE_FORMAT(created_at, '%Y-%m-%d %H:00:00') AS h, COUNT(*)
+FROM orders
+WHERE order_id <= (SELECT last_jst_order_id FROM utc_switch WHERE id = 1)
+GROUP BY h
+ON DUPLICATE KEY UPDATE orders_created = orders_created + VALUES(orders_created);
+
+INSERT INTO order_hourly_stats (hour, deliveries_completed)
+SELECT h, n FROM (
+    SELECT DATE_FORMAT(arrived_at, '%Y-%m-%d %H:00:00') AS h, COUNT(*) AS n
+    FROM orders
+    WHERE order_id <= (SELECT last_jst_order_id FROM utc_switch WHERE id = 1) AND arrived_at IS NOT NULL
+    GROUP BY h
+) delivered
+ON DUPLICATE KEY UPDATE deliveries_completed = deliveries_completed + VALUES(deliveries_completed)
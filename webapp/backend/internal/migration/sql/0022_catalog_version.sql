@@ -0,0 +1,7 @@
+-- 商品カタログのバージョン (1行のみ)
+-- 商品を追加・変更する経路で1ずつ進め、商品一覧のETagに使う。サーバー・運用ツールのどちらから変更しても全てのサーバーで共有される
+CREATE TABLE IF NOT EXISTS catalog_version (
+    id TINYINT UNSIGNED PRIMARY KEY,
+    version BIGINT UNSIGNED NOT NULL
+);
+INSERT IGNORE INTO catalog_version (id, version) VALUES (1, 1)
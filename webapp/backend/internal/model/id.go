@@ -0,0 +1,43 @@
+package model
+
+import "strconv"
+
+// 注文ID
+type OrderID int64
+
+// 商品ID
+type ProductID int
+
+// ユーザーID
+type UserID int
+
+func (id OrderID) String() string   { return strconv.FormatInt(int64(id), 10) }
+func (id ProductID) String() string { return strconv.Itoa(int(id)) }
+func (id UserID) String() string    { return strconv.Itoa(int(id)) }
+
+// 10進数の文字列を注文IDに変換する
+func ParseOrderID(s string) (OrderID, error) {
+	id, err := strconv.ParseInt(s, 10, 64)
+	return OrderID(id), err
+}
+
+// 10進数の文字列を商品IDに変換する
+func ParseProductID(s string) (ProductID, error) {
+	id, err := strconv.Atoi(s)
+	return ProductID(id), err
+}
+
+// 10進数の文字列をユーザーIDに変換する
+func ParseUserID(s string) (UserID, error) {
+	id, err := strconv.Atoi(s)
+	return UserID(id), err
+}
+
+// 注文IDを文字列にする (注文作成のレスポンスなど)
+func OrderIDStrings(ids []OrderID) []string {
+	out := make([]string, len(ids))
+	for i, id := range ids {
+		out[i] = id.String()
+	}
+	return out
+}
@@ -0,0 +1,68 @@
+package model
+
+import "backend/internal/jsonenc"
+
+// 一覧で大量に返す型のJSON
+// encoding/json の出力と同じにすること (フィールドやタグを変えた場合はここも合わせる)
+
+func (p Product) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"product_id":`...)
+	dst = jsonenc.AppendInt(dst, p.ProductID)
+	dst = append(dst, `,"name":`...)
+	dst = jsonenc.AppendString(dst, p.Name)
+	dst = append(dst, `,"value":`...)
+	dst = jsonenc.AppendInt(dst, p.Value)
+	dst = append(dst, `,"weight":`...)
+	dst = jsonenc.AppendInt(dst, p.Weight)
+	dst = append(dst, `,"image":`...)
+	dst = jsonenc.AppendString(dst, p.Image)
+	dst = append(dst, `,"description":`...)
+	dst = jsonenc.AppendString(dst, p.Description)
+	return append(dst, '}')
+}
+
+func (p ProductSummary) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"product_id":`...)
+	dst = jsonenc.AppendInt(dst, p.ProductID)
+	dst = append(dst, `,"name":`...)
+	dst = jsonenc.AppendString(dst, p.Name)
+	dst = append(dst, `,"value":`...)
+	dst = jsonenc.AppendInt(dst, p.Value)
+	dst = append(dst, `,"weight":`...)
+	dst = jsonenc.AppendInt(dst, p.Weight)
+	return append(dst, '}')
+}
+
+func (o Order) AppendJSON(dst []byte) []byte {
+	dst = append(dst, `{"order_id":`...)
+	dst = jsonenc.AppendInt(dst, o.OrderID)
+	dst = append(dst, `,"user_id":`...)
+	dst = jsonenc.AppendInt(dst, o.UserID)
+	dst = append(dst, `,"product_id":`...)
+	dst = jsonenc.AppendInt(dst, o.ProductID)
+	dst = append(dst, `,"product_name":`...)
+	dst = jsonenc.AppendString(dst, o.ProductName)
+	dst = append(dst, `,"shipped_status":`...)
+	dst = jsonenc.AppendString(dst, o.ShippedStatus)
+	dst = append(dst, `,"weight":`...)
+	dst = jsonenc.AppendInt(dst, o.Weight)
+	dst = append(dst, `,"value":`...)
+	dst = jsonenc.AppendInt(dst, o.Value)
+	dst = append(dst, `,"created_at":`...)
+	dst = jsonenc.AppendTime(dst, o.CreatedAt)
+	// sql.NullTime はフィールド名のまま {"Time", "Valid"} になる
+	dst = append(dst, `,"arrived_at":{"Time":`...)
+	dst = jsonenc.AppendTime(dst, o.ArrivedAt.Time)
+	dst = append(dst, `,"Valid":`...)
+	dst = jsonenc.AppendBool(dst, o.ArrivedAt.Valid)
+	dst = append(dst, '}')
+	if o.Discount != 0 {
+		dst = append(dst, `,"discount":`...)
+		dst = jsonenc.AppendInt(dst, o.Discount)
+	}
+	if o.Note != "" {
+		dst = append(dst, `,"note":`...)
+		dst = jsonenc.AppendString(dst, o.Note)
+	}
+	return append(dst, '}')
+}
@@ -0,0 +1,143 @@
+package model
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"backend/internal/jsonenc"
+)
+
+// エスケープが必要な文字列 (HTMLの記号、制御文字、不正なUTF-8、U+2028/U+2029)
+var jsonTestStrings = []string{
+	"",
+	"ノート",
+	`<a href="x">&'</a>`,
+	"tab\tnewline\nreturn\r\x00\x1f\x7f",
+	`back\slash "quoted"`,
+	"\xff\xfeinvalid\xc3",
+	"line\u2028separator\u2029paragraph",
+	"emoji 🎉 and 漢字",
+}
+
+func jsonTestTimes() []time.Time {
+	jst := time.FixedZone("JST", 9*60*60)
+	return []time.Time{
+		{},
+		time.Date(2025, 3, 31, 12, 19, 23, 0, time.UTC),
+		time.Date(2025, 3, 31, 23, 59, 59, 123456000, time.UTC),
+		time.Date(2025, 4, 1, 8, 0, 0, 0, jst),
+	}
+}
+
+func TestAppendJSONMatchesEncodingJSON(t *testing.T) {
+	times := jsonTestTimes()
+	for i, s := range jsonTestStrings {
+		compareJSON(t, Product{ProductID: ProductID(i), Name: s, Value: -i, Weight: 1 << 40, Image: s, Description: s})
+		compareJSON(t, ProductSummary{ProductID: ProductID(i), Name: s, Value: i, Weight: i})
+
+		for j, createdAt := range times {
+			base := Order{
+				OrderID:       OrderID(i),
+				UserID:        UserID(j),
+				ProductID:     ProductID(i + j),
+				ProductName:   s,
+				ShippedStatus: "completed",
+				Weight:        i,
+				Value:         j,
+				CreatedAt:     createdAt,
+				// レスポンスに含めないフィールド
+				CouponCode:  "SECRET",
+				AddressID:   1,
+				PaymentID:   2,
+				WarehouseID: 3,
+			}
+			// arrived_at が null (Valid: false) の場合
+			compareJSON(t, base)
+
+			arrived := base
+			arrived.ArrivedAt = sql.NullTime{Time: createdAt.Add(time.Hour), Valid: true}
+			compareJSON(t, arrived)
+
+			// omitempty のフィールドがある場合
+			withOptional := arrived
+			withOptional.Discount = 150
+			withOptional.Note = s + "置き配希望"
+			compareJSON(t, withOptional)
+		}
+	}
+}
+
+func TestAppendSliceMatchesEncodingJSON(t *testing.T) {
+	orders := benchmarkOrders(3)
+	compareJSON(t, orders)
+	compareJSON(t, []Order{})
+	compareJSON(t, []Order(nil))
+}
+
+// v を jsonenc と encoding/json で書き出し、1バイトも違わないことを確かめる
+func compareJSON[T any](t *testing.T, v T) {
+	t.Helper()
+	var want bytes.Buffer
+	if err := json.NewEncoder(&want).Encode(v); err != nil {
+		t.Fatalf("encoding/json: %v", err)
+	}
+	var got bytes.Buffer
+	err := jsonenc.Write(&got, func(dst []byte) []byte {
+		switch v := any(v).(type) {
+		case []Order:
+			return jsonenc.AppendSlice(dst, v)
+		case jsonenc.Appender:
+			return v.AppendJSON(dst)
+		}
+		t.Fatalf("%T does not implement jsonenc.Appender", v)
+		return dst
+	})
+	if err != nil {
+		t.Fatalf("jsonenc: %v", err)
+	}
+	if !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("%T mismatch\n got: %s\nwant: %s", v, got.Bytes(), want.Bytes())
+	}
+}
+
+func benchmarkOrders(n int) []Order {
+	orders := make([]Order, n)
+	createdAt := time.Date(2025, 3, 31, 12, 19, 23, 0, time.UTC)
+	for i := range orders {
+		orders[i] = Order{
+			OrderID:       OrderID(i + 1),
+			UserID:        1,
+			ProductID:     ProductID(i*7 + 1),
+			ProductName:   fmt.Sprintf("限定匠仕上げケース改良型 %d", i),
+			ShippedStatus: "completed",
+			Weight:        i % 50,
+			Value:         100 * i,
+			CreatedAt:     createdAt.Add(time.Duration(i) * time.Minute),
+			ArrivedAt:     sql.NullTime{Time: createdAt.Add(time.Duration(i) * time.Hour), Valid: i%2 == 0},
+		}
+	}
+	return orders
+}
+
+func BenchmarkWriteList(b *testing.B) {
+	orders := benchmarkOrders(100)
+	b.Run("jsonenc", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			jsonenc.Write(io.Discard, func(dst []byte) []byte {
+				return jsonenc.AppendSlice(dst, orders)
+			})
+		}
+	})
+	b.Run("encoding/json", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			json.NewEncoder(io.Discard).Encode(orders)
+		}
+	})
+}
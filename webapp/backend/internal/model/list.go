@@ -0,0 +1,119 @@
+package model
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 1ページあたりの最大件数 (v2では超えるとエラー、v1ではこの件数に丸める)
+const MaxPageSize = 100
+
+// 一覧の種類ごとのソート条件
+type ListSpec struct {
+	DefaultSortField string
+	DefaultSortOrder string
+	// ソートに使えるフィールド
+	SortFields []string
+	// v1で受け付けてきた別名 (別名 -> SortFields のいずれか)
+	LegacySortFields map[string]string
+}
+
+var (
+	ProductListSpec = ListSpec{
+		DefaultSortField: "product_id",
+		DefaultSortOrder: "ASC",
+		SortFields:       []string{"product_id", "name", "value", "weight", "image", "description"},
+	}
+	OrderListSpec = ListSpec{
+		DefaultSortField: "order_id",
+		DefaultSortOrder: "DESC",
+		SortFields:       []string{"order_id", "product_name", "shipped_status", "created_at", "arrived_at"},
+		// ベンチマーカーは注文履歴を "name" でソートする
+		LegacySortFields: map[string]string{"name": "product_name"},
+	}
+)
+
+// 一覧の条件の誤り
+type ListRequestError struct {
+	Field   string
+	Message string
+}
+
+func (e *ListRequestError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// 一覧の条件に既定値を補い、不正な値はエラーにする (v2・GraphQL)
+// 成功すると SortOrder は ASC/DESC、SortField は spec.SortFields のいずれかになり、Offset が設定される
+func (r *ListRequest) Validate(spec ListSpec) error {
+	return r.validate(spec, true)
+}
+
+// v1の一覧の条件に既定値を補う
+// 従来どおり、負のページは1ページ目、未知のソート対象は既定のもの (別名は対応するもの) にし、件数は MaxPageSize に丸める
+func (r *ListRequest) ValidateV1(spec ListSpec) error {
+	return r.validate(spec, false)
+}
+
+func (r *ListRequest) validate(spec ListSpec, strict bool) error {
+	if r.Page < 0 {
+		if strict {
+			return &ListRequestError{Field: "page", Message: "must not be negative"}
+		}
+		r.Page = 1
+	}
+	if r.Page == 0 {
+		r.Page = 1
+	}
+	if r.PageSize <= 0 {
+		r.PageSize = 20
+	}
+	if r.PageSize > MaxPageSize {
+		if strict {
+			return &ListRequestError{Field: "page_size", Message: fmt.Sprintf("must be at most %d", MaxPageSize)}
+		}
+		r.PageSize = MaxPageSize
+	}
+
+	switch strings.ToUpper(r.SortOrder) {
+	case "":
+		r.SortOrder = spec.DefaultSortOrder
+	case "ASC":
+		r.SortOrder = "ASC"
+	case "DESC":
+		r.SortOrder = "DESC"
+	default:
+		return &ListRequestError{Field: "sort_order", Message: "must be asc or desc"}
+	}
+
+	switch {
+	case r.SortField == "":
+		r.SortField = spec.DefaultSortField
+	case containsString(spec.SortFields, r.SortField):
+	case strict:
+		return &ListRequestError{Field: "sort_field", Message: "must be one of " + strings.Join(spec.SortFields, ", ")}
+	case spec.LegacySortFields[r.SortField] != "":
+		r.SortField = spec.LegacySortFields[r.SortField]
+	default:
+		r.SortField = spec.DefaultSortField
+	}
+
+	if r.Type != "prefix" {
+		r.Type = "partial"
+	}
+
+	r.Offset = (r.Page - 1) * r.PageSize
+	if r.Offset < 0 {
+		return &ListRequestError{Field: "page", Message: "is too large"}
+	}
+	return nil
+}
+
+func containsString(values []string, v string) bool {
+	for _, s := range values {
+		if s == v {
+			return true
+		}
+	}
+	return false
+}
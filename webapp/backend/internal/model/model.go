@@ -6,30 +6,98 @@ import (
 )
 
 type User struct {
-	UserID       int    `db:"user_id"`
+	UserID       UserID `db:"user_id"`
 	PasswordHash string `db:"password_hash"`
 	UserName     string `db:"user_name"`
+	// 表示用のタイムゾーン (IANA名)
+	Timezone string `db:"timezone"`
+}
+
+// 未設定のユーザーに使う表示用のタイムゾーン
+const DefaultTimezone = "UTC"
+
+type UserProfile struct {
+	UserID   UserID `json:"user_id"`
+	UserName string `json:"user_name"`
+	Timezone string `json:"timezone"`
+}
+
+type UpdateTimezoneRequest struct {
+	Timezone string `json:"timezone"`
 }
 
 type Product struct {
-	ProductID   int    `db:"product_id"   json:"product_id"`
-	Name        string `db:"name"         json:"name"`
-	Value       int    `db:"value"        json:"value"`
-	Weight      int    `db:"weight"       json:"weight"`
-	Image       string `db:"image"        json:"image"`
-	Description string `db:"description"  json:"description"`
+	ProductID   ProductID `db:"product_id"   json:"product_id"`
+	Name        string    `db:"name"         json:"name"`
+	Value       int       `db:"value"        json:"value"`
+	Weight      int       `db:"weight"       json:"weight"`
+	Image       string    `db:"image"        json:"image"`
+	Description string    `db:"description"  json:"description"`
+}
+
+// 配送計画に使う商品の重さと価値
+type ProductAttributes struct {
+	Weight int `db:"weight" json:"weight"`
+	Value  int `db:"value"  json:"value"`
+}
+
+// 商品一覧用の商品情報 (画像と説明文は含めない)
+type ProductSummary struct {
+	ProductID ProductID `db:"product_id" json:"product_id"`
+	Name      string    `db:"name"       json:"name"`
+	Value     int       `db:"value"      json:"value"`
+	Weight    int       `db:"weight"     json:"weight"`
 }
 
 type Order struct {
-	OrderID       int64        `db:"order_id"        json:"order_id"`
-	UserID        int          `db:"user_id"         json:"user_id"`
-	ProductID     int          `db:"product_id"      json:"product_id"`
+	OrderID       OrderID      `db:"order_id"        json:"order_id"`
+	UserID        UserID       `db:"user_id"         json:"user_id"`
+	ProductID     ProductID    `db:"product_id"      json:"product_id"`
 	ProductName   string       `db:"product_name"    json:"product_name"`
 	ShippedStatus string       `db:"shipped_status"  json:"shipped_status"`
 	Weight        int          `db:"weight"          json:"weight"`
 	Value         int          `db:"value"           json:"value"`
 	CreatedAt     time.Time    `db:"created_at"      json:"created_at"`
 	ArrivedAt     sql.NullTime `db:"arrived_at"      json:"arrived_at"`
+	// クーポンによる割引額 (割引がない場合はレスポンスに含めない)
+	Discount   int    `db:"discount"    json:"discount,omitempty"`
+	CouponCode string `db:"coupon_code" json:"-"`
+	// 配送時の備考 ("置き配希望" など。ない場合はレスポンスに含めない)
+	Note string `db:"note" json:"note,omitempty"`
+	// 配送先 (0: 未指定。v1のレスポンスには含めない)
+	AddressID int64 `db:"address_id" json:"-"`
+	// 注文時の決済 (0: 決済なし。v1のレスポンスには含めない)
+	PaymentID int64 `db:"payment_id" json:"-"`
+	// 出荷元の倉庫 (v1のレスポンスには含めない)
+	WarehouseID int `db:"warehouse_id" json:"-"`
+}
+
+// 注文の領収書
+type Receipt struct {
+	OrderID       OrderID   `db:"order_id"`
+	ProductName   string    `db:"product_name"`
+	Price         int       `db:"value"`
+	Discount      int       `db:"discount"`
+	ShippedStatus string    `db:"shipped_status"`
+	OrderedAt     time.Time `db:"created_at"`
+	// 配送計画に含まれた時刻 (未発送の場合は nil)
+	DispatchedAt *time.Time `db:"planned_at"`
+	// 配送完了時刻 (未完了の場合は nil)
+	DeliveredAt *time.Time `db:"delivered_at"`
+}
+
+// 割引後の支払額
+func (r Receipt) Total() int {
+	return r.Price - r.Discount
+}
+
+// 外部の配車システムに渡す配送待ちの注文
+type ShippingCandidate struct {
+	OrderID OrderID `db:"order_id" json:"order_id"`
+	Weight  int     `db:"weight"   json:"weight"`
+	Value   int     `db:"value"    json:"value"`
+	// 出荷元の倉庫のコード
+	Zone string `db:"zone" json:"zone"`
 }
 
 type DeliveryPlan struct {
@@ -46,16 +114,59 @@ type LoginRequest struct {
 
 type CreateOrderRequest struct {
 	Items []RequestItem `json:"items"`
+	// 省略可
+	CouponCode string `json:"coupon_code,omitempty"`
+	// 省略可。作成する全ての注文に付ける
+	Note string `json:"note,omitempty"`
+	// 省略時は既定の配送先
+	AddressID int64 `json:"address_id,omitempty"`
+}
+
+// 注文作成時の任意の指定
+type OrderOptions struct {
+	// 空でなければクーポンを適用する
+	CouponCode string
+	// 配送時の備考
+	Note string
+	// 配送先 (0: ユーザーの既定の配送先)
+	AddressID int64
 }
 
 type RequestItem struct {
-	ProductID int `json:"product_id"`
-	Quantity  int `json:"quantity"`
+	ProductID ProductID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
 }
 
 type UpdateOrderStatusRequest struct {
-	OrderID   int64  `json:"order_id"`
-	NewStatus string `json:"new_status"`
+	OrderID   OrderID `json:"order_id"`
+	NewStatus string  `json:"new_status"`
+}
+
+// ロボットが注文を配送できなかった理由
+const (
+	ReturnAddressUnreachable = "address_unreachable"
+	ReturnRecipientAbsent    = "recipient_absent"
+	ReturnItemDamaged        = "item_damaged"
+	ReturnOther              = "other"
+)
+
+type ReturnOrdersRequest struct {
+	Orders []ReturnOrderItem `json:"orders"`
+}
+
+type ReturnOrderItem struct {
+	OrderID OrderID `json:"order_id"`
+	Reason  string  `json:"reason"`
+}
+
+// 配送できなかった注文を戻した結果
+type ReturnOrdersResult struct {
+	// 配送待ち(shipping)に戻し、次の配送計画の対象にした注文
+	Requeued []OrderID `json:"requeued"`
+	// 配送の試行回数が上限に達したため failed にした注文
+	Failed []OrderID `json:"failed"`
+	// 配送中でない、または他のロボットが配送中のため何もしなかった注文
+	Skipped []OrderID `json:"skipped"`
 }
 
 type ListRequest struct {
@@ -65,5 +176,286 @@ type ListRequest struct {
 	PageSize  int    `json:"page_size"`
 	SortField string `json:"sort_field"`
 	SortOrder string `json:"sort_order"`
-	Offset    int    `json:"-"`
+	// 前のレスポンスの next_cursor (v2のみ)。指定した場合は page より優先する
+	Cursor string `json:"cursor"`
+	// 総数が多い場合も正確に数える (v2の商品一覧のみ。v1は常に正確に数える)
+	Exact  bool `json:"exact"`
+	Offset int  `json:"-"`
+}
+
+// 一覧の総数
+type ListTotal struct {
+	Count int
+	// true の場合、Count は総数の下限 (実際の総数は Count より多い)
+	Approximate bool
+}
+
+// 配信待ちのドメインイベント (transactional outbox)
+type OutboxEvent struct {
+	ID          int64     `db:"id"           json:"id"`
+	EventType   string    `db:"event_type"   json:"event_type"`
+	AggregateID string    `db:"aggregate_id" json:"aggregate_id"`
+	Payload     []byte    `db:"payload"      json:"-"`
+	Attempts    int       `db:"attempts"     json:"attempts"`
+	CreatedAt   time.Time `db:"created_at"   json:"created_at"`
+}
+
+type FeatureFlag struct {
+	Name    string `db:"name"            json:"name"`
+	Enabled bool   `db:"enabled"         json:"enabled"`
+	// 有効にする対象の割合 (0-100)。対象ごとのハッシュ値で振り分ける
+	RolloutPercent int       `db:"rollout_percent" json:"rollout_percent"`
+	UpdatedAt      time.Time `db:"updated_at"      json:"updated_at"`
+}
+
+type UpdateFeatureFlagRequest struct {
+	Enabled        bool `json:"enabled"`
+	RolloutPercent *int `json:"rollout_percent"`
+}
+
+// 注文の割り当て先が決まらない場合に使う倉庫
+const DefaultWarehouseID = 1
+
+type Warehouse struct {
+	WarehouseID int    `db:"warehouse_id" json:"warehouse_id"`
+	Code        string `db:"code"         json:"code"`
+	Name        string `db:"name"         json:"name"`
+}
+
+type ProductStock struct {
+	ProductID   ProductID `db:"product_id"   json:"product_id"`
+	WarehouseID int       `db:"warehouse_id" json:"warehouse_id"`
+	Quantity    int       `db:"quantity"     json:"quantity"`
+}
+
+// 在庫が閾値を下回っている商品
+type LowStock struct {
+	ProductID     ProductID `db:"product_id"     json:"product_id"`
+	ProductName   string    `db:"product_name"   json:"product_name"`
+	WarehouseID   int       `db:"warehouse_id"   json:"warehouse_id"`
+	WarehouseCode string    `db:"warehouse_code" json:"warehouse_code"`
+	Quantity      int       `db:"quantity"       json:"quantity"`
+	// アラートを出した時刻 (次回のチェックまでは null)
+	AlertedAt *time.Time `db:"alerted_at" json:"alerted_at"`
+}
+
+type CreateWarehouseRequest struct {
+	Code string `json:"code"`
+	Name string `json:"name"`
+}
+
+type UpdateStockRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+type CartItem struct {
+	ProductID ProductID `db:"product_id" json:"product_id"`
+	Name      string    `db:"name"       json:"name"`
+	Value     int       `db:"value"      json:"value"`
+	Weight    int       `db:"weight"     json:"weight"`
+	Image     string    `db:"image"      json:"image"`
+	Quantity  int       `db:"quantity"   json:"quantity"`
+}
+
+type Cart struct {
+	Items       []CartItem `json:"items"`
+	TotalValue  int        `json:"total_value"`
+	TotalWeight int        `json:"total_weight"`
+}
+
+type AddCartItemRequest struct {
+	ProductID ProductID `json:"product_id"`
+	Quantity  int       `json:"quantity"`
+}
+
+type UpdateCartItemRequest struct {
+	Quantity int `json:"quantity"`
+}
+
+// 決済の状態
+const (
+	PaymentAuthorized = "authorized"
+	PaymentCaptured   = "captured"
+	PaymentVoided     = "voided"
+)
+
+type Payment struct {
+	PaymentID       int64     `db:"payment_id"`
+	UserID          UserID    `db:"user_id"`
+	Provider        string    `db:"provider"`
+	AuthorizationID string    `db:"authorization_id"`
+	Amount          int       `db:"amount"`
+	Status          string    `db:"status"`
+	CreatedAt       time.Time `db:"created_at"`
+	UpdatedAt       time.Time `db:"updated_at"`
+}
+
+// クーポンの割引の種類
+const (
+	CouponPercent = "percent"
+	CouponFixed   = "fixed"
+)
+
+type Coupon struct {
+	Code         string     `db:"code"          json:"code"`
+	DiscountType string     `db:"discount_type" json:"discount_type"`
+	Value        int        `db:"value"         json:"value"`
+	ExpiresAt    *time.Time `db:"expires_at"    json:"expires_at"`
+	// 0の場合は制限しない
+	MaxRedemptions int       `db:"max_redemptions" json:"max_redemptions"`
+	MaxPerUser     int       `db:"max_per_user"    json:"max_per_user"`
+	RedeemedCount  int       `db:"redeemed_count"  json:"redeemed_count"`
+	CreatedAt      time.Time `db:"created_at"      json:"created_at"`
+}
+
+type CreateCouponRequest struct {
+	Code           string     `json:"code"`
+	DiscountType   string     `json:"discount_type"`
+	Value          int        `json:"value"`
+	ExpiresAt      *time.Time `json:"expires_at"`
+	MaxRedemptions int        `json:"max_redemptions"`
+	MaxPerUser     int        `json:"max_per_user"`
+}
+
+type OrderTracking struct {
+	OrderID   OrderID   `db:"order_id"`
+	Token     string    `db:"token"`
+	RobotID   string    `db:"robot_id"`
+	PlannedAt time.Time `db:"planned_at"`
+	ETA       time.Time `db:"eta"`
+}
+
+type RobotLocation struct {
+	RobotID    string    `db:"robot_id"    json:"-"`
+	Latitude   float64   `db:"latitude"    json:"latitude"`
+	Longitude  float64   `db:"longitude"   json:"longitude"`
+	ReportedAt time.Time `db:"reported_at" json:"reported_at"`
+}
+
+type ReportLocationRequest struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
+}
+
+// 追跡ページ向けの配送状況
+// ログインなしで参照されるため、ユーザーを特定できる情報は含めない
+type TrackingInfo struct {
+	Token         string `json:"token"`
+	ProductName   string `json:"product_name"`
+	ShippedStatus string `json:"shipped_status"`
+	// 配送中でない場合は null
+	ETA       *time.Time `json:"eta"`
+	ArrivedAt *time.Time `json:"arrived_at"`
+	// ロボットが位置を報告していない場合や配送中でない場合は null
+	Location *RobotLocation `json:"location"`
+}
+
+// 通知の種類
+const NotificationOrderArrived = "order_arrived"
+
+type Notification struct {
+	ID          int64     `db:"id"`
+	UserID      UserID    `db:"user_id"`
+	Kind        string    `db:"kind"`
+	OrderID     OrderID   `db:"order_id"`
+	ProductName string    `db:"product_name"`
+	Recipient   string    `db:"recipient"`
+	Attempts    int       `db:"attempts"`
+	CreatedAt   time.Time `db:"created_at"`
+}
+
+type NotificationPreferences struct {
+	// 空の場合は通知しない
+	Email string `db:"email" json:"email"`
+	// 注文の到着時に通知するか
+	OrderArrived bool `db:"order_arrived" json:"order_arrived"`
+}
+
+type Address struct {
+	AddressID  int64     `db:"address_id"  json:"address_id"`
+	UserID     UserID    `db:"user_id"     json:"-"`
+	Label      string    `db:"label"       json:"label"`
+	Recipient  string    `db:"recipient"   json:"recipient"`
+	PostalCode string    `db:"postal_code" json:"postal_code"`
+	Prefecture string    `db:"prefecture"  json:"prefecture"`
+	City       string    `db:"city"        json:"city"`
+	Line1      string    `db:"line1"       json:"line1"`
+	Line2      string    `db:"line2"       json:"line2"`
+	Phone      string    `db:"phone"       json:"phone"`
+	IsDefault  bool      `db:"is_default"  json:"is_default"`
+	CreatedAt  time.Time `db:"created_at"  json:"created_at"`
+	UpdatedAt  time.Time `db:"updated_at"  json:"updated_at"`
+}
+
+// 配送先の作成・更新
+type AddressRequest struct {
+	Label      string `json:"label"`
+	Recipient  string `json:"recipient"`
+	PostalCode string `json:"postal_code"`
+	Prefecture string `json:"prefecture"`
+	City       string `json:"city"`
+	Line1      string `json:"line1"`
+	Line2      string `json:"line2"`
+	Phone      string `json:"phone"`
+	// 作成時のみ。最初の配送先は指定がなくても既定になる
+	IsDefault bool `json:"is_default"`
+}
+
+// 管理画面のサマリー
+type DashboardSummary struct {
+	OrdersByStatus map[string]int `json:"orders_by_status"`
+	// 直近24時間に作成された注文数
+	CreatedLast24h int `json:"created_last_24h"`
+	// 直近24時間に配送完了した注文数
+	CompletedLast24h int `json:"completed_last_24h"`
+	// 直近24時間に配送完了した注文の、作成から到着までの平均秒数 (該当なしの場合は null)
+	AvgDeliverySeconds *float64 `json:"avg_delivery_seconds"`
+	// 直近24時間にロボットが配送できずに戻した注文の数 (理由ごと)
+	ReturnsLast24h map[string]int `json:"returns_last_24h"`
+	// 直近15分以内に配送計画の取得または位置の報告があったロボットの数
+	ActiveRobots int       `json:"active_robots"`
+	GeneratedAt  time.Time `json:"generated_at"`
+}
+
+// 配送SLAを超えた注文のレポート
+type SLAReport struct {
+	SLASeconds int64     `json:"sla_seconds"`
+	From       time.Time `json:"from"`
+	// 件数が上限に達し、一部の注文を含んでいない場合は true
+	Truncated bool             `json:"truncated"`
+	Groups    []SLAReportGroup `json:"groups"`
+}
+
+// 注文の作成日 (UTC) と配送したロボットごとの集計
+type SLAReportGroup struct {
+	Date string `json:"date"`
+	// 配送計画に含まれていない注文は null
+	RobotID *string        `json:"robot_id"`
+	Count   int            `json:"count"`
+	Orders  []DelayedOrder `json:"orders"`
+}
+
+type DelayedOrder struct {
+	OrderID       OrderID    `db:"order_id"        json:"order_id"`
+	ShippedStatus string     `db:"shipped_status"  json:"shipped_status"`
+	CreatedAt     time.Time  `db:"created_at"      json:"created_at"`
+	DeliveredAt   *time.Time `db:"delivered_at"    json:"delivered_at"`
+	RobotID       *string    `db:"robot_id"        json:"-"`
+	// ロボットが最後に配送できずに戻したときの理由 (戻されていない注文は null)
+	LastReturnReason *string `db:"last_return_reason" json:"last_return_reason"`
+	// 作成から配送完了まで (未完了の場合は現在まで) の秒数
+	ElapsedSeconds int64 `db:"elapsed_seconds" json:"elapsed_seconds"`
+}
+
+type OrderVolumeSeries struct {
+	// hour または day
+	Granularity string        `json:"granularity"`
+	Series      []OrderVolume `json:"series"`
+}
+
+// 期間ごとの注文数と配送完了数
+type OrderVolume struct {
+	Start               time.Time `db:"start"                json:"start"`
+	OrdersCreated       int       `db:"orders_created"       json:"orders_created"`
+	DeliveriesCompleted int       `db:"deliveries_completed" json:"deliveries_completed"`
 }
@@ -0,0 +1,90 @@
+package notification
+
+import (
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"fmt"
+	"time"
+)
+
+type DispatcherConfig struct {
+	// 1回のポーリングで処理する最大件数
+	BatchSize int
+	// この回数失敗した通知は送信を諦める
+	MaxAttempts int
+	// 再送までの待ち時間の基準値 (失敗回数に応じて倍々に延ばす)
+	RetryBackoff time.Duration
+}
+
+// 送信待ちの通知を Sender で送信する
+type Dispatcher struct {
+	store  *repository.Store
+	sender Sender
+	cfg    DispatcherConfig
+}
+
+func NewDispatcher(store *repository.Store, sender Sender, cfg DispatcherConfig) *Dispatcher {
+	return &Dispatcher{store: store, sender: sender, cfg: cfg}
+}
+
+// 送信待ちの通知がなくなる(1バッチに満たなくなる)まで送信を続ける
+// スケジューラから PollInterval ごとに呼び出す
+func (d *Dispatcher) Drain(ctx context.Context) error {
+	for {
+		n, err := d.DispatchOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if n < d.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// 送信待ちの通知を1バッチ分送信し、処理した件数を返す
+func (d *Dispatcher) DispatchOnce(ctx context.Context) (int, error) {
+	var processed int
+	err := d.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		notifications, err := txStore.NotificationRepo.FetchPending(ctx, d.cfg.BatchSize, d.cfg.MaxAttempts)
+		if err != nil {
+			return err
+		}
+		for _, n := range notifications {
+			if sendErr := d.sender.Send(ctx, render(n)); sendErr != nil {
+				next := time.Now().Add(d.backoff(n.Attempts))
+				logging.FromContext(ctx).Warn("notification delivery failed",
+					"notification_id", n.ID, "kind", n.Kind, "attempts", n.Attempts+1, "error", sendErr)
+				if err := txStore.NotificationRepo.MarkFailed(ctx, n.ID, sendErr.Error(), next); err != nil {
+					return err
+				}
+			} else if err := txStore.NotificationRepo.MarkSent(ctx, n.ID); err != nil {
+				return err
+			}
+			processed++
+		}
+		return nil
+	})
+	return processed, err
+}
+
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	if attempts > 10 {
+		attempts = 10
+	}
+	return d.cfg.RetryBackoff << attempts
+}
+
+func render(n model.Notification) Message {
+	switch n.Kind {
+	case model.NotificationOrderArrived:
+		return Message{
+			To:      n.Recipient,
+			Subject: "ご注文の商品が到着しました",
+			Body:    fmt.Sprintf("ご注文(注文番号: %d)の「%s」が到着しました。ご利用ありがとうございました。", n.OrderID, n.ProductName),
+		}
+	default:
+		return Message{To: n.Recipient, Subject: n.Kind, Body: fmt.Sprintf("注文番号: %d", n.OrderID)}
+	}
+}
@@ -0,0 +1,31 @@
+package notification
+
+import (
+	"context"
+
+	"backend/internal/logging"
+)
+
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+// 通知の送信手段
+// エラーを返した場合はディスパッチャが時間を空けて再送する
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// SMTPサーバーに接続せず、送信内容をログに出すだけの Sender
+// 実際のSMTPサーバーを用意するまでの間に使う
+type SMTPStub struct {
+	From string
+}
+
+func (s SMTPStub) Send(ctx context.Context, msg Message) error {
+	logging.FromContext(ctx).Info("notification (smtp stub)",
+		"from", s.From, "to", msg.To, "subject", msg.Subject, "body", msg.Body)
+	return nil
+}
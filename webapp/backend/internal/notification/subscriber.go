@@ -0,0 +1,19 @@
+package notification
+
+import (
+	"context"
+
+	"backend/internal/event"
+	"backend/internal/repository"
+)
+
+// 注文が配送完了になったら、同じトランザクション内で到着通知を送信待ちにする
+func Subscribe(bus *event.Bus) {
+	bus.SubscribeTx(func(ctx context.Context, tx *repository.Store, e event.Event) error {
+		changed, ok := e.(event.OrderStatusChanged)
+		if !ok || changed.NewStatus != "completed" {
+			return nil
+		}
+		return tx.NotificationRepo.EnqueueOrderArrived(ctx, changed.OrderID)
+	}, event.TypeOrderStatusChanged)
+}
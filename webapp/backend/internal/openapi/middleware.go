@@ -0,0 +1,107 @@
+package openapi
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+
+	"backend/internal/apierror"
+	"backend/internal/logging"
+)
+
+// リクエストボディの上限
+const maxBodyBytes = 1 << 20
+
+type bodyKey struct{}
+
+// リクエストボディを schema で検証し、T にデコードしてコンテキストに格納する
+// 検証に失敗した場合はハンドラを呼ばずに400を返す (ボディが maxBodyBytes を超える場合は413)
+// ハンドラでは Body[T] で取り出す
+func ValidateBody[T any](schema *Schema) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			raw, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodyBytes))
+			if err != nil {
+				apierror.WriteBodyError(w, r, err)
+				return
+			}
+
+			dec := json.NewDecoder(bytes.NewReader(raw))
+			dec.UseNumber()
+			var doc any
+			if err := dec.Decode(&doc); err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+				return
+			}
+			if errs := schema.Validate(doc); len(errs) > 0 {
+				rejectInvalid(w, r, errs)
+				return
+			}
+
+			var body T
+			if err := json.Unmarshal(raw, &body); err != nil {
+				apierror.Write(w, r, http.StatusBadRequest, apierror.CodeBadRequest, "Invalid request body")
+				return
+			}
+			ctx := context.WithValue(r.Context(), bodyKey{}, body)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// ValidateBody で検証済みのリクエストボディを取り出す
+func Body[T any](ctx context.Context) (T, bool) {
+	body, ok := ctx.Value(bodyKey{}).(T)
+	return body, ok
+}
+
+// クエリパラメータを検証する
+func ValidateQuery(params ...Parameter) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			query := r.URL.Query()
+			var errs []FieldError
+			for _, p := range params {
+				raw := query.Get(p.Name)
+				if raw == "" {
+					if p.Required {
+						errs = append(errs, FieldError{Field: p.Name, Message: "is required"})
+					}
+					continue
+				}
+				var v any = raw
+				if p.Schema.Type == "integer" || p.Schema.Type == "number" {
+					v = json.Number(raw)
+				} else if p.Schema.Type == "boolean" {
+					b, err := strconv.ParseBool(raw)
+					if err != nil {
+						errs = append(errs, FieldError{Field: p.Name, Message: "must be a boolean"})
+						continue
+					}
+					v = b
+				}
+				for _, e := range p.Schema.Validate(v) {
+					e.Field = p.Name
+					errs = append(errs, e)
+				}
+			}
+			if len(errs) > 0 {
+				rejectInvalid(w, r, errs)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func rejectInvalid(w http.ResponseWriter, r *http.Request, errs []FieldError) {
+	details := make([]apierror.Detail, len(errs))
+	for i, e := range errs {
+		details[i] = apierror.Detail{Field: e.Field, Message: e.Message}
+	}
+	logging.FromContext(r.Context()).Info("Request validation failed", "errors", details)
+	apierror.Write(w, r, http.StatusBadRequest, apierror.CodeValidation, "Invalid request", details...)
+}
@@ -0,0 +1,147 @@
+package openapi
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+)
+
+// OpenAPI 3 の Schema Object のうち、このAPIで使う部分
+// 仕様書の出力とリクエストの検証の両方に使う
+type Schema struct {
+	Type        string             `json:"type,omitempty"`
+	Format      string             `json:"format,omitempty"`
+	Description string             `json:"description,omitempty"`
+	Properties  map[string]*Schema `json:"properties,omitempty"`
+	Required    []string           `json:"required,omitempty"`
+	Items       *Schema            `json:"items,omitempty"`
+	Enum        []any              `json:"enum,omitempty"`
+	Minimum     *float64           `json:"minimum,omitempty"`
+	Maximum     *float64           `json:"maximum,omitempty"`
+	MaxLength   *int               `json:"maxLength,omitempty"`
+	Default     any                `json:"default,omitempty"`
+	Nullable    bool               `json:"nullable,omitempty"`
+}
+
+// 検証に失敗したフィールドと理由
+type FieldError struct {
+	// JSON上の位置 (例: items[0].quantity)
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func (e FieldError) Error() string {
+	return e.Field + ": " + e.Message
+}
+
+func ptr[T any](v T) *T { return &v }
+
+// json.Decoder.UseNumber でデコードした値を検証する
+func (s *Schema) Validate(v any) []FieldError {
+	var errs []FieldError
+	s.validate(v, "", &errs)
+	return errs
+}
+
+func (s *Schema) validate(v any, path string, errs *[]FieldError) {
+	fail := func(format string, args ...any) {
+		field := path
+		if field == "" {
+			field = "(body)"
+		}
+		*errs = append(*errs, FieldError{Field: field, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if v == nil {
+		if !s.Nullable && path == "" {
+			fail("must not be null")
+		}
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		obj, ok := v.(map[string]any)
+		if !ok {
+			fail("must be an object")
+			return
+		}
+		for _, name := range s.Required {
+			if _, ok := obj[name]; !ok {
+				*errs = append(*errs, FieldError{Field: join(path, name), Message: "is required"})
+			}
+		}
+		names := make([]string, 0, len(s.Properties))
+		for name := range s.Properties {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			if value, ok := obj[name]; ok {
+				s.Properties[name].validate(value, join(path, name), errs)
+			}
+		}
+	case "array":
+		arr, ok := v.([]any)
+		if !ok {
+			fail("must be an array")
+			return
+		}
+		if s.Items != nil {
+			for i, item := range arr {
+				s.Items.validate(item, path+"["+strconv.Itoa(i)+"]", errs)
+			}
+		}
+	case "string":
+		str, ok := v.(string)
+		if !ok {
+			fail("must be a string")
+			return
+		}
+		if s.MaxLength != nil && len([]rune(str)) > *s.MaxLength {
+			fail("must be at most %d characters", *s.MaxLength)
+		}
+		if len(s.Enum) > 0 && !containsEnum(s.Enum, str) {
+			fail("must be one of %v", s.Enum)
+		}
+	case "integer", "number":
+		num, ok := v.(json.Number)
+		if !ok {
+			fail("must be a %s", s.Type)
+			return
+		}
+		f, err := num.Float64()
+		if err != nil || (s.Type == "integer" && (f != math.Trunc(f) || math.Abs(f) > 1<<53)) {
+			fail("must be a %s", s.Type)
+			return
+		}
+		if s.Minimum != nil && f < *s.Minimum {
+			fail("must be >= %v", *s.Minimum)
+		}
+		if s.Maximum != nil && f > *s.Maximum {
+			fail("must be <= %v", *s.Maximum)
+		}
+	case "boolean":
+		if _, ok := v.(bool); !ok {
+			fail("must be a boolean")
+		}
+	}
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
+
+func containsEnum(enum []any, v string) bool {
+	for _, e := range enum {
+		if e == v {
+			return true
+		}
+	}
+	return false
+}
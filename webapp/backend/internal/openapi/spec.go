@@ -0,0 +1,639 @@
+package openapi
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+)
+
+// リクエストのスキーマ
+// sort_field などの値の範囲は呼び出し側(リポジトリ)で既定値に丸めるため、ここでは型だけを検証する
+var (
+	LoginRequest = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"user_name": {Type: "string", MaxLength: ptr(255)},
+			"password":  {Type: "string", MaxLength: ptr(255)},
+		},
+	}
+
+	ListRequest = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"search":     {Type: "string", Description: "検索ワード", MaxLength: ptr(255)},
+			"type":       {Type: "string", Description: "検索タイプ (partial, prefix)"},
+			"page":       {Type: "integer", Description: "ページ番号 (省略時は1)", Default: 1},
+			"page_size":  {Type: "integer", Description: "1ページあたりの件数 (省略時は20、最大100。v1では超えた分を100に丸め、v2ではエラー)", Default: 20},
+			"sort_field": {Type: "string", Description: "ソート対象のフィールド (v1では未知の値は既定のソート順になる)"},
+			"sort_order": {Type: "string", Description: "ソート順", Enum: []any{"", "asc", "desc", "ASC", "DESC"}},
+			"cursor":     {Type: "string", Description: "前のレスポンスの next_cursor (v2のみ)", MaxLength: ptr(64)},
+			"exact":      {Type: "boolean", Description: "該当件数が多い場合も総数を正確に数える (v2の商品一覧のみ)"},
+		},
+	}
+
+	CreateOrderRequest = &Schema{
+		Type:     "object",
+		Required: []string{"items"},
+		Properties: map[string]*Schema{
+			"items": {
+				Type: "array",
+				Items: &Schema{
+					Type:     "object",
+					Required: []string{"product_id", "quantity"},
+					Properties: map[string]*Schema{
+						"product_id": {Type: "integer", Minimum: ptr(1.0)},
+						"quantity":   {Type: "integer", Minimum: ptr(0.0)},
+					},
+				},
+			},
+			"coupon_code": {Type: "string", Description: "適用するクーポンのコード (省略可)", MaxLength: ptr(32)},
+			"note":        {Type: "string", Description: "配送時の備考 (省略可。作成する全ての注文に付ける)", MaxLength: ptr(500)},
+			"address_id":  {Type: "integer", Description: "配送先 (省略時は既定の配送先)", Minimum: ptr(1.0)},
+		},
+	}
+
+	UpdateOrderStatusRequest = &Schema{
+		Type:     "object",
+		Required: []string{"order_id", "new_status"},
+		Properties: map[string]*Schema{
+			"order_id":   {Type: "integer", Description: "注文ID", Minimum: ptr(1.0)},
+			"new_status": {Type: "string", Description: "新しい注文ステータス", MaxLength: ptr(50)},
+		},
+	}
+
+	ReturnOrdersRequest = &Schema{
+		Type:     "object",
+		Required: []string{"orders"},
+		Properties: map[string]*Schema{
+			"orders": {
+				Type:        "array",
+				Description: "配送できなかった注文 (1000件まで)",
+				Items: &Schema{
+					Type:     "object",
+					Required: []string{"order_id", "reason"},
+					Properties: map[string]*Schema{
+						"order_id": {Type: "integer", Description: "注文ID", Minimum: ptr(1.0)},
+						"reason":   {Type: "string", Description: "配送できなかった理由", Enum: []any{"address_unreachable", "recipient_absent", "item_damaged", "other"}},
+					},
+				},
+			},
+		},
+	}
+
+	ReturnOrdersResult = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"requeued": {Type: "array", Items: &Schema{Type: "integer"}, Description: "配送待ちに戻した注文"},
+			"failed":   {Type: "array", Items: &Schema{Type: "integer"}, Description: "配送の試行回数が上限に達したため failed にした注文"},
+			"skipped":  {Type: "array", Items: &Schema{Type: "integer"}, Description: "このロボットが配送中でないため何もしなかった注文"},
+		},
+	}
+
+	UpdateTimezoneRequest = &Schema{
+		Type:     "object",
+		Required: []string{"timezone"},
+		Properties: map[string]*Schema{
+			"timezone": {Type: "string", Description: "IANAのタイムゾーン名 (例: Asia/Tokyo)", MaxLength: ptr(64)},
+		},
+	}
+
+	NotificationPreferences = &Schema{
+		Type:     "object",
+		Required: []string{"email", "order_arrived"},
+		Properties: map[string]*Schema{
+			"email":         {Type: "string", Description: "通知先のメールアドレス (空の場合は通知しない)", MaxLength: ptr(255)},
+			"order_arrived": {Type: "boolean", Description: "注文の到着時に通知するか"},
+		},
+	}
+
+	AddressRequest = &Schema{
+		Type:     "object",
+		Required: []string{"recipient", "postal_code", "prefecture", "city", "line1"},
+		Properties: map[string]*Schema{
+			"label":       {Type: "string", Description: "自宅・勤務先など", MaxLength: ptr(64)},
+			"recipient":   {Type: "string", Description: "受取人の氏名", MaxLength: ptr(255)},
+			"postal_code": {Type: "string", MaxLength: ptr(16)},
+			"prefecture":  {Type: "string", MaxLength: ptr(32)},
+			"city":        {Type: "string", MaxLength: ptr(255)},
+			"line1":       {Type: "string", Description: "番地", MaxLength: ptr(255)},
+			"line2":       {Type: "string", Description: "建物名・部屋番号", MaxLength: ptr(255)},
+			"phone":       {Type: "string", MaxLength: ptr(32)},
+			"is_default":  {Type: "boolean", Description: "作成時のみ有効。最初の配送先は常に既定になる"},
+		},
+	}
+
+	Address = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"address_id":  {Type: "integer"},
+			"label":       {Type: "string"},
+			"recipient":   {Type: "string"},
+			"postal_code": {Type: "string"},
+			"prefecture":  {Type: "string"},
+			"city":        {Type: "string"},
+			"line1":       {Type: "string"},
+			"line2":       {Type: "string"},
+			"phone":       {Type: "string"},
+			"is_default":  {Type: "boolean"},
+			"created_at":  {Type: "string", Format: "date-time"},
+			"updated_at":  {Type: "string", Format: "date-time"},
+		},
+	}
+
+	UserProfile = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"user_id":   {Type: "integer"},
+			"user_name": {Type: "string"},
+			"timezone":  {Type: "string"},
+		},
+	}
+
+	AddCartItemRequest = &Schema{
+		Type:     "object",
+		Required: []string{"product_id", "quantity"},
+		Properties: map[string]*Schema{
+			"product_id": {Type: "integer", Minimum: ptr(1.0)},
+			"quantity":   {Type: "integer", Minimum: ptr(1.0), Maximum: ptr(100.0)},
+		},
+	}
+
+	UpdateCartItemRequest = &Schema{
+		Type:     "object",
+		Required: []string{"quantity"},
+		Properties: map[string]*Schema{
+			"quantity": {Type: "integer", Description: "0の場合はカートから削除する", Minimum: ptr(0.0), Maximum: ptr(100.0)},
+		},
+	}
+
+	Cart = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"items": {
+				Type: "array",
+				Items: &Schema{
+					Type: "object",
+					Properties: map[string]*Schema{
+						"product_id": {Type: "integer"},
+						"name":       {Type: "string"},
+						"value":      {Type: "integer"},
+						"weight":     {Type: "integer"},
+						"image":      {Type: "string"},
+						"quantity":   {Type: "integer"},
+					},
+				},
+			},
+			"total_value":  {Type: "integer"},
+			"total_weight": {Type: "integer"},
+		},
+	}
+
+	ReportLocationRequest = &Schema{
+		Type:     "object",
+		Required: []string{"latitude", "longitude"},
+		Properties: map[string]*Schema{
+			"latitude":  {Type: "number", Minimum: ptr(-90.0), Maximum: ptr(90.0)},
+			"longitude": {Type: "number", Minimum: ptr(-180.0), Maximum: ptr(180.0)},
+		},
+	}
+
+	Tracking = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"token":          {Type: "string"},
+			"product_name":   {Type: "string"},
+			"shipped_status": {Type: "string"},
+			"eta":            {Type: "string", Format: "date-time", Nullable: true, Description: "配送中でない場合は null"},
+			"arrived_at":     {Type: "string", Format: "date-time", Nullable: true},
+			"location": {
+				Type:        "object",
+				Nullable:    true,
+				Description: "ロボットが最後に報告した位置",
+				Properties: map[string]*Schema{
+					"latitude":    {Type: "number"},
+					"longitude":   {Type: "number"},
+					"reported_at": {Type: "string", Format: "date-time"},
+				},
+			},
+		},
+	}
+
+	Product = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"product_id":  {Type: "integer"},
+			"name":        {Type: "string"},
+			"value":       {Type: "integer"},
+			"weight":      {Type: "integer"},
+			"image":       {Type: "string"},
+			"description": {Type: "string"},
+		},
+	}
+
+	// v2の商品一覧の要素 (画像と説明文は商品の詳細で取得する)
+	ProductSummary = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"product_id": {Type: "integer"},
+			"name":       {Type: "string"},
+			"value":      {Type: "integer"},
+			"weight":     {Type: "integer"},
+		},
+	}
+
+	Order = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"order_id":       {Type: "integer"},
+			"user_id":        {Type: "integer"},
+			"product_id":     {Type: "integer"},
+			"product_name":   {Type: "string"},
+			"shipped_status": {Type: "string"},
+			"weight":         {Type: "integer"},
+			"value":          {Type: "integer"},
+			"discount":       {Type: "integer", Description: "クーポンによる割引額 (割引がない場合は省略)"},
+			"note":           {Type: "string", Description: "配送時の備考 (ない場合は省略)"},
+			"created_at":     {Type: "string", Format: "date-time"},
+			"arrived_at": {
+				Type: "object",
+				Properties: map[string]*Schema{
+					"Time":  {Type: "string", Format: "date-time"},
+					"Valid": {Type: "boolean"},
+				},
+			},
+		},
+	}
+
+	DeliveryPlan = &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"robot_id":     {Type: "string"},
+			"total_weight": {Type: "integer"},
+			"total_value":  {Type: "integer"},
+			"orders":       {Type: "array", Items: Order},
+		},
+	}
+)
+
+// パスパラメータ
+var cartProductParam = Parameter{Name: "productID", In: "path", Required: true, Description: "商品ID", Schema: &Schema{Type: "integer"}}
+
+// クエリパラメータ
+var (
+	CapacityParam    = Parameter{Name: "capacity", In: "query", Required: true, Description: "ロボットの最大積載量", Schema: &Schema{Type: "integer", Minimum: ptr(0.0)}}
+	ImagePathParam   = Parameter{Name: "path", In: "query", Required: true, Description: "画像ファイルのパス", Schema: &Schema{Type: "string"}}
+	CouponParam      = Parameter{Name: "coupon_code", In: "query", Description: "適用するクーポンのコード", Schema: &Schema{Type: "string", MaxLength: ptr(32)}}
+	NoteParam        = Parameter{Name: "note", In: "query", Description: "配送時の備考", Schema: &Schema{Type: "string", MaxLength: ptr(500)}}
+	AddressParam     = Parameter{Name: "address_id", In: "query", Description: "配送先 (省略時は既定の配送先)", Schema: &Schema{Type: "integer", Minimum: ptr(1.0)}}
+	ReportDaysParam  = Parameter{Name: "days", In: "query", Description: "集計する日数 (既定は7日)", Schema: &Schema{Type: "integer", Minimum: ptr(1.0), Maximum: ptr(31.0)}}
+	AfterOrderParam  = Parameter{Name: "after", In: "query", Description: "この注文IDより後の注文から返す", Schema: &Schema{Type: "integer", Minimum: ptr(0.0)}}
+	ZoneParam        = Parameter{Name: "zone", In: "query", Description: "出荷元の倉庫のコード (省略時は全ての倉庫)", Schema: &Schema{Type: "string", MaxLength: ptr(32)}}
+	GranularityParam = Parameter{Name: "granularity", In: "query", Description: "集計単位 (既定は hour)", Schema: &Schema{Type: "string", Enum: []any{"hour", "day"}}}
+	FromParam        = Parameter{Name: "from", In: "query", Description: "集計の開始日時 (RFC 3339)", Schema: &Schema{Type: "string", Format: "date-time"}}
+	ToParam          = Parameter{Name: "to", In: "query", Description: "集計の終了日時 (RFC 3339。この日時を含まない)", Schema: &Schema{Type: "string", Format: "date-time"}}
+	ProductIDsParam  = Parameter{Name: "ids", In: "query", Required: true, Description: "カンマ区切りの商品ID (最大100件。例: 1,2,3)", Schema: &Schema{Type: "string"}}
+)
+
+type Document struct {
+	OpenAPI    string              `json:"openapi"`
+	Info       Info                `json:"info"`
+	Paths      map[string]PathItem `json:"paths"`
+	Components Components          `json:"components"`
+}
+
+type Info struct {
+	Title       string `json:"title"`
+	Version     string `json:"version"`
+	Description string `json:"description,omitempty"`
+}
+
+type PathItem map[string]*Operation
+
+type Operation struct {
+	Summary     string              `json:"summary"`
+	Security    []map[string][]any  `json:"security,omitempty"`
+	Parameters  []Parameter         `json:"parameters,omitempty"`
+	RequestBody *RequestBody        `json:"requestBody,omitempty"`
+	Responses   map[string]Response `json:"responses"`
+}
+
+type Parameter struct {
+	Name        string  `json:"name"`
+	In          string  `json:"in"`
+	Required    bool    `json:"required,omitempty"`
+	Description string  `json:"description,omitempty"`
+	Schema      *Schema `json:"schema"`
+}
+
+type RequestBody struct {
+	Required bool                 `json:"required"`
+	Content  map[string]MediaType `json:"content"`
+}
+
+type Response struct {
+	Description string               `json:"description"`
+	Content     map[string]MediaType `json:"content,omitempty"`
+}
+
+type MediaType struct {
+	Schema *Schema `json:"schema"`
+}
+
+type Components struct {
+	SecuritySchemes map[string]SecurityScheme `json:"securitySchemes"`
+}
+
+type SecurityScheme struct {
+	Type string `json:"type"`
+	In   string `json:"in"`
+	Name string `json:"name"`
+}
+
+func jsonBody(s *Schema) *RequestBody {
+	return &RequestBody{Required: true, Content: map[string]MediaType{"application/json": {Schema: s}}}
+}
+
+func jsonResponse(description string, s *Schema) map[string]Response {
+	return map[string]Response{"200": {Description: description, Content: map[string]MediaType{"application/json": {Schema: s}}}}
+}
+
+func listOf(s *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data":  {Type: "array", Items: s},
+			"total": {Type: "integer"},
+		},
+	}
+}
+
+var (
+	session = []map[string][]any{{"session": {}}}
+	apiKey  = []map[string][]any{{"robotApiKey": {}}}
+)
+
+// v2の一覧レスポンス
+func pageOf(s *Schema) *Schema {
+	return &Schema{
+		Type: "object",
+		Properties: map[string]*Schema{
+			"data":              {Type: "array", Items: s},
+			"total":             {Type: "integer"},
+			"total_approximate": {Type: "boolean", Description: "true の場合、total は総数の下限 (該当件数が多い商品検索のみ)"},
+			"page":              {Type: "integer"},
+			"page_size":         {Type: "integer"},
+			"next_cursor":       {Type: "string", Nullable: true, Description: "次のページがない場合は null"},
+		},
+	}
+}
+
+// ユーザー向けAPIの操作
+func userOperations(version string) map[string]PathItem {
+	prefix := "/api/" + version
+	list := listOf
+	listedProduct := Product
+	if version != "v1" {
+		list = pageOf
+		listedProduct = ProductSummary
+	}
+	ops := map[string]PathItem{
+		prefix + "/product": {"post": {
+			Summary:     "商品一覧取得",
+			Security:    session,
+			RequestBody: jsonBody(ListRequest),
+			Responses:   jsonResponse("商品一覧", list(listedProduct)),
+		}},
+		prefix + "/product/post": {"post": {
+			Summary:     "注文作成",
+			Security:    session,
+			RequestBody: jsonBody(CreateOrderRequest),
+			Responses: map[string]Response{
+				"201": {Description: "注文作成成功 (直前に同じ注文があった場合は X-Possible-Duplicate: true を付ける)"},
+				"409": {Description: "直前に同じ注文があったため作成しなかった (二重送信の検出が有効な場合)、または在庫が足りない"},
+			},
+		}},
+		prefix + "/orders": {"post": {
+			Summary:     "注文履歴取得",
+			Security:    session,
+			RequestBody: jsonBody(ListRequest),
+			Responses:   jsonResponse("注文履歴一覧", list(Order)),
+		}},
+		prefix + "/image": {"get": {
+			Summary:    "画像ファイルを取得",
+			Security:   session,
+			Parameters: []Parameter{ImagePathParam},
+			Responses:  map[string]Response{"200": {Description: "画像ファイル本体"}},
+		}},
+	}
+	if version != "v1" {
+		ops[prefix+"/me"] = PathItem{"get": {
+			Summary:   "ログイン中のユーザー情報取得",
+			Security:  session,
+			Responses: jsonResponse("ユーザー情報", UserProfile),
+		}}
+		ops[prefix+"/me/timezone"] = PathItem{"put": {
+			Summary:     "表示用タイムゾーンの更新",
+			Security:    session,
+			RequestBody: jsonBody(UpdateTimezoneRequest),
+			Responses:   map[string]Response{"204": {Description: "更新成功 (以降の注文履歴の日時はこのタイムゾーンで返す)"}},
+		}}
+		ops[prefix+"/me/notifications"] = PathItem{
+			"get": {
+				Summary:   "通知設定の取得",
+				Security:  session,
+				Responses: jsonResponse("通知設定", NotificationPreferences),
+			},
+			"put": {
+				Summary:     "通知設定の更新",
+				Security:    session,
+				RequestBody: jsonBody(NotificationPreferences),
+				Responses:   map[string]Response{"204": {Description: "更新成功"}},
+			},
+		}
+		addressIDParam := Parameter{Name: "addressID", In: "path", Required: true, Description: "配送先ID", Schema: &Schema{Type: "integer"}}
+		ops[prefix+"/addresses"] = PathItem{
+			"get": {
+				Summary:   "配送先の一覧取得",
+				Security:  session,
+				Responses: jsonResponse("配送先の一覧 (既定の配送先が先頭)", &Schema{Type: "array", Items: Address}),
+			},
+			"post": {
+				Summary:     "配送先の作成",
+				Security:    session,
+				RequestBody: jsonBody(AddressRequest),
+				Responses:   map[string]Response{"201": {Description: "作成した配送先", Content: map[string]MediaType{"application/json": {Schema: Address}}}},
+			},
+		}
+		ops[prefix+"/addresses/{addressID}"] = PathItem{
+			"put": {
+				Summary:     "配送先の更新",
+				Security:    session,
+				Parameters:  []Parameter{addressIDParam},
+				RequestBody: jsonBody(AddressRequest),
+				Responses:   jsonResponse("更新後の配送先", Address),
+			},
+			"delete": {
+				Summary:    "配送先の削除",
+				Security:   session,
+				Parameters: []Parameter{addressIDParam},
+				Responses:  map[string]Response{"204": {Description: "削除成功"}},
+			},
+		}
+		ops[prefix+"/addresses/{addressID}/default"] = PathItem{"put": {
+			Summary:    "既定の配送先の変更",
+			Security:   session,
+			Parameters: []Parameter{addressIDParam},
+			Responses:  map[string]Response{"204": {Description: "変更成功"}},
+		}}
+		ops[prefix+"/products"] = PathItem{"get": {
+			Summary:    "商品をまとめて取得",
+			Security:   session,
+			Parameters: []Parameter{ProductIDsParam},
+			Responses:  jsonResponse("指定した順の商品 (存在しない商品は含まない)", &Schema{Type: "array", Items: Product}),
+		}}
+		ops[prefix+"/products/{productID}"] = PathItem{"get": {
+			Summary:    "商品の詳細取得",
+			Security:   session,
+			Parameters: []Parameter{{Name: "productID", In: "path", Required: true, Description: "商品ID", Schema: &Schema{Type: "integer"}}},
+			Responses:  jsonResponse("商品 (画像と説明文を含む)", Product),
+		}}
+		ops[prefix+"/orders/{orderID}"] = PathItem{"get": {
+			Summary:    "注文の詳細取得",
+			Security:   session,
+			Parameters: []Parameter{{Name: "orderID", In: "path", Required: true, Description: "注文ID", Schema: &Schema{Type: "integer"}}},
+			Responses:  jsonResponse("注文 (備考を含む)", Order),
+		}}
+		ops[prefix+"/orders/{orderID}/tracking"] = PathItem{"get": {
+			Summary:    "注文の追跡トークン取得",
+			Security:   session,
+			Parameters: []Parameter{{Name: "orderID", In: "path", Required: true, Description: "注文ID", Schema: &Schema{Type: "integer"}}},
+			Responses:  map[string]Response{"200": {Description: "追跡トークンと追跡URL"}, "404": {Description: "配送計画に入っていない注文"}},
+		}}
+		ops[prefix+"/orders/{orderID}/receipt"] = PathItem{"get": {
+			Summary:    "注文の領収書 (印刷用HTML)",
+			Security:   session,
+			Parameters: []Parameter{{Name: "orderID", In: "path", Required: true, Description: "注文ID", Schema: &Schema{Type: "integer"}}},
+			Responses:  map[string]Response{"200": {Description: "領収書のHTML"}, "404": {Description: "注文が存在しない"}},
+		}}
+		ops[prefix+"/graphql"] = PathItem{"post": {
+			Summary:   "GraphQL (注文・商品・注文数をまとめて取得)",
+			Security:  session,
+			Responses: map[string]Response{"200": {Description: "GraphQLのレスポンス (エラーは errors に含まれる)"}},
+		}}
+		ops[prefix+"/cart"] = PathItem{"get": {
+			Summary:   "カートの取得",
+			Security:  session,
+			Responses: jsonResponse("カートの中身", Cart),
+		}}
+		ops[prefix+"/cart/items"] = PathItem{"post": {
+			Summary:     "商品をカートに追加",
+			Security:    session,
+			RequestBody: jsonBody(AddCartItemRequest),
+			Responses:   jsonResponse("追加後のカート", Cart),
+		}}
+		ops[prefix+"/cart/items/{productID}"] = PathItem{
+			"put": {
+				Summary:     "カート内の商品の数量を変更",
+				Security:    session,
+				Parameters:  []Parameter{cartProductParam},
+				RequestBody: jsonBody(UpdateCartItemRequest),
+				Responses:   jsonResponse("変更後のカート", Cart),
+			},
+			"delete": {
+				Summary:    "カートから商品を削除",
+				Security:   session,
+				Parameters: []Parameter{cartProductParam},
+				Responses:  jsonResponse("削除後のカート", Cart),
+			},
+		}
+		ops[prefix+"/cart/checkout"] = PathItem{"post": {
+			Summary:    "カートの中身で注文を確定",
+			Security:   session,
+			Parameters: []Parameter{CouponParam, NoteParam, AddressParam},
+			Responses: map[string]Response{
+				"201": {Description: "注文作成成功 (カートは空になる)"},
+				"409": {Description: "在庫が足りない"},
+			},
+		}}
+	}
+	return ops
+}
+
+// APIの定義全体
+// ルーティングを追加・変更した場合はここも更新すること
+func Spec() *Document {
+	doc := &Document{
+		OpenAPI: "3.0.3",
+		Info: Info{
+			Title:       "倉庫管理 API",
+			Version:     "2.0.0",
+			Description: "商品一覧・注文・ロボット配送・認証を提供するAPI",
+		},
+		Paths: map[string]PathItem{
+			"/api/login": {"post": {
+				Summary:     "ログイン",
+				RequestBody: jsonBody(LoginRequest),
+				Responses:   map[string]Response{"200": {Description: "ログイン成功 (Set-CookieでセッションIDを返す)"}},
+			}},
+			"/api/robot/delivery-plan": {"get": {
+				Summary:    "配送計画の取得",
+				Security:   apiKey,
+				Parameters: []Parameter{CapacityParam},
+				Responses:  jsonResponse("配送計画", DeliveryPlan),
+			}},
+			"/api/robot/shipping-orders/stream": {"get": {
+				Summary:    "配送待ちの注文をNDJSONで取得",
+				Security:   apiKey,
+				Parameters: []Parameter{AfterOrderParam, ZoneParam},
+				Responses:  map[string]Response{"200": {Description: "1行に1件の注文 (order_id, weight, value, zone) を注文ID順に返す"}},
+			}},
+			"/api/robot/location": {"put": {
+				Summary:     "ロボットの現在位置の報告",
+				Security:    apiKey,
+				RequestBody: jsonBody(ReportLocationRequest),
+				Responses:   map[string]Response{"204": {Description: "記録成功"}},
+			}},
+			"/api/track/{token}": {"get": {
+				Summary:    "配送状況の追跡 (ログイン不要)",
+				Parameters: []Parameter{{Name: "token", In: "path", Required: true, Description: "追跡トークン", Schema: &Schema{Type: "string"}}},
+				Responses:  jsonResponse("配送状況", Tracking),
+			}},
+			"/api/robot/orders/return": {"post": {
+				Summary:     "配送できなかった注文を理由とともに配送待ちに戻す (試行回数が上限に達した注文は failed にする)",
+				Security:    apiKey,
+				RequestBody: jsonBody(ReturnOrdersRequest),
+				Responses:   jsonResponse("戻した結果", ReturnOrdersResult),
+			}},
+			"/api/robot/orders/status": {"patch": {
+				Summary:     "注文ステータスの更新",
+				Security:    apiKey,
+				RequestBody: jsonBody(UpdateOrderStatusRequest),
+				Responses:   map[string]Response{"200": {Description: "ステータス更新成功"}},
+			}},
+		},
+		Components: Components{SecuritySchemes: map[string]SecurityScheme{
+			"session":     {Type: "apiKey", In: "cookie", Name: "session_id"},
+			"robotApiKey": {Type: "apiKey", In: "header", Name: "X-API-KEY"},
+		}},
+	}
+	for _, version := range []string{"v1", "v2"} {
+		for path, item := range userOperations(version) {
+			doc.Paths[path] = item
+		}
+	}
+	return doc
+}
+
+var (
+	specOnce sync.Once
+	specJSON []byte
+)
+
+// 仕様書をJSONで返す
+func Handler(w http.ResponseWriter, r *http.Request) {
+	specOnce.Do(func() {
+		specJSON, _ = json.MarshalIndent(Spec(), "", "  ")
+	})
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(specJSON)
+}
@@ -0,0 +1,76 @@
+package outbox
+
+import (
+	"backend/internal/logging"
+	"backend/internal/model"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// 配信時のJSON形式
+type envelope struct {
+	ID          int64           `json:"id"`
+	EventType   string          `json:"event_type"`
+	AggregateID string          `json:"aggregate_id"`
+	Payload     json.RawMessage `json:"payload"`
+	CreatedAt   time.Time       `json:"created_at"`
+}
+
+func newEnvelope(event model.OutboxEvent) envelope {
+	return envelope{
+		ID:          event.ID,
+		EventType:   event.EventType,
+		AggregateID: event.AggregateID,
+		Payload:     json.RawMessage(event.Payload),
+		CreatedAt:   event.CreatedAt,
+	}
+}
+
+// イベントをWebhookとしてPOSTする
+// 受信側はX-Event-IDで重複を排除すること (少なくとも1回の配信)
+type WebhookPublisher struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookPublisher(url string, timeout time.Duration) *WebhookPublisher {
+	return &WebhookPublisher{url: url, client: &http.Client{Timeout: timeout}}
+}
+
+func (p *WebhookPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	body, err := json.Marshal(newEnvelope(event))
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatInt(event.ID, 10))
+	req.Header.Set("X-Event-Type", event.EventType)
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook responded with status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// 配信先が設定されていない場合に使う、ログに出すだけのPublisher
+type LogPublisher struct{}
+
+func (LogPublisher) Publish(ctx context.Context, event model.OutboxEvent) error {
+	logging.FromContext(ctx).Debug("outbox event",
+		"event_id", event.ID, "event_type", event.EventType, "aggregate_id", event.AggregateID)
+	return nil
+}
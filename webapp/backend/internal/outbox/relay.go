@@ -0,0 +1,100 @@
+package outbox
+
+import (
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"context"
+	"time"
+)
+
+// outboxのイベントを外部に配信する
+type Publisher interface {
+	Publish(ctx context.Context, event model.OutboxEvent) error
+}
+
+type RelayConfig struct {
+	// 1回のポーリングで処理する最大件数
+	BatchSize int
+	// この回数失敗したイベントは配信を諦める
+	MaxAttempts int
+	// 再試行までの待ち時間の基準値 (失敗回数に応じて倍々に延ばす)
+	RetryBackoff time.Duration
+	// 取得したイベントを配信中として他のリレーから隠す時間
+	// 1バッチの配信にかかる時間より長くすること (過ぎると別のリレーが再配信する)
+	Lease time.Duration
+}
+
+// 未配信のイベントを Publisher に渡して配信済みにする
+type Relay struct {
+	store     *repository.Store
+	publisher Publisher
+	cfg       RelayConfig
+}
+
+func NewRelay(store *repository.Store, publisher Publisher, cfg RelayConfig) *Relay {
+	return &Relay{store: store, publisher: publisher, cfg: cfg}
+}
+
+// 未配信のイベントがなくなる(1バッチに満たなくなる)まで配信を続ける
+// スケジューラから PollInterval ごとに呼び出す
+func (r *Relay) Drain(ctx context.Context) error {
+	for {
+		n, err := r.RelayOnce(ctx)
+		if err != nil {
+			return err
+		}
+		if n < r.cfg.BatchSize {
+			return nil
+		}
+	}
+}
+
+// 未配信のイベントを1バッチ分配信し、処理した件数を返す
+// 行ロックは取得して配信中にする間だけ持ち、配信はトランザクションの外で行う
+// 結果はイベントごとに記録するため、記録に失敗したイベントだけがリースの期限後に再配信される
+func (r *Relay) RelayOnce(ctx context.Context) (int, error) {
+	events, err := r.claim(ctx)
+	if err != nil {
+		return 0, err
+	}
+	log := logging.FromContext(ctx)
+	for _, event := range events {
+		if pubErr := r.publisher.Publish(ctx, event); pubErr != nil {
+			next := time.Now().Add(r.backoff(event.Attempts))
+			log.Warn("outbox event delivery failed",
+				"event_id", event.ID, "event_type", event.EventType, "attempts", event.Attempts+1, "error", pubErr)
+			if err := r.store.OutboxRepo.MarkFailed(ctx, event.ID, pubErr.Error(), next); err != nil {
+				log.Error("Failed to record outbox delivery failure", "event_id", event.ID, "error", err)
+			}
+		} else if err := r.store.OutboxRepo.MarkDelivered(ctx, event.ID); err != nil {
+			log.Error("Failed to mark outbox event as delivered", "event_id", event.ID, "error", err)
+		}
+	}
+	return len(events), nil
+}
+
+// 配信対象のイベントを取得し、リースの期限まで配信中にする
+func (r *Relay) claim(ctx context.Context) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	err := r.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		var err error
+		events, err = txStore.OutboxRepo.FetchPending(ctx, r.cfg.BatchSize, r.cfg.MaxAttempts)
+		if err != nil {
+			return err
+		}
+		ids := make([]int64, len(events))
+		for i, event := range events {
+			ids[i] = event.ID
+		}
+		return txStore.OutboxRepo.Lease(ctx, ids, time.Now().Add(r.cfg.Lease))
+	})
+	return events, err
+}
+
+func (r *Relay) backoff(attempts int) time.Duration {
+	if attempts > 10 {
+		attempts = 10
+	}
+	return r.cfg.RetryBackoff << attempts
+}
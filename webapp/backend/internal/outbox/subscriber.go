@@ -0,0 +1,16 @@
+package outbox
+
+import (
+	"context"
+
+	"backend/internal/event"
+	"backend/internal/repository"
+)
+
+// バスに発行された全てのイベントを、発行元のトランザクション内でoutboxに書き込む
+// 配信はリレーが非同期に行う
+func Subscribe(bus *event.Bus) {
+	bus.SubscribeTx(func(ctx context.Context, tx *repository.Store, e event.Event) error {
+		return tx.OutboxRepo.Enqueue(ctx, e.Type(), e.AggregateID(), e)
+	})
+}
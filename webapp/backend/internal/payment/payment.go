@@ -0,0 +1,24 @@
+package payment
+
+import (
+	"context"
+	"errors"
+
+	"backend/internal/model"
+)
+
+// 決済が拒否された (残高不足・与信枠超過など)
+// これ以外のエラーは決済事業者との通信の失敗として扱う
+var ErrDeclined = errors.New("payment declined")
+
+// 決済事業者とのやりとり
+// 注文の作成時に与信(Authorize)を取り、注文のコミット後に売上確定(Capture)する
+// 途中で失敗した場合は与信を取り消す(Void)
+type Provider interface {
+	// payments.provider に記録する名前
+	Name() string
+	// amount の与信を取り、事業者側の与信IDを返す
+	Authorize(ctx context.Context, userID model.UserID, amount int) (string, error)
+	Capture(ctx context.Context, authorizationID string, amount int) error
+	Void(ctx context.Context, authorizationID string) error
+}
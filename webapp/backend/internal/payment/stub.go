@@ -0,0 +1,43 @@
+package payment
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+
+	"backend/internal/model"
+)
+
+// 外部と通信しない決済
+// declineAbove より大きい金額の与信だけを拒否する (0の場合は全て承認する)
+type Stub struct {
+	declineAbove int
+}
+
+func NewStub(declineAbove int) *Stub {
+	return &Stub{declineAbove: declineAbove}
+}
+
+func (s *Stub) Name() string {
+	return "stub"
+}
+
+func (s *Stub) Authorize(ctx context.Context, userID model.UserID, amount int) (string, error) {
+	if s.declineAbove > 0 && amount > s.declineAbove {
+		return "", fmt.Errorf("%w: amount %d exceeds limit %d", ErrDeclined, amount, s.declineAbove)
+	}
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return "stub_" + hex.EncodeToString(b), nil
+}
+
+func (s *Stub) Capture(ctx context.Context, authorizationID string, amount int) error {
+	return nil
+}
+
+func (s *Stub) Void(ctx context.Context, authorizationID string) error {
+	return nil
+}
@@ -0,0 +1,88 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+type AddressRepository struct {
+	db DBTX
+}
+
+func NewAddressRepository(db DBTX) *AddressRepository {
+	return &AddressRepository{db: db}
+}
+
+const addressColumns = "address_id, user_id, label, recipient, postal_code, prefecture, city, line1, line2, phone, is_default, created_at, updated_at"
+
+// ユーザーの配送先一覧 (既定の配送先が先頭)
+func (r *AddressRepository) List(ctx context.Context, userID model.UserID) ([]model.Address, error) {
+	addresses := []model.Address{}
+	query := "SELECT " + addressColumns + " FROM addresses WHERE user_id = ? ORDER BY is_default DESC, address_id"
+	err := r.db.SelectContext(ctx, &addresses, query, userID)
+	return addresses, translateError(err)
+}
+
+// ユーザー自身の配送先を取得する。他のユーザーの配送先の場合も ErrNotFound
+func (r *AddressRepository) Find(ctx context.Context, userID model.UserID, addressID int64) (model.Address, error) {
+	var a model.Address
+	query := "SELECT " + addressColumns + " FROM addresses WHERE address_id = ? AND user_id = ?"
+	err := r.db.GetContext(ctx, &a, query, addressID, userID)
+	return a, translateError(err)
+}
+
+// 既定の配送先のID。設定されていない場合は ErrNotFound
+func (r *AddressRepository) FindDefaultID(ctx context.Context, userID model.UserID) (int64, error) {
+	var id int64
+	err := r.db.GetContext(ctx, &id, "SELECT address_id FROM addresses WHERE user_id = ? AND is_default = 1 LIMIT 1", userID)
+	return id, translateError(err)
+}
+
+// ユーザーの配送先の件数
+func (r *AddressRepository) Count(ctx context.Context, userID model.UserID) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, "SELECT COUNT(*) FROM addresses WHERE user_id = ?", userID)
+	return n, translateError(err)
+}
+
+// 配送先を作成し、生成されたIDを返す
+func (r *AddressRepository) Create(ctx context.Context, a model.Address) (int64, error) {
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO addresses (user_id, label, recipient, postal_code, prefecture, city, line1, line2, phone, is_default, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, a.UserID, a.Label, a.Recipient, a.PostalCode, a.Prefecture, a.City, a.Line1, a.Line2, a.Phone, a.IsDefault, now, now)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+// 配送先の内容を更新する (既定かどうかは変えない)
+func (r *AddressRepository) Update(ctx context.Context, a model.Address) error {
+	query := `
+		UPDATE addresses
+		SET label = ?, recipient = ?, postal_code = ?, prefecture = ?, city = ?, line1 = ?, line2 = ?, phone = ?, updated_at = ?
+		WHERE address_id = ? AND user_id = ?`
+	_, err := r.db.ExecContext(ctx, query, a.Label, a.Recipient, a.PostalCode, a.Prefecture, a.City, a.Line1, a.Line2, a.Phone, time.Now().UTC(), a.AddressID, a.UserID)
+	return translateError(err)
+}
+
+func (r *AddressRepository) Delete(ctx context.Context, userID model.UserID, addressID int64) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM addresses WHERE address_id = ? AND user_id = ?", addressID, userID)
+	if err != nil {
+		return translateError(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// addressID をユーザーの既定の配送先にし、それ以外の既定を外す
+func (r *AddressRepository) SetDefault(ctx context.Context, userID model.UserID, addressID int64) error {
+	query := "UPDATE addresses SET is_default = (address_id = ?) WHERE user_id = ?"
+	_, err := r.db.ExecContext(ctx, query, addressID, userID)
+	return translateError(err)
+}
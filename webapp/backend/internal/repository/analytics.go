@@ -0,0 +1,46 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"strings"
+	"time"
+)
+
+type AnalyticsRepository struct {
+	db DBTX
+}
+
+func NewAnalyticsRepository(db DBTX) *AnalyticsRepository {
+	return &AnalyticsRepository{db: db}
+}
+
+// 1時間ごとの件数に差分を加算する (Start は時の始まりに切り捨てておくこと)
+func (r *AnalyticsRepository) AddHourly(ctx context.Context, deltas []model.OrderVolume) error {
+	if len(deltas) == 0 {
+		return nil
+	}
+	placeholders := strings.Repeat("(?, ?, ?),", len(deltas))
+	query := "INSERT INTO order_hourly_stats (hour, orders_created, deliveries_completed) VALUES " + placeholders[:len(placeholders)-1] + `
+		ON DUPLICATE KEY UPDATE
+			orders_created = orders_created + VALUES(orders_created),
+			deliveries_completed = deliveries_completed + VALUES(deliveries_completed)`
+	args := make([]interface{}, 0, len(deltas)*3)
+	for _, d := range deltas {
+		args = append(args, d.Start.UTC(), d.OrdersCreated, d.DeliveriesCompleted)
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return translateError(err)
+}
+
+// [from, to) の1時間ごとの件数。件数のない時間は含まない
+func (r *AnalyticsRepository) Hourly(ctx context.Context, from, to time.Time) ([]model.OrderVolume, error) {
+	var volumes []model.OrderVolume
+	query := `
+		SELECT hour AS start, orders_created, deliveries_completed
+		FROM order_hourly_stats
+		WHERE hour >= ? AND hour < ?
+		ORDER BY hour`
+	err := r.db.SelectContext(ctx, &volumes, query, from.UTC(), to.UTC())
+	return volumes, translateError(err)
+}
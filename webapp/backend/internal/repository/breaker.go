@@ -0,0 +1,216 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"sync"
+	"time"
+
+	"backend/internal/metrics"
+)
+
+// サーキットブレーカーがopenの間に返すエラー
+// errors.Is(err, ErrUnavailable) が成り立つため、ハンドラでは503に変換される
+var ErrCircuitOpen = errors.New("circuit breaker is open")
+
+type breakerState int
+
+const (
+	breakerClosed breakerState = iota
+	breakerHalfOpen
+	breakerOpen
+)
+
+func (s breakerState) String() string {
+	switch s {
+	case breakerHalfOpen:
+		return "half-open"
+	case breakerOpen:
+		return "open"
+	default:
+		return "closed"
+	}
+}
+
+type BreakerConfig struct {
+	// 失敗率を集計する期間
+	Window time.Duration
+	// 集計期間内にこの件数に満たない場合は判定しない
+	MinRequests int
+	// 失敗率がこれ以上になるとopenにする
+	FailureRatio float64
+	// これより遅いクエリも失敗として数える (0: 無効)
+	SlowThreshold time.Duration
+	// openを維持する時間。経過後はhalf-openで試行を通す
+	OpenDuration time.Duration
+	// half-openで同時に通す試行の数
+	HalfOpenProbes int
+}
+
+// DBの障害時に、タイムアウトを待たずに即座に失敗させるためのサーキットブレーカー
+// 接続エラー・クエリのタイムアウト・遅延をDBの異常として数え、
+// 見つからない・重複などの通常のエラーは成功として扱う
+type CircuitBreaker struct {
+	cfg         BreakerConfig
+	mu          sync.Mutex
+	state       breakerState
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probes      int
+}
+
+func NewCircuitBreaker(cfg BreakerConfig) *CircuitBreaker {
+	b := &CircuitBreaker{cfg: cfg, windowStart: time.Now()}
+	metrics.DBCircuitState.Set(float64(breakerClosed))
+	return b
+}
+
+func (b *CircuitBreaker) State() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state.String()
+}
+
+// クエリを実行してよいか判定する
+func (b *CircuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case breakerOpen:
+		if time.Since(b.openedAt) < b.cfg.OpenDuration {
+			return false
+		}
+		b.setState(breakerHalfOpen)
+		b.probes = 0
+		fallthrough
+	case breakerHalfOpen:
+		if b.probes >= b.cfg.HalfOpenProbes {
+			return false
+		}
+		b.probes++
+	}
+	return true
+}
+
+func (b *CircuitBreaker) record(failed bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	switch b.state {
+	case breakerHalfOpen:
+		if failed {
+			b.trip(now)
+			return
+		}
+		b.probes--
+		b.setState(breakerClosed)
+		b.resetWindow(now)
+		return
+	case breakerOpen:
+		return
+	}
+
+	if now.Sub(b.windowStart) > b.cfg.Window {
+		b.resetWindow(now)
+	}
+	b.requests++
+	if failed {
+		b.failures++
+	}
+	if b.requests >= b.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= b.cfg.FailureRatio {
+		b.trip(now)
+	}
+}
+
+func (b *CircuitBreaker) trip(now time.Time) {
+	b.setState(breakerOpen)
+	b.openedAt = now
+	b.resetWindow(now)
+}
+
+func (b *CircuitBreaker) resetWindow(now time.Time) {
+	b.windowStart = now
+	b.requests = 0
+	b.failures = 0
+}
+
+func (b *CircuitBreaker) setState(s breakerState) {
+	b.state = s
+	metrics.DBCircuitState.Set(float64(s))
+}
+
+// DBの異常とみなすエラーかどうか
+func (b *CircuitBreaker) isFailure(ctx context.Context, err error, elapsed time.Duration) bool {
+	if b.cfg.SlowThreshold > 0 && elapsed >= b.cfg.SlowThreshold {
+		return true
+	}
+	if err == nil {
+		return false
+	}
+	// リクエスト自体がキャンセル・タイムアウトした場合はDBの異常ではない
+	if ctx.Err() != nil {
+		return false
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	return errors.Is(translateError(err), ErrUnavailable)
+}
+
+type breakerDB struct {
+	db      DBTX
+	breaker *CircuitBreaker
+}
+
+// クエリをサーキットブレーカー経由で実行するデコレータを返す
+// breaker はトランザクションをまたいで共有される
+func WithCircuitBreaker(breaker *CircuitBreaker) DBTXWrapper {
+	return func(db DBTX) DBTX {
+		if breaker == nil {
+			return db
+		}
+		return &breakerDB{db: db, breaker: breaker}
+	}
+}
+
+func (b *breakerDB) do(ctx context.Context, fn func() error) error {
+	if !b.breaker.allow() {
+		metrics.DBCircuitRejections.Inc()
+		return &dbError{kind: ErrUnavailable, err: ErrCircuitOpen}
+	}
+	start := time.Now()
+	err := fn()
+	b.breaker.record(b.breaker.isFailure(ctx, err, time.Since(start)))
+	return err
+}
+
+func (b *breakerDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return b.do(ctx, func() error {
+		return b.db.GetContext(ctx, dest, query, args...)
+	})
+}
+
+func (b *breakerDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return b.do(ctx, func() error {
+		return b.db.SelectContext(ctx, dest, query, args...)
+	})
+}
+
+func (b *breakerDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	var res sql.Result
+	err := b.do(ctx, func() error {
+		var err error
+		res, err = b.db.ExecContext(ctx, query, args...)
+		return err
+	})
+	return res, err
+}
+
+func (b *breakerDB) Rebind(query string) string {
+	return b.db.Rebind(query)
+}
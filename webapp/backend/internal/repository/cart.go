@@ -0,0 +1,78 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+type CartRepository struct {
+	db DBTX
+}
+
+func NewCartRepository(db DBTX) *CartRepository {
+	return &CartRepository{db: db}
+}
+
+// カートの中身を商品情報と併せて取得
+func (r *CartRepository) List(ctx context.Context, userID model.UserID) ([]model.CartItem, error) {
+	return r.list(ctx, userID, "")
+}
+
+// カートの中身を取得し、行ロックを取る
+// チェックアウト中に同じユーザーのカートが変更されないようにトランザクション内で使う
+func (r *CartRepository) ListForUpdate(ctx context.Context, userID model.UserID) ([]model.CartItem, error) {
+	return r.list(ctx, userID, " FOR UPDATE")
+}
+
+func (r *CartRepository) list(ctx context.Context, userID model.UserID, suffix string) ([]model.CartItem, error) {
+	items := []model.CartItem{}
+	query := `
+		SELECT c.product_id, p.name, p.value, p.weight, COALESCE(p.image, '') AS image, c.quantity
+		FROM cart_items c
+		JOIN products p ON c.product_id = p.product_id
+		WHERE c.user_id = ?
+		ORDER BY c.product_id` + suffix
+	err := r.db.SelectContext(ctx, &items, query, userID)
+	return items, translateError(err)
+}
+
+// 商品をカートに追加する。すでにある場合は数量を加算する
+// 存在しない商品の場合は ErrForeignKey
+func (r *CartRepository) Add(ctx context.Context, userID model.UserID, productID model.ProductID, quantity int) error {
+	query := `
+		INSERT INTO cart_items (user_id, product_id, quantity, updated_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = quantity + VALUES(quantity), updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, userID, productID, quantity, time.Now().UTC())
+	return translateError(err)
+}
+
+// カート内の商品の数量を変更する。カートにない場合は ErrNotFound
+func (r *CartRepository) SetQuantity(ctx context.Context, userID model.UserID, productID model.ProductID, quantity int) error {
+	var exists bool
+	err := r.db.GetContext(ctx, &exists, "SELECT 1 FROM cart_items WHERE user_id = ? AND product_id = ? FOR UPDATE", userID, productID)
+	if err != nil {
+		return translateError(err)
+	}
+	_, err = r.db.ExecContext(ctx, "UPDATE cart_items SET quantity = ?, updated_at = ? WHERE user_id = ? AND product_id = ?",
+		quantity, time.Now().UTC(), userID, productID)
+	return translateError(err)
+}
+
+// カートから商品を削除する。カートにない場合は ErrNotFound
+func (r *CartRepository) Remove(ctx context.Context, userID model.UserID, productID model.ProductID) error {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ? AND product_id = ?", userID, productID)
+	if err != nil {
+		return translateError(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// カートを空にする
+func (r *CartRepository) Clear(ctx context.Context, userID model.UserID) error {
+	_, err := r.db.ExecContext(ctx, "DELETE FROM cart_items WHERE user_id = ?", userID)
+	return translateError(err)
+}
@@ -0,0 +1,57 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+type CouponRepository struct {
+	db DBTX
+}
+
+func NewCouponRepository(db DBTX) *CouponRepository {
+	return &CouponRepository{db: db}
+}
+
+const couponColumns = "code, discount_type, value, expires_at, max_redemptions, max_per_user, redeemed_count, created_at"
+
+func (r *CouponRepository) List(ctx context.Context) ([]model.Coupon, error) {
+	coupons := []model.Coupon{}
+	err := r.db.SelectContext(ctx, &coupons, "SELECT "+couponColumns+" FROM coupons ORDER BY created_at DESC")
+	return coupons, translateError(err)
+}
+
+// クーポンを作成する。コードが重複する場合は ErrConflict
+func (r *CouponRepository) Create(ctx context.Context, c model.Coupon) error {
+	query := `
+		INSERT INTO coupons (code, discount_type, value, expires_at, max_redemptions, max_per_user, redeemed_count, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, 0, ?)`
+	_, err := r.db.ExecContext(ctx, query, c.Code, c.DiscountType, c.Value, c.ExpiresAt, c.MaxRedemptions, c.MaxPerUser, c.CreatedAt)
+	return translateError(err)
+}
+
+// クーポンを取得し、行ロックを取る。存在しない場合は ErrNotFound
+// 利用回数の確認から使用の記録までを同じトランザクション内で行うために使う
+func (r *CouponRepository) Lock(ctx context.Context, code string) (model.Coupon, error) {
+	var c model.Coupon
+	err := r.db.GetContext(ctx, &c, "SELECT "+couponColumns+" FROM coupons WHERE code = ? FOR UPDATE", code)
+	return c, translateError(err)
+}
+
+// ユーザーがクーポンを使用した回数
+func (r *CouponRepository) CountRedemptions(ctx context.Context, code string, userID model.UserID) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, "SELECT COUNT(*) FROM coupon_redemptions WHERE code = ? AND user_id = ?", code, userID)
+	return n, translateError(err)
+}
+
+// クーポンの使用を記録し、使用回数を加算する
+func (r *CouponRepository) Redeem(ctx context.Context, code string, userID model.UserID, paymentID int64, discount int) error {
+	query := "INSERT INTO coupon_redemptions (code, user_id, payment_id, discount, redeemed_at) VALUES (?, ?, ?, ?, ?)"
+	if _, err := r.db.ExecContext(ctx, query, code, userID, paymentID, discount, time.Now().UTC()); err != nil {
+		return translateError(err)
+	}
+	_, err := r.db.ExecContext(ctx, "UPDATE coupons SET redeemed_count = redeemed_count + 1 WHERE code = ?", code)
+	return translateError(err)
+}
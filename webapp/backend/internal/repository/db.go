@@ -11,3 +11,20 @@ type DBTX interface {
 	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
 	Rebind(query string) string
 }
+
+// DBTX をラップしてクエリ単位の処理(タイムアウト・計測など)を差し込むデコレータ
+type DBTXWrapper func(DBTX) DBTX
+
+// 複数行のINSERTで生成された連続したIDを返す
+// 1つのINSERT文には連続したIDが割り当てられることを前提にしている
+func insertedIDs[ID ~int | ~int64](result sql.Result, n int) ([]ID, error) {
+	firstID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]ID, n)
+	for i := range ids {
+		ids[i] = ID(firstID) + ID(i)
+	}
+	return ids, nil
+}
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// リポジトリが返すエラーの種別
+// ドライバ固有のエラーは translateError でこれらに変換され、errors.Is で判定できる
+var (
+	ErrNotFound    = errors.New("record not found")
+	ErrConflict    = errors.New("record conflict")
+	ErrForeignKey  = errors.New("foreign key constraint violation")
+	ErrUnavailable = errors.New("database unavailable")
+	// デッドロック・ロック待ちのタイムアウト。やり直せば成功する可能性がある
+	ErrRetryable = errors.New("transaction aborted by lock contention")
+	// SAVEPOINTまで戻せなかった。トランザクション全体が使えない
+	ErrSavepointLost = errors.New("rollback to savepoint failed")
+)
+
+// err の後もトランザクションを続けられないかどうか
+// デッドロックではMySQLがトランザクション全体をロールバックするため、SAVEPOINTだけの取り消しでは済まない
+func TxAborted(err error) bool {
+	return errors.Is(err, ErrRetryable) || errors.Is(err, ErrUnavailable) || errors.Is(err, ErrSavepointLost)
+}
+
+// MySQLのエラー番号
+// https://dev.mysql.com/doc/mysql-errors/8.0/en/server-error-reference.html
+const (
+	mysqlErrDupEntry          = 1062
+	mysqlErrLockWaitTimeout   = 1205
+	mysqlErrDeadlock          = 1213
+	mysqlErrRowIsReferenced   = 1451
+	mysqlErrNoReferencedRow   = 1452
+	mysqlErrRowIsReferencedV1 = 1217
+	mysqlErrNoReferencedRowV1 = 1216
+)
+
+// 種別と元のエラーを併せ持つエラー
+// errors.Is(err, ErrNotFound) と errors.Is(err, sql.ErrNoRows) の両方が成り立つ
+type dbError struct {
+	kind error
+	err  error
+}
+
+func (e *dbError) Error() string {
+	return e.kind.Error() + ": " + e.err.Error()
+}
+
+func (e *dbError) Is(target error) bool {
+	return target == e.kind
+}
+
+func (e *dbError) Unwrap() error {
+	return e.err
+}
+
+// ドライバのエラーをリポジトリのエラー種別に変換する
+// 種別に当てはまらないエラー(コンテキストのキャンセルなど)はそのまま返す
+func translateError(err error) error {
+	if err == nil {
+		return nil
+	}
+	var de *dbError
+	if errors.As(err, &de) {
+		return err
+	}
+	if errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return err
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		return &dbError{kind: ErrNotFound, err: err}
+	}
+
+	var myErr *mysql.MySQLError
+	if errors.As(err, &myErr) {
+		switch myErr.Number {
+		case mysqlErrDupEntry:
+			return &dbError{kind: ErrConflict, err: err}
+		case mysqlErrDeadlock, mysqlErrLockWaitTimeout:
+			return &dbError{kind: ErrRetryable, err: err}
+		case mysqlErrRowIsReferenced, mysqlErrNoReferencedRow, mysqlErrRowIsReferencedV1, mysqlErrNoReferencedRowV1:
+			return &dbError{kind: ErrForeignKey, err: err}
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.Is(err, driver.ErrBadConn) || errors.Is(err, mysql.ErrInvalidConn) ||
+		errors.Is(err, sql.ErrConnDone) || errors.As(err, &netErr) {
+		return &dbError{kind: ErrUnavailable, err: err}
+	}
+	return err
+}
@@ -0,0 +1,41 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+)
+
+type FeatureFlagRepository struct {
+	db DBTX
+}
+
+func NewFeatureFlagRepository(db DBTX) *FeatureFlagRepository {
+	return &FeatureFlagRepository{db: db}
+}
+
+// フラグを取得する。行がない場合は ErrNotFound
+func (r *FeatureFlagRepository) Get(ctx context.Context, name string) (model.FeatureFlag, error) {
+	var flag model.FeatureFlag
+	query := "SELECT name, enabled, rollout_percent, updated_at FROM feature_flags WHERE name = ?"
+	err := r.db.GetContext(ctx, &flag, query, name)
+	return flag, translateError(err)
+}
+
+func (r *FeatureFlagRepository) List(ctx context.Context) ([]model.FeatureFlag, error) {
+	var flags []model.FeatureFlag
+	query := "SELECT name, enabled, rollout_percent, updated_at FROM feature_flags ORDER BY name"
+	err := r.db.SelectContext(ctx, &flags, query)
+	return flags, translateError(err)
+}
+
+func (r *FeatureFlagRepository) Upsert(ctx context.Context, flag model.FeatureFlag) error {
+	query := `
+		INSERT INTO feature_flags (name, enabled, rollout_percent, updated_at)
+		VALUES (?, ?, ?, UTC_TIMESTAMP(6))
+		ON DUPLICATE KEY UPDATE
+			enabled = VALUES(enabled),
+			rollout_percent = VALUES(rollout_percent),
+			updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, flag.Name, flag.Enabled, flag.RolloutPercent)
+	return translateError(err)
+}
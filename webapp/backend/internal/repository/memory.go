@@ -0,0 +1,100 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/internal/model"
+)
+
+// DBを使わずに動くStoreを作る (DEV_MODE・サービスのテスト用)
+// ユーザー・セッション・商品・注文はメモリに保持し、それ以外のテーブルは常に空として扱って書き込みを捨てる
+// トランザクションはなく、ExecTx の fn が失敗してもそれまでの変更は取り消されない
+func NewMemoryStore() *Store {
+	m := &memoryDB{
+		users:    make(map[model.UserID]*model.User),
+		sessions: make(map[string]memorySession),
+		products: make(map[model.ProductID]*model.Product),
+		orders:   make(map[model.OrderID]*memoryOrder),
+	}
+	s := newStore(emptyDB{}, storeOptions{})
+	s.UserRepo = &memoryUsers{m}
+	s.SessionRepo = &memorySessions{m}
+	s.ProductRepo = &memoryProducts{m: m}
+	s.OrderRepo = &memoryOrders{m}
+	return s
+}
+
+// メモリ上のテーブル
+// 全てのリポジトリで1つのロックを共有し、テーブルをまたぐ参照 (注文の商品名など) も一貫させる
+type memoryDB struct {
+	mu sync.Mutex
+
+	users      map[model.UserID]*model.User
+	lastUserID model.UserID
+
+	sessions map[string]memorySession
+
+	products      map[model.ProductID]*model.Product
+	lastProductID model.ProductID
+
+	orders      map[model.OrderID]*memoryOrder
+	lastOrderID model.OrderID
+}
+
+type memorySession struct {
+	userID    model.UserID
+	expiresAt time.Time
+}
+
+type memoryOrder struct {
+	model.Order
+	robotID string
+	planID  string
+	// ステータスの変更履歴 (order_status_history)
+	history []memoryStatusChange
+	// 配送できずに戻された記録 (order_returns)
+	returns []string
+}
+
+type memoryStatusChange struct {
+	status    string
+	changedAt time.Time
+}
+
+// 行がなく、書き込みを捨てる DBTX
+// NewMemoryStore でメモリに保持しないテーブルのリポジトリに使う
+type emptyDB struct{}
+
+// 集計 (SELECT COUNT(...) ...) は空のテーブルでも1行返るため、dest をゼロ値のままにして成功させる
+func (emptyDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	if strings.HasPrefix(strings.TrimSpace(query), "SELECT COUNT(") {
+		return nil
+	}
+	return sql.ErrNoRows
+}
+
+func (emptyDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	return nil
+}
+
+func (emptyDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	return emptyResult{}, nil
+}
+
+func (emptyDB) Rebind(query string) string {
+	return query
+}
+
+type emptyResult struct{}
+
+func (emptyResult) LastInsertId() (int64, error) { return 0, nil }
+func (emptyResult) RowsAffected() (int64, error) { return 0, nil }
+
+// メモリのリポジトリが返す「見つからない」エラー (MySQLの実装と同じく sql.ErrNoRows としても判定できる)
+func errMemoryNotFound() error {
+	return translateError(sql.ErrNoRows)
+}
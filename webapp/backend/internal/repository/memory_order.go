@@ -0,0 +1,372 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"database/sql"
+	"slices"
+	"strings"
+	"time"
+
+	"backend/internal/model"
+)
+
+// 既定の倉庫のコード (0004_warehouses.sql で作成されるもの)
+// メモリのStoreには他の倉庫がないため、それ以外の倉庫の注文は配送候補にならない
+const memoryDefaultZone = "main"
+
+type memoryOrders struct {
+	m *memoryDB
+}
+
+func (r *memoryOrders) Create(ctx context.Context, order *model.Order) (model.OrderID, error) {
+	ids, err := r.BulkCreate(ctx, []model.Order{*order})
+	if err != nil {
+		return 0, err
+	}
+	return ids[0], nil
+}
+
+func (r *memoryOrders) BulkCreate(ctx context.Context, orders []model.Order) ([]model.OrderID, error) {
+	now := time.Now().UTC().Truncate(time.Second)
+	imported := make([]model.Order, len(orders))
+	for i, o := range orders {
+		o.ShippedStatus = "shipping"
+		o.CreatedAt = now
+		o.ArrivedAt = sql.NullTime{}
+		imported[i] = o
+	}
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return r.insert(imported), nil
+}
+
+func (r *memoryOrders) Import(ctx context.Context, orders []model.Order) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.insert(orders)
+	return nil
+}
+
+// 注文を追加して生成した注文IDを返す。ロックを取ってから呼ぶこと
+func (r *memoryOrders) insert(orders []model.Order) []model.OrderID {
+	ids := make([]model.OrderID, len(orders))
+	for i, o := range orders {
+		r.m.lastOrderID++
+		o.OrderID = r.m.lastOrderID
+		o.WarehouseID = warehouseOrDefault(o.WarehouseID)
+		o.ProductName = ""
+		r.m.orders[o.OrderID] = &memoryOrder{Order: o}
+		ids[i] = o.OrderID
+	}
+	return ids
+}
+
+func (r *memoryOrders) UpdateStatus(ctx context.Context, orderID model.OrderID, newStatus string) error {
+	return r.UpdateStatuses(ctx, []model.OrderID{orderID}, newStatus)
+}
+
+func (r *memoryOrders) UpdateStatuses(ctx context.Context, orderIDs []model.OrderID, newStatus string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, id := range orderIDs {
+		if o, ok := r.m.orders[id]; ok {
+			o.ShippedStatus = newStatus
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrders) UpdateStatusesChunked(ctx context.Context, orderIDs []model.OrderID, newStatus string) error {
+	return r.UpdateStatuses(ctx, orderIDs, newStatus)
+}
+
+func (r *memoryOrders) RecordStatusHistory(ctx context.Context, orderIDs []model.OrderID, status string) error {
+	now := time.Now().UTC().Truncate(time.Microsecond)
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, id := range orderIDs {
+		if o, ok := r.m.orders[id]; ok {
+			o.history = append(o.history, memoryStatusChange{status: status, changedAt: now})
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrders) ClaimForDelivery(ctx context.Context, orderIDs []model.OrderID, robotID, planID string) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var claimed int64
+	for _, id := range orderIDs {
+		if o, ok := r.m.orders[id]; ok && o.ShippedStatus == "shipping" {
+			o.ShippedStatus = "delivering"
+			o.robotID = robotID
+			o.planID = planID
+			claimed++
+		}
+	}
+	return claimed, nil
+}
+
+func (r *memoryOrders) FindClaimed(ctx context.Context, planID string) (map[model.OrderID]string, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	claimed := make(map[model.OrderID]string)
+	for id, o := range r.m.orders {
+		if o.planID == planID {
+			claimed[id] = o.Note
+		}
+	}
+	return claimed, nil
+}
+
+func (r *memoryOrders) GetShippingOrders(ctx context.Context, warehouseID int) ([]model.Order, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var orders []model.Order
+	for _, o := range r.sorted() {
+		if o.ShippedStatus == "shipping" && (warehouseID == 0 || o.WarehouseID == warehouseID) {
+			orders = append(orders, model.Order{OrderID: o.OrderID, Weight: o.Weight, Value: o.Value})
+		}
+	}
+	return orders, nil
+}
+
+func (r *memoryOrders) ListShippingCandidates(ctx context.Context, afterID model.OrderID, zone string, limit int) ([]model.ShippingCandidate, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	candidates := []model.ShippingCandidate{}
+	if zone != "" && zone != memoryDefaultZone {
+		return candidates, nil
+	}
+	for _, o := range r.sorted() {
+		if len(candidates) >= limit {
+			break
+		}
+		if o.ShippedStatus == "shipping" && o.OrderID > afterID && o.WarehouseID == model.DefaultWarehouseID {
+			candidates = append(candidates, model.ShippingCandidate{OrderID: o.OrderID, Weight: o.Weight, Value: o.Value, Zone: memoryDefaultZone})
+		}
+	}
+	return candidates, nil
+}
+
+func (r *memoryOrders) FindByID(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Order, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	o, p, ok := r.find(userID, orderID)
+	if !ok {
+		return model.Order{}, errMemoryNotFound()
+	}
+	return model.Order{
+		OrderID:       o.OrderID,
+		ProductID:     o.ProductID,
+		ProductName:   p.Name,
+		ShippedStatus: o.ShippedStatus,
+		Weight:        o.Weight,
+		Value:         o.Value,
+		CreatedAt:     o.CreatedAt,
+		ArrivedAt:     o.ArrivedAt,
+		Discount:      o.Discount,
+		Note:          o.Note,
+	}, nil
+}
+
+func (r *memoryOrders) FindReceipt(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Receipt, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	o, p, ok := r.find(userID, orderID)
+	if !ok {
+		return model.Receipt{}, errMemoryNotFound()
+	}
+	receipt := model.Receipt{
+		OrderID:       o.OrderID,
+		ProductName:   p.Name,
+		Price:         o.Value,
+		Discount:      o.Discount,
+		ShippedStatus: o.ShippedStatus,
+		OrderedAt:     o.CreatedAt,
+	}
+	// 配送計画の記録 (order_tracking) は保持しないため、発送時刻は常に nil
+	if o.ArrivedAt.Valid {
+		receipt.DeliveredAt = &o.ArrivedAt.Time
+	} else {
+		for _, h := range o.history {
+			if h.status == "completed" {
+				receipt.DeliveredAt = &h.changedAt
+				break
+			}
+		}
+	}
+	return receipt, nil
+}
+
+// ユーザー自身の注文とその商品。ロックを取ってから呼ぶこと
+func (r *memoryOrders) find(userID model.UserID, orderID model.OrderID) (*memoryOrder, *model.Product, bool) {
+	o, ok := r.m.orders[orderID]
+	if !ok || o.UserID != userID {
+		return nil, nil, false
+	}
+	p, ok := r.m.products[o.ProductID]
+	if !ok {
+		return nil, nil, false
+	}
+	return o, p, true
+}
+
+func (r *memoryOrders) ListOrders(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Order, int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+
+	search := strings.ToLower(req.Search)
+	var orders []model.Order
+	for _, o := range r.m.orders {
+		p, ok := r.m.products[o.ProductID]
+		if !ok || o.UserID != userID {
+			continue
+		}
+		matched := strings.Contains(strings.ToLower(p.Name), search)
+		if req.Type == "prefix" {
+			matched = strings.HasPrefix(strings.ToLower(p.Name), search)
+		}
+		if !matched {
+			continue
+		}
+		orders = append(orders, model.Order{
+			OrderID:       o.OrderID,
+			ProductID:     o.ProductID,
+			ProductName:   p.Name,
+			ShippedStatus: o.ShippedStatus,
+			Weight:        o.Weight,
+			Value:         o.Value,
+			CreatedAt:     o.CreatedAt,
+			ArrivedAt:     o.ArrivedAt,
+			Discount:      o.Discount,
+		})
+	}
+
+	desc := strings.ToUpper(req.SortOrder) == "DESC"
+	slices.SortFunc(orders, func(a, b model.Order) int {
+		c := compareOrders(a, b, req.SortField)
+		if desc {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.OrderID, b.OrderID))
+	})
+	total := len(orders)
+	return orders[min(req.Offset, total):min(req.Offset+req.PageSize, total)], total, nil
+}
+
+// MySQLと同じく、到着日時が NULL のものは昇順で先頭に来る
+func compareOrders(a, b model.Order, field string) int {
+	switch field {
+	case "product_name":
+		return strings.Compare(a.ProductName, b.ProductName)
+	case "created_at":
+		return a.CreatedAt.Compare(b.CreatedAt)
+	case "shipped_status":
+		return strings.Compare(a.ShippedStatus, b.ShippedStatus)
+	case "arrived_at":
+		if a.ArrivedAt.Valid != b.ArrivedAt.Valid {
+			if a.ArrivedAt.Valid {
+				return 1
+			}
+			return -1
+		}
+		return a.ArrivedAt.Time.Compare(b.ArrivedAt.Time)
+	}
+	return cmp.Compare(a.OrderID, b.OrderID)
+}
+
+func (r *memoryOrders) FindIDsByStatus(ctx context.Context, status string, createdBefore time.Time, limit int) ([]model.OrderID, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var ids []model.OrderID
+	for _, o := range r.sorted() {
+		if len(ids) >= limit {
+			break
+		}
+		if o.ShippedStatus == status && o.CreatedAt.Before(createdBefore) {
+			ids = append(ids, o.OrderID)
+		}
+	}
+	return ids, nil
+}
+
+func (r *memoryOrders) LockByStatus(ctx context.Context, orderIDs []model.OrderID, status string) ([]model.OrderID, error) {
+	return r.filter(orderIDs, func(o *memoryOrder) bool {
+		return o.ShippedStatus == status
+	}), nil
+}
+
+func (r *memoryOrders) LockDeliveringBy(ctx context.Context, orderIDs []model.OrderID, robotID string) ([]model.OrderID, error) {
+	return r.filter(orderIDs, func(o *memoryOrder) bool {
+		return o.ShippedStatus == "delivering" && (o.robotID == robotID || o.robotID == "")
+	}), nil
+}
+
+// orderIDs のうち match を満たす注文のIDを注文ID順に返す (トランザクションがないためロックは取らない)
+func (r *memoryOrders) filter(orderIDs []model.OrderID, match func(*memoryOrder) bool) []model.OrderID {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	ids := []model.OrderID{}
+	for _, id := range orderIDs {
+		if o, ok := r.m.orders[id]; ok && match(o) && !slices.Contains(ids, id) {
+			ids = append(ids, id)
+		}
+	}
+	slices.Sort(ids)
+	return ids
+}
+
+func (r *memoryOrders) CountReturns(ctx context.Context, orderIDs []model.OrderID) (map[model.OrderID]int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	counts := make(map[model.OrderID]int)
+	for _, id := range orderIDs {
+		if o, ok := r.m.orders[id]; ok && len(o.returns) > 0 {
+			counts[id] = len(o.returns)
+		}
+	}
+	return counts, nil
+}
+
+func (r *memoryOrders) RecordReturns(ctx context.Context, robotID string, items []model.ReturnOrderItem) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, item := range items {
+		if o, ok := r.m.orders[item.OrderID]; ok {
+			o.returns = append(o.returns, item.Reason)
+		}
+	}
+	return nil
+}
+
+func (r *memoryOrders) CountByStatus(ctx context.Context) (map[string]int, error) {
+	return r.countByStatus(func(o *memoryOrder) bool { return true }), nil
+}
+
+func (r *memoryOrders) CountByStatusForUser(ctx context.Context, userID model.UserID) (map[string]int, error) {
+	return r.countByStatus(func(o *memoryOrder) bool { return o.UserID == userID }), nil
+}
+
+func (r *memoryOrders) countByStatus(match func(*memoryOrder) bool) map[string]int {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	counts := make(map[string]int)
+	for _, o := range r.m.orders {
+		if match(o) {
+			counts[o.ShippedStatus]++
+		}
+	}
+	return counts
+}
+
+// 全ての注文を注文ID順に返す。ロックを取ってから呼ぶこと
+func (r *memoryOrders) sorted() []*memoryOrder {
+	orders := make([]*memoryOrder, 0, len(r.m.orders))
+	for _, o := range r.m.orders {
+		orders = append(orders, o)
+	}
+	slices.SortFunc(orders, func(a, b *memoryOrder) int { return cmp.Compare(a.OrderID, b.OrderID) })
+	return orders
+}
@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"cmp"
+	"context"
+	"slices"
+	"strings"
+	"sync/atomic"
+
+	"backend/internal/model"
+)
+
+type memoryProducts struct {
+	m *memoryDB
+	// 総数はキャッシュせずに毎回数えるため、温める処理は印を付けるだけ
+	countWarmed atomic.Bool
+	// 商品カタログのバージョン (MySQLの catalog_version テーブルにあたる)
+	catalogVersion atomic.Int64
+}
+
+func (r *memoryProducts) CountProducts(ctx context.Context, req model.ListRequest) (int, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	return len(r.matching(req.Search)), nil
+}
+
+func (r *memoryProducts) WarmCountCache(ctx context.Context) error {
+	r.countWarmed.Store(true)
+	return nil
+}
+
+func (r *memoryProducts) InvalidateCountCache(ctx context.Context) {}
+
+func (r *memoryProducts) IsCountCacheWarm() bool {
+	return r.countWarmed.Load()
+}
+
+func (r *memoryProducts) ListProducts(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Product, model.ListTotal, error) {
+	page, total := r.list(req)
+	products := make([]model.Product, len(page))
+	for i, p := range page {
+		products[i] = *p
+	}
+	return products, total, nil
+}
+
+func (r *memoryProducts) ListProductSummaries(ctx context.Context, req model.ListRequest) ([]model.ProductSummary, model.ListTotal, error) {
+	page, total := r.list(req)
+	products := make([]model.ProductSummary, len(page))
+	for i, p := range page {
+		products[i] = model.ProductSummary{ProductID: p.ProductID, Name: p.Name, Value: p.Value, Weight: p.Weight}
+	}
+	return products, total, nil
+}
+
+// 一覧の条件で1ページ分を取得する。総数は常に正確に数える
+func (r *memoryProducts) list(req model.ListRequest) ([]*model.Product, model.ListTotal) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	products := r.matching(req.Search)
+	slices.SortFunc(products, func(a, b *model.Product) int {
+		c := compareProducts(a, b, req.SortField)
+		if req.SortOrder == "DESC" {
+			c = -c
+		}
+		return cmp.Or(c, cmp.Compare(a.ProductID, b.ProductID))
+	})
+	total := model.ListTotal{Count: len(products)}
+	return products[min(req.Offset, len(products)):min(req.Offset+req.PageSize, len(products))], total
+}
+
+// 名前か説明文に search を含む商品 (search が空の場合は全て)
+func (r *memoryProducts) matching(search string) []*model.Product {
+	search = strings.ToLower(search)
+	products := make([]*model.Product, 0, len(r.m.products))
+	for _, p := range r.m.products {
+		if search == "" || strings.Contains(strings.ToLower(p.Name), search) || strings.Contains(strings.ToLower(p.Description), search) {
+			products = append(products, p)
+		}
+	}
+	return products
+}
+
+// field は ProductListSpec.SortFields のいずれか
+func compareProducts(a, b *model.Product, field string) int {
+	switch field {
+	case "name":
+		return strings.Compare(a.Name, b.Name)
+	case "value":
+		return cmp.Compare(a.Value, b.Value)
+	case "weight":
+		return cmp.Compare(a.Weight, b.Weight)
+	case "image":
+		return strings.Compare(a.Image, b.Image)
+	case "description":
+		return strings.Compare(a.Description, b.Description)
+	}
+	return cmp.Compare(a.ProductID, b.ProductID)
+}
+
+func (r *memoryProducts) FindByID(ctx context.Context, productID model.ProductID) (model.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	p, ok := r.m.products[productID]
+	if !ok {
+		return model.Product{}, errMemoryNotFound()
+	}
+	return *p, nil
+}
+
+func (r *memoryProducts) BulkCreate(ctx context.Context, products []model.Product) ([]model.ProductID, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	ids := make([]model.ProductID, len(products))
+	for i, p := range products {
+		r.m.lastProductID++
+		p.ProductID = r.m.lastProductID
+		r.m.products[p.ProductID] = &p
+		ids[i] = p.ProductID
+	}
+	r.catalogVersion.Add(1)
+	return ids, nil
+}
+
+func (r *memoryProducts) CatalogVersion(ctx context.Context) (int64, error) {
+	return r.catalogVersion.Load(), nil
+}
+
+func (r *memoryProducts) BumpCatalogVersion(ctx context.Context) (int64, error) {
+	return r.catalogVersion.Add(1), nil
+}
+
+func (r *memoryProducts) GetProductsByIDs(ctx context.Context, productIDs []model.ProductID) ([]model.Product, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	products := []model.Product{}
+	seen := make(map[model.ProductID]bool, len(productIDs))
+	for _, id := range productIDs {
+		if p, ok := r.m.products[id]; ok && !seen[id] {
+			seen[id] = true
+			products = append(products, *p)
+		}
+	}
+	return products, nil
+}
@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/model"
+
+	"github.com/google/uuid"
+)
+
+type memorySessions struct {
+	m *memoryDB
+}
+
+func (r *memorySessions) Create(ctx context.Context, userID model.UserID, duration time.Duration) (string, time.Time, error) {
+	sessionUUID, err := uuid.NewRandom()
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	expiresAt := time.Now().Add(duration)
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	r.m.sessions[sessionUUID.String()] = memorySession{userID: userID, expiresAt: expiresAt}
+	return sessionUUID.String(), expiresAt, nil
+}
+
+func (r *memorySessions) FindUserBySessionID(ctx context.Context, sessionID string) (model.UserID, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	s, ok := r.m.sessions[sessionID]
+	if !ok || !s.expiresAt.After(time.Now()) {
+		return 0, errMemoryNotFound()
+	}
+	if _, ok := r.m.users[s.userID]; !ok {
+		return 0, errMemoryNotFound()
+	}
+	return s.userID, nil
+}
+
+func (r *memorySessions) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	var n int64
+	for id, s := range r.m.sessions {
+		if n >= int64(limit) {
+			break
+		}
+		if !s.expiresAt.After(before) {
+			delete(r.m.sessions, id)
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,59 @@
+package repository
+
+import (
+	"context"
+
+	"backend/internal/model"
+)
+
+type memoryUsers struct {
+	m *memoryDB
+}
+
+func (r *memoryUsers) FindByUserName(ctx context.Context, userName string) (*model.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	for _, u := range r.m.users {
+		if u.UserName == userName {
+			user := *u
+			return &user, nil
+		}
+	}
+	return nil, errMemoryNotFound()
+}
+
+func (r *memoryUsers) BulkCreate(ctx context.Context, users []model.User) ([]model.UserID, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	ids := make([]model.UserID, len(users))
+	for i, u := range users {
+		r.m.lastUserID++
+		u.UserID = r.m.lastUserID
+		if u.Timezone == "" {
+			u.Timezone = model.DefaultTimezone
+		}
+		r.m.users[u.UserID] = &u
+		ids[i] = u.UserID
+	}
+	return ids, nil
+}
+
+func (r *memoryUsers) FindByID(ctx context.Context, userID model.UserID) (*model.User, error) {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	u, ok := r.m.users[userID]
+	if !ok {
+		return nil, errMemoryNotFound()
+	}
+	// MySQLの実装と同じくパスワードのハッシュは返さない
+	return &model.User{UserID: u.UserID, UserName: u.UserName, Timezone: u.Timezone}, nil
+}
+
+func (r *memoryUsers) UpdateTimezone(ctx context.Context, userID model.UserID, timezone string) error {
+	r.m.mu.Lock()
+	defer r.m.mu.Unlock()
+	if u, ok := r.m.users[userID]; ok {
+		u.Timezone = timezone
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+
+	"backend/internal/metrics"
+)
+
+// クエリのレイテンシをPrometheusに記録するDBTX
+type metricsDB struct {
+	db DBTX
+}
+
+// クエリのレイテンシを記録するデコレータを返す
+func WithMetrics() DBTXWrapper {
+	return func(db DBTX) DBTX {
+		return &metricsDB{db: db}
+	}
+}
+
+func (m *metricsDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	start := time.Now()
+	err := m.db.GetContext(ctx, dest, query, args...)
+	observeQuery("get", start, err)
+	return err
+}
+
+func (m *metricsDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	start := time.Now()
+	err := m.db.SelectContext(ctx, dest, query, args...)
+	observeQuery("select", start, err)
+	return err
+}
+
+func (m *metricsDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := m.db.ExecContext(ctx, query, args...)
+	observeQuery("exec", start, err)
+	return res, err
+}
+
+func (m *metricsDB) Rebind(query string) string {
+	return m.db.Rebind(query)
+}
+
+func observeQuery(op string, start time.Time, err error) {
+	status := "ok"
+	switch {
+	case err == nil || errors.Is(err, sql.ErrNoRows):
+	case errors.Is(err, context.Canceled):
+		// リクエストのキャンセル (クライアントの切断) で中断した
+		status = "canceled"
+	default:
+		status = "error"
+	}
+	metrics.DBQueryDuration.WithLabelValues(op, status).Observe(time.Since(start).Seconds())
+}
@@ -0,0 +1,85 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+type NotificationRepository struct {
+	db DBTX
+}
+
+func NewNotificationRepository(db DBTX) *NotificationRepository {
+	return &NotificationRepository{db: db}
+}
+
+// 注文の到着通知を送信待ちにする
+// 注文のユーザーが到着通知を有効にしていない場合は何もしない
+func (r *NotificationRepository) EnqueueOrderArrived(ctx context.Context, orderID model.OrderID) error {
+	query := `
+		INSERT INTO notifications (user_id, kind, order_id, recipient, created_at, next_attempt_at)
+		SELECT o.user_id, ?, o.order_id, np.email, UTC_TIMESTAMP(6), UTC_TIMESTAMP(6)
+		FROM orders o
+		JOIN notification_preferences np ON np.user_id = o.user_id
+		WHERE o.order_id = ? AND np.order_arrived = 1 AND np.email IS NOT NULL`
+	_, err := r.db.ExecContext(ctx, query, model.NotificationOrderArrived, orderID)
+	return translateError(err)
+}
+
+// 送信対象の通知を古い順に取得し、行ロックを取る
+// トランザクション内で呼び出すこと
+func (r *NotificationRepository) FetchPending(ctx context.Context, limit, maxAttempts int) ([]model.Notification, error) {
+	var notifications []model.Notification
+	query := `
+		SELECT n.id, n.user_id, n.kind, n.order_id, p.name AS product_name, n.recipient, n.attempts, n.created_at
+		FROM notifications n
+		JOIN orders o ON n.order_id = o.order_id
+		JOIN products p ON o.product_id = p.product_id
+		WHERE n.sent_at IS NULL AND n.next_attempt_at <= UTC_TIMESTAMP(6) AND n.attempts < ?
+		ORDER BY n.id
+		LIMIT ?
+		FOR UPDATE OF n SKIP LOCKED`
+	err := r.db.SelectContext(ctx, &notifications, query, maxAttempts, limit)
+	return notifications, translateError(err)
+}
+
+// 送信済みにする
+func (r *NotificationRepository) MarkSent(ctx context.Context, id int64) error {
+	query := "UPDATE notifications SET sent_at = UTC_TIMESTAMP(6), attempts = attempts + 1, last_error = NULL WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return translateError(err)
+}
+
+// 送信失敗を記録し、次回の送信時刻を設定する
+func (r *NotificationRepository) MarkFailed(ctx context.Context, id int64, lastErr string, nextAttemptAt time.Time) error {
+	query := "UPDATE notifications SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, lastErr, nextAttemptAt.UTC(), id)
+	return translateError(err)
+}
+
+// ユーザーの通知設定。未設定の場合は通知しない設定を返す
+func (r *NotificationRepository) GetPreferences(ctx context.Context, userID model.UserID) (model.NotificationPreferences, error) {
+	var row struct {
+		Email        sql.NullString `db:"email"`
+		OrderArrived bool           `db:"order_arrived"`
+	}
+	err := r.db.GetContext(ctx, &row, "SELECT email, order_arrived FROM notification_preferences WHERE user_id = ?", userID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return model.NotificationPreferences{OrderArrived: true}, nil
+	}
+	if err != nil {
+		return model.NotificationPreferences{}, translateError(err)
+	}
+	return model.NotificationPreferences{Email: row.Email.String, OrderArrived: row.OrderArrived}, nil
+}
+
+func (r *NotificationRepository) SavePreferences(ctx context.Context, userID model.UserID, prefs model.NotificationPreferences) error {
+	query := `
+		INSERT INTO notification_preferences (user_id, email, order_arrived, updated_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE email = VALUES(email), order_arrived = VALUES(order_arrived), updated_at = VALUES(updated_at)`
+	_, err := r.db.ExecContext(ctx, query, userID, nullableString(prefs.Email), prefs.OrderArrived, time.Now().UTC())
+	return translateError(err)
+}
@@ -6,6 +6,7 @@ import (
 	"database/sql"
 	"fmt"
 	"strings"
+	"time"
 
 	"github.com/jmoiron/sqlx"
 )
@@ -19,66 +20,58 @@ func NewOrderRepository(db DBTX) *OrderRepository {
 }
 
 // 注文を作成し、生成された注文IDを返す
-func (r *OrderRepository) Create(ctx context.Context, order *model.Order) (string, error) {
-	query := `INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES (?, ?, 'shipping', NOW())`
-	result, err := r.db.ExecContext(ctx, query, order.UserID, order.ProductID)
+// 重さと価値は order の値を注文時点のものとして保存する
+func (r *OrderRepository) Create(ctx context.Context, order *model.Order) (model.OrderID, error) {
+	query := `INSERT INTO orders (user_id, product_id, shipped_status, created_at, warehouse_id, weight, value) VALUES (?, ?, 'shipping', UTC_TIMESTAMP(), ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, order.UserID, order.ProductID, warehouseOrDefault(order.WarehouseID), order.Weight, order.Value)
 	if err != nil {
-		return "", err
+		return 0, translateError(err)
 	}
 	id, err := result.LastInsertId()
 	if err != nil {
-		return "", err
+		return 0, err
 	}
-	return fmt.Sprintf("%d", id), nil
+	return model.OrderID(id), nil
 }
 
 // 複数の注文を一括で作成し、生成された注文IDのリストを返す
-func (r *OrderRepository) BulkCreate(ctx context.Context, orders []model.Order) ([]string, error) {
+// 重さと価値は各注文の値を注文時点のものとして保存する
+func (r *OrderRepository) BulkCreate(ctx context.Context, orders []model.Order) ([]model.OrderID, error) {
 	if len(orders) == 0 {
-		return []string{}, nil
+		return []model.OrderID{}, nil
 	}
 
 	// バルクINSERTのクエリを構築
-	valuesPlaceholder := strings.Repeat("(?, ?, 'shipping', NOW()),", len(orders))
+	valuesPlaceholder := strings.Repeat("(?, ?, 'shipping', UTC_TIMESTAMP(), ?, ?, ?, ?, ?, ?, ?, ?),", len(orders))
 	valuesPlaceholder = valuesPlaceholder[:len(valuesPlaceholder)-1]
-	query := fmt.Sprintf("INSERT INTO orders (user_id, product_id, shipped_status, created_at) VALUES %s", valuesPlaceholder)
+	query := fmt.Sprintf("INSERT INTO orders (user_id, product_id, shipped_status, created_at, warehouse_id, payment_id, discount, coupon_code, note, address_id, weight, value) VALUES %s", valuesPlaceholder)
 
 	// パラメータを展開
-	args := make([]interface{}, 0, len(orders)*2)
+	args := make([]interface{}, 0, len(orders)*10)
 	for _, order := range orders {
-		args = append(args, order.UserID, order.ProductID)
+		args = append(args, order.UserID, order.ProductID, warehouseOrDefault(order.WarehouseID), nullableID(order.PaymentID),
+			order.Discount, nullableString(order.CouponCode), nullableString(order.Note), nullableID(order.AddressID),
+			order.Weight, order.Value)
 	}
 
 	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
-	}
-
-	// 最初に挿入されたIDを取得
-	firstID, err := result.LastInsertId()
-	if err != nil {
-		return nil, err
-	}
-
-	// 連続したIDのリストを生成
-	orderIDs := make([]string, len(orders))
-	for i := range orders {
-		orderIDs[i] = fmt.Sprintf("%d", firstID+int64(i))
+		return nil, translateError(err)
 	}
 
-	return orderIDs, nil
+	return insertedIDs[model.OrderID](result, len(orders))
 }
 
 // 単一の注文のステータスを更新
-func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID int64, newStatus string) error {
+func (r *OrderRepository) UpdateStatus(ctx context.Context, orderID model.OrderID, newStatus string) error {
 	query := "UPDATE orders SET shipped_status = ? WHERE order_id = ?"
 	_, err := r.db.ExecContext(ctx, query, newStatus, orderID)
-	return err
+	return translateError(err)
 }
 
 // 複数の注文IDのステータスを一括で更新
 // 主に配送ロボットが注文を引き受けた際に一括更新をするために使用
-func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64, newStatus string) error {
+func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []model.OrderID, newStatus string) error {
 	if len(orderIDs) == 0 {
 		return nil
 	}
@@ -88,11 +81,11 @@ func (r *OrderRepository) UpdateStatuses(ctx context.Context, orderIDs []int64,
 	}
 	query = r.db.Rebind(query)
 	_, err = r.db.ExecContext(ctx, query, args...)
-	return err
+	return translateError(err)
 }
 
 // UpdateStatusesChunked は大量注文でも安全にステータスを更新する
-func (r *OrderRepository) UpdateStatusesChunked(ctx context.Context, orderIDs []int64, newStatus string) error {
+func (r *OrderRepository) UpdateStatusesChunked(ctx context.Context, orderIDs []model.OrderID, newStatus string) error {
 	if len(orderIDs) == 0 {
 		return nil
 	}
@@ -118,38 +111,187 @@ func (r *OrderRepository) UpdateStatusesChunked(ctx context.Context, orderIDs []
 
 		// 実行
 		if _, err := r.db.ExecContext(ctx, query, args...); err != nil {
-			return err
+			return translateError(err)
 		}
 	}
 
 	return nil
 }
 
+// ステータスの変更履歴を記録する
+func (r *OrderRepository) RecordStatusHistory(ctx context.Context, orderIDs []model.OrderID, status string) error {
+	if len(orderIDs) == 0 {
+		return nil
+	}
+	placeholders := strings.Repeat("(?, ?, UTC_TIMESTAMP(6)),", len(orderIDs))
+	query := "INSERT INTO order_status_history (order_id, status, changed_at) VALUES " + placeholders[:len(placeholders)-1]
+	args := make([]interface{}, 0, len(orderIDs)*2)
+	for _, id := range orderIDs {
+		args = append(args, id, status)
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return translateError(err)
+}
+
+// 配送待ちの注文を1つのUPDATEで配送中にし、ロボットと計画のIDを付ける
+// 他の計画が先に確保した注文は対象にならないため、実際に確保できた注文は FindClaimed で読み戻すこと
+func (r *OrderRepository) ClaimForDelivery(ctx context.Context, orderIDs []model.OrderID, robotID, planID string) (int64, error) {
+	if len(orderIDs) == 0 {
+		return 0, nil
+	}
+	query, args, err := sqlx.In(`
+		UPDATE orders SET shipped_status = 'delivering', robot_id = ?, plan_id = ?
+		WHERE shipped_status = 'shipping' AND order_id IN (?)`, robotID, planID, orderIDs)
+	if err != nil {
+		return 0, err
+	}
+	result, err := r.db.ExecContext(ctx, r.db.Rebind(query), args...)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.RowsAffected()
+}
+
+// 計画で確保した注文のIDと備考 (備考のない注文は空文字列)
+func (r *OrderRepository) FindClaimed(ctx context.Context, planID string) (map[model.OrderID]string, error) {
+	var rows []struct {
+		OrderID model.OrderID  `db:"order_id"`
+		Note    sql.NullString `db:"note"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, "SELECT order_id, note FROM orders WHERE plan_id = ?", planID); err != nil {
+		return nil, translateError(err)
+	}
+	claimed := make(map[model.OrderID]string, len(rows))
+	for _, row := range rows {
+		claimed[row.OrderID] = row.Note.String
+	}
+	return claimed, nil
+}
+
 // 配送中(shipped_status:shipping)の注文一覧を取得
-func (r *OrderRepository) GetShippingOrders(ctx context.Context) ([]model.Order, error) {
+// warehouseID が0の場合は全ての倉庫が対象
+// 重さと価値は注文時点のもの
+func (r *OrderRepository) GetShippingOrders(ctx context.Context, warehouseID int) ([]model.Order, error) {
 	var orders []model.Order
 	query := `
         SELECT
-            o.order_id,
-            p.weight,
-            p.value
-        FROM orders o
-        JOIN products p ON o.product_id = p.product_id
-        WHERE o.shipped_status = 'shipping'
+            order_id,
+            weight,
+            value
+        FROM orders
+        WHERE shipped_status = 'shipping'
     `
-	err := r.db.SelectContext(ctx, &orders, query)
-	return orders, err
+	var args []interface{}
+	if warehouseID != 0 {
+		query += " AND warehouse_id = ?"
+		args = append(args, warehouseID)
+	}
+	err := r.db.SelectContext(ctx, &orders, query, args...)
+	return orders, translateError(err)
+}
+
+// 注文IDが afterID より大きい配送待ちの注文を、注文ID順に最大 limit 件取得する
+// zone (倉庫のコード) が空の場合は全ての倉庫が対象。重さと価値は注文時点のもの
+func (r *OrderRepository) ListShippingCandidates(ctx context.Context, afterID model.OrderID, zone string, limit int) ([]model.ShippingCandidate, error) {
+	candidates := []model.ShippingCandidate{}
+	query := `
+		SELECT o.order_id, o.weight, o.value, w.code AS zone
+		FROM orders o
+		JOIN warehouses w ON o.warehouse_id = w.warehouse_id
+		WHERE o.shipped_status = 'shipping' AND o.order_id > ?`
+	args := []interface{}{afterID}
+	if zone != "" {
+		query += " AND w.code = ?"
+		args = append(args, zone)
+	}
+	query += " ORDER BY o.order_id LIMIT ?"
+	args = append(args, limit)
+	err := r.db.SelectContext(ctx, &candidates, query, args...)
+	return candidates, translateError(err)
+}
+
+func warehouseOrDefault(warehouseID int) int {
+	if warehouseID == 0 {
+		return model.DefaultWarehouseID
+	}
+	return warehouseID
+}
+
+func nullableID(id int64) sql.NullInt64 {
+	return sql.NullInt64{Int64: id, Valid: id != 0}
+}
+
+func nullableString(s string) sql.NullString {
+	return sql.NullString{String: s, Valid: s != ""}
+}
+
+// ユーザー自身の注文を1件取得する
+// 他のユーザーの注文の場合も ErrNotFound
+func (r *OrderRepository) FindByID(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Order, error) {
+	var row struct {
+		OrderID       model.OrderID   `db:"order_id"`
+		ProductID     model.ProductID `db:"product_id"`
+		ProductName   string          `db:"product_name"`
+		ShippedStatus string          `db:"shipped_status"`
+		Weight        int             `db:"weight"`
+		Value         int             `db:"value"`
+		CreatedAt     time.Time       `db:"created_at"`
+		ArrivedAt     sql.NullTime    `db:"arrived_at"`
+		Discount      int             `db:"discount"`
+		Note          sql.NullString  `db:"note"`
+	}
+	query := `
+		SELECT o.order_id, o.product_id, p.name AS product_name, o.shipped_status, o.weight, o.value, o.created_at, o.arrived_at, o.discount, o.note
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		WHERE o.order_id = ? AND o.user_id = ?`
+	if err := r.db.GetContext(ctx, &row, query, orderID, userID); err != nil {
+		return model.Order{}, translateError(err)
+	}
+	return model.Order{
+		OrderID:       row.OrderID,
+		ProductID:     row.ProductID,
+		ProductName:   row.ProductName,
+		ShippedStatus: row.ShippedStatus,
+		Weight:        row.Weight,
+		Value:         row.Value,
+		CreatedAt:     row.CreatedAt,
+		ArrivedAt:     row.ArrivedAt,
+		Discount:      row.Discount,
+		Note:          row.Note.String,
+	}, nil
+}
+
+// ユーザー自身の注文の領収書に載せる情報を取得する
+// 価格は注文時点のもの。配送完了時刻は arrived_at がなければステータス履歴から求める。他のユーザーの注文の場合も ErrNotFound
+func (r *OrderRepository) FindReceipt(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Receipt, error) {
+	var receipt model.Receipt
+	query := `
+		SELECT o.order_id, p.name AS product_name, o.value, o.discount, o.shipped_status, o.created_at, t.planned_at,
+			COALESCE(o.arrived_at, (
+				SELECT MIN(h.changed_at) FROM order_status_history h
+				WHERE h.order_id = o.order_id AND h.status = 'completed'
+			)) AS delivered_at
+		FROM orders o
+		JOIN products p ON o.product_id = p.product_id
+		LEFT JOIN order_tracking t ON t.order_id = o.order_id
+		WHERE o.order_id = ? AND o.user_id = ?`
+	err := r.db.GetContext(ctx, &receipt, query, orderID, userID)
+	return receipt, translateError(err)
 }
 
 // 注文履歴一覧を取得
-func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
+func (r *OrderRepository) ListOrders(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Order, int, error) {
 	type orderRow struct {
-		OrderID       int64        `db:"order_id"`
-		ProductID     int          `db:"product_id"`
-		ProductName   string       `db:"product_name"`
-		ShippedStatus string       `db:"shipped_status"`
-		CreatedAt     sql.NullTime `db:"created_at"`
-		ArrivedAt     sql.NullTime `db:"arrived_at"`
+		OrderID       model.OrderID   `db:"order_id"`
+		ProductID     model.ProductID `db:"product_id"`
+		ProductName   string          `db:"product_name"`
+		ShippedStatus string          `db:"shipped_status"`
+		Weight        int             `db:"weight"`
+		Value         int             `db:"value"`
+		CreatedAt     sql.NullTime    `db:"created_at"`
+		ArrivedAt     sql.NullTime    `db:"arrived_at"`
+		Discount      int             `db:"discount"`
 	}
 
 	// WHERE句の構築
@@ -214,8 +356,11 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
 				o.order_id,
 				o.product_id,
 				o.shipped_status,
+				o.weight,
+				o.value,
 				o.created_at,
 				o.arrived_at,
+				o.discount,
 				p.name AS product_name
 			FROM orders o
 			JOIN products p ON o.product_id = p.product_id
@@ -233,10 +378,10 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
 	<-selectDone
 
 	if countErr != nil {
-		return nil, 0, countErr
+		return nil, 0, translateError(countErr)
 	}
 	if selectErr != nil {
-		return nil, 0, selectErr
+		return nil, 0, translateError(selectErr)
 	}
 
 	// モデルに変換
@@ -247,10 +392,143 @@ func (r *OrderRepository) ListOrders(ctx context.Context, userID int, req model.
 			ProductID:     o.ProductID,
 			ProductName:   o.ProductName,
 			ShippedStatus: o.ShippedStatus,
+			Weight:        o.Weight,
+			Value:         o.Value,
 			CreatedAt:     o.CreatedAt.Time,
 			ArrivedAt:     o.ArrivedAt,
+			Discount:      o.Discount,
 		}
 	}
 
 	return orders, total, nil
 }
+
+// ステータス・作成日時・到着日時を指定して注文を一括で作成する
+// シードデータの投入用で、通常の注文作成には BulkCreate を使う。重さと価値は各注文の値を保存する
+func (r *OrderRepository) Import(ctx context.Context, orders []model.Order) error {
+	if len(orders) == 0 {
+		return nil
+	}
+	placeholders := strings.Repeat("(?, ?, ?, ?, ?, ?, ?, ?),", len(orders))
+	query := "INSERT INTO orders (user_id, product_id, shipped_status, created_at, arrived_at, warehouse_id, weight, value) VALUES " + placeholders[:len(placeholders)-1]
+	args := make([]interface{}, 0, len(orders)*8)
+	for _, o := range orders {
+		args = append(args, o.UserID, o.ProductID, o.ShippedStatus, o.CreatedAt, o.ArrivedAt, warehouseOrDefault(o.WarehouseID), o.Weight, o.Value)
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return translateError(err)
+}
+
+// 指定したステータスのまま created_at 以前から残っている注文のIDを取得
+// 運用ツールで滞留した注文を探すために使う
+func (r *OrderRepository) FindIDsByStatus(ctx context.Context, status string, createdBefore time.Time, limit int) ([]model.OrderID, error) {
+	var ids []model.OrderID
+	query := "SELECT order_id FROM orders WHERE shipped_status = ? AND created_at < ? ORDER BY order_id LIMIT ?"
+	err := r.db.SelectContext(ctx, &ids, query, status, createdBefore.UTC(), limit)
+	return ids, translateError(err)
+}
+
+// orderIDs のうち現在 status のものに行ロックを取り、そのIDを返す
+// トランザクション内で呼び出すこと
+func (r *OrderRepository) LockByStatus(ctx context.Context, orderIDs []model.OrderID, status string) ([]model.OrderID, error) {
+	if len(orderIDs) == 0 {
+		return []model.OrderID{}, nil
+	}
+	query, args, err := sqlx.In("SELECT order_id FROM orders WHERE order_id IN (?) AND shipped_status = ? ORDER BY order_id FOR UPDATE", orderIDs, status)
+	if err != nil {
+		return nil, err
+	}
+	var ids []model.OrderID
+	err = r.db.SelectContext(ctx, &ids, r.db.Rebind(query), args...)
+	return ids, translateError(err)
+}
+
+// robotID のロボットが配送中の注文を行ロックし、そのIDを注文ID順に返す
+// ロボットの記録がない (計画で確保する前に配送中になった) 注文はどのロボットからも戻せる
+func (r *OrderRepository) LockDeliveringBy(ctx context.Context, orderIDs []model.OrderID, robotID string) ([]model.OrderID, error) {
+	if len(orderIDs) == 0 {
+		return []model.OrderID{}, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT order_id FROM orders
+		WHERE order_id IN (?) AND shipped_status = 'delivering' AND (robot_id = ? OR robot_id IS NULL)
+		ORDER BY order_id FOR UPDATE`, orderIDs, robotID)
+	if err != nil {
+		return nil, err
+	}
+	var ids []model.OrderID
+	err = r.db.SelectContext(ctx, &ids, r.db.Rebind(query), args...)
+	return ids, translateError(err)
+}
+
+// ロボットが配送できずに戻した回数 (戻したことのない注文は含まない)
+func (r *OrderRepository) CountReturns(ctx context.Context, orderIDs []model.OrderID) (map[model.OrderID]int, error) {
+	counts := make(map[model.OrderID]int)
+	if len(orderIDs) == 0 {
+		return counts, nil
+	}
+	query, args, err := sqlx.In("SELECT order_id, COUNT(*) AS count FROM order_returns WHERE order_id IN (?) GROUP BY order_id", orderIDs)
+	if err != nil {
+		return nil, err
+	}
+	var rows []struct {
+		OrderID model.OrderID `db:"order_id"`
+		Count   int           `db:"count"`
+	}
+	if err := r.db.SelectContext(ctx, &rows, r.db.Rebind(query), args...); err != nil {
+		return nil, translateError(err)
+	}
+	for _, row := range rows {
+		counts[row.OrderID] = row.Count
+	}
+	return counts, nil
+}
+
+// ロボットが配送できずに戻した注文と理由を記録する
+func (r *OrderRepository) RecordReturns(ctx context.Context, robotID string, items []model.ReturnOrderItem) error {
+	if len(items) == 0 {
+		return nil
+	}
+	placeholders := strings.Repeat("(?, ?, ?, UTC_TIMESTAMP(6)),", len(items))
+	query := "INSERT INTO order_returns (order_id, robot_id, reason, returned_at) VALUES " + placeholders[:len(placeholders)-1]
+	args := make([]interface{}, 0, len(items)*3)
+	for _, item := range items {
+		args = append(args, item.OrderID, robotID, item.Reason)
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return translateError(err)
+}
+
+// ステータスごとの注文数
+func (r *OrderRepository) CountByStatus(ctx context.Context) (map[string]int, error) {
+	var rows []struct {
+		Status string `db:"shipped_status"`
+		Count  int    `db:"count"`
+	}
+	query := "SELECT shipped_status, COUNT(*) AS count FROM orders GROUP BY shipped_status"
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, translateError(err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
+
+// ユーザーの注文のステータスごとの件数
+func (r *OrderRepository) CountByStatusForUser(ctx context.Context, userID model.UserID) (map[string]int, error) {
+	var rows []struct {
+		Status string `db:"shipped_status"`
+		Count  int    `db:"count"`
+	}
+	query := "SELECT shipped_status, COUNT(*) AS count FROM orders WHERE user_id = ? GROUP BY shipped_status"
+	if err := r.db.SelectContext(ctx, &rows, query, userID); err != nil {
+		return nil, translateError(err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Status] = row.Count
+	}
+	return counts, nil
+}
@@ -0,0 +1,77 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type OutboxRepository struct {
+	db DBTX
+}
+
+func NewOutboxRepository(db DBTX) *OutboxRepository {
+	return &OutboxRepository{db: db}
+}
+
+// イベントをoutboxに書き込む
+// 状態変更と同じトランザクション(txStore)から呼ぶことで、コミットされた変更のイベントだけが配信される
+func (r *OutboxRepository) Enqueue(ctx context.Context, eventType, aggregateID string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+	query := `
+		INSERT INTO outbox_events (event_type, aggregate_id, payload, created_at, next_attempt_at)
+		VALUES (?, ?, ?, UTC_TIMESTAMP(6), UTC_TIMESTAMP(6))`
+	_, err = r.db.ExecContext(ctx, query, eventType, aggregateID, body)
+	return translateError(err)
+}
+
+// 配信対象のイベントを古い順に取得し、行ロックを取る
+// 複数のリレーが同時に動いても同じイベントを取り合わないよう SKIP LOCKED を使う
+// トランザクション内で呼び出し、Lease で配信中にしてからコミットすること
+func (r *OutboxRepository) FetchPending(ctx context.Context, limit, maxAttempts int) ([]model.OutboxEvent, error) {
+	var events []model.OutboxEvent
+	query := `
+		SELECT id, event_type, aggregate_id, payload, attempts, created_at
+		FROM outbox_events
+		WHERE delivered_at IS NULL AND next_attempt_at <= UTC_TIMESTAMP(6) AND attempts < ?
+		ORDER BY id
+		LIMIT ?
+		FOR UPDATE SKIP LOCKED`
+	err := r.db.SelectContext(ctx, &events, query, maxAttempts, limit)
+	return events, translateError(err)
+}
+
+// 配信中として leaseUntil まで他のリレーから取得されないようにする
+// 期限までに配信済み・失敗のどちらも記録されなければ、再び配信対象になる
+func (r *OutboxRepository) Lease(ctx context.Context, ids []int64, leaseUntil time.Time) error {
+	if len(ids) == 0 {
+		return nil
+	}
+	query, args, err := sqlx.In("UPDATE outbox_events SET next_attempt_at = ? WHERE id IN (?)", leaseUntil.UTC(), ids)
+	if err != nil {
+		return err
+	}
+	query = r.db.Rebind(query)
+	_, err = r.db.ExecContext(ctx, query, args...)
+	return translateError(err)
+}
+
+// 配信済みにする
+func (r *OutboxRepository) MarkDelivered(ctx context.Context, id int64) error {
+	query := "UPDATE outbox_events SET delivered_at = UTC_TIMESTAMP(6), attempts = attempts + 1, last_error = NULL WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return translateError(err)
+}
+
+// 配信失敗を記録し、次回の配信時刻を設定する
+func (r *OutboxRepository) MarkFailed(ctx context.Context, id int64, lastErr string, nextAttemptAt time.Time) error {
+	query := "UPDATE outbox_events SET attempts = attempts + 1, last_error = ?, next_attempt_at = ? WHERE id = ?"
+	_, err := r.db.ExecContext(ctx, query, lastErr, nextAttemptAt.UTC(), id)
+	return translateError(err)
+}
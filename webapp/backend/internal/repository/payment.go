@@ -0,0 +1,33 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"time"
+)
+
+type PaymentRepository struct {
+	db DBTX
+}
+
+func NewPaymentRepository(db DBTX) *PaymentRepository {
+	return &PaymentRepository{db: db}
+}
+
+// 決済を記録し、生成された決済IDを返す
+func (r *PaymentRepository) Create(ctx context.Context, p model.Payment) (int64, error) {
+	now := time.Now().UTC()
+	query := `
+		INSERT INTO payments (user_id, provider, authorization_id, amount, status, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)`
+	result, err := r.db.ExecContext(ctx, query, p.UserID, p.Provider, p.AuthorizationID, p.Amount, p.Status, now, now)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+func (r *PaymentRepository) UpdateStatus(ctx context.Context, paymentID int64, status string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE payments SET status = ?, updated_at = ? WHERE payment_id = ?", status, time.Now().UTC(), paymentID)
+	return translateError(err)
+}
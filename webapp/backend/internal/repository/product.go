@@ -1,82 +1,123 @@
 package repository
 
 import (
+	"backend/internal/cache"
+	"backend/internal/logging"
 	"backend/internal/model"
 	"context"
 	"fmt"
-	"sync"
-	"time"
-)
+	"strings"
+	"sync/atomic"
 
-type countCacheEntry struct {
-	count int
-	time  time.Time
-}
+	"github.com/jmoiron/sqlx"
+)
 
 type ProductRepository struct {
-	db              DBTX
-	countCache      map[string]countCacheEntry
-	countCacheMutex sync.RWMutex
-	countCacheTTL   time.Duration
+	db DBTX
+	// 同じ条件のCOUNTは同時に1つだけ実行し、期限切れの値は読み込み直す間も返す
+	countCache  *cache.Refresher[int]
+	countWarmed atomic.Bool
+	// 検索の該当件数がこれを超える場合は概数にする (0: 常に正確に数える)
+	approxThreshold int
 }
 
-func NewProductRepository(db DBTX) *ProductRepository {
+func NewProductRepository(db DBTX, countCache *cache.Refresher[int], approxThreshold int) *ProductRepository {
 	return &ProductRepository{
-		db:            db,
-		countCache:    make(map[string]countCacheEntry),
-		countCacheTTL: 60 * time.Second, // 60秒キャッシュ
+		db:              db,
+		countCache:      countCache,
+		approxThreshold: approxThreshold,
 	}
 }
 
 // 商品の総数を取得する関数
 func (r *ProductRepository) CountProducts(ctx context.Context, req model.ListRequest) (int, error) {
-	// キャッシュキーを生成
-	cacheKey := fmt.Sprintf("count:%s", req.Search)
-
-	// キャッシュチェック
-	r.countCacheMutex.RLock()
-	if entry, exists := r.countCache[cacheKey]; exists {
-		if time.Since(entry.time) < r.countCacheTTL {
-			r.countCacheMutex.RUnlock()
-			return entry.count, nil
+	count, err := r.countCache.Get(ctx, fmt.Sprintf("count:%s", req.Search), func(ctx context.Context) (int, error) {
+		var count int
+		countQuery := `SELECT COUNT(*) FROM products`
+		if req.Search == "" {
+			err := r.db.GetContext(ctx, &count, countQuery)
+			return count, translateError(err)
 		}
-	}
-	r.countCacheMutex.RUnlock()
-
-	var count int
-	countQuery := `SELECT COUNT(*) FROM products`
-	if req.Search != "" {
 		countQuery += " WHERE name LIKE ? OR description LIKE ?"
 		searchArg := "%" + req.Search + "%"
 		err := r.db.GetContext(ctx, &count, countQuery, searchArg, searchArg)
-		if err != nil {
-			return 0, err
-		}
-	} else {
-		err := r.db.GetContext(ctx, &count, countQuery)
-		if err != nil {
-			return 0, err
-		}
+		return count, translateError(err)
+	})
+	return count, err
+}
+
+// 一覧に返す総数を数える
+// 検索の該当件数が閾値を超える場合は、閾値+1件まで数えたところで打ち切って概数 (閾値) を返す
+func (r *ProductRepository) countForList(ctx context.Context, req model.ListRequest) (model.ListTotal, error) {
+	if req.Exact || req.Search == "" || r.approxThreshold <= 0 {
+		count, err := r.CountProducts(ctx, req)
+		return model.ListTotal{Count: count}, err
+	}
+	// 正確な件数がキャッシュにあればそれを使う
+	if count, ok := r.countCache.Peek(ctx, fmt.Sprintf("count:%s", req.Search)); ok {
+		return model.ListTotal{Count: count}, nil
 	}
 
-	// キャッシュに保存
-	r.countCacheMutex.Lock()
-	r.countCache[cacheKey] = countCacheEntry{
-		count: count,
-		time:  time.Now(),
+	cacheKey := fmt.Sprintf("capped:%d:%s", r.approxThreshold, req.Search)
+	count, err := r.countCache.Get(ctx, cacheKey, func(ctx context.Context) (int, error) {
+		var count int
+		query := `
+			SELECT COUNT(*) FROM (
+				SELECT 1 FROM products WHERE name LIKE ? OR description LIKE ? LIMIT ?
+			) matched`
+		searchArg := "%" + req.Search + "%"
+		err := r.db.GetContext(ctx, &count, query, searchArg, searchArg, r.approxThreshold+1)
+		return count, translateError(err)
+	})
+	if err != nil {
+		return model.ListTotal{}, err
+	}
+	if count > r.approxThreshold {
+		return model.ListTotal{Count: r.approxThreshold, Approximate: true}, nil
 	}
-	r.countCacheMutex.Unlock()
+	return model.ListTotal{Count: count}, nil
+}
 
-	return count, nil
+// 検索条件なしの総数をキャッシュに載せておく
+// 起動直後の最初のリクエストでCOUNTが走らないようにするため
+func (r *ProductRepository) WarmCountCache(ctx context.Context) error {
+	if _, err := r.CountProducts(ctx, model.ListRequest{}); err != nil {
+		return err
+	}
+	r.countWarmed.Store(true)
+	return nil
 }
 
-// 商品一覧を全件取得し、アプリケーション側でページング処理を行う
-func (r *ProductRepository) ListProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
+// 商品の変更後に総数のキャッシュを破棄する
+func (r *ProductRepository) InvalidateCountCache(ctx context.Context) {
+	if err := r.countCache.Clear(ctx); err != nil {
+		logging.FromContext(ctx).Warn("Failed to clear product count cache", "error", err)
+	}
+}
+
+// WarmCountCache 済みかどうか
+func (r *ProductRepository) IsCountCacheWarm() bool {
+	return r.countWarmed.Load()
+}
+
+// 商品一覧を取得する (画像と説明文を含む)
+func (r *ProductRepository) ListProducts(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Product, model.ListTotal, error) {
 	var products []model.Product
-	baseQuery := `
-		SELECT product_id, name, value, weight, image, description
-		FROM products
-	`
+	total, err := r.list(ctx, &products, "product_id, name, value, weight, image, description", req)
+	return products, total, err
+}
+
+// 商品一覧を画像と説明文を除いて取得する
+// 説明文は一覧の表示に使わず、行の大半を占めるため読み込まない
+func (r *ProductRepository) ListProductSummaries(ctx context.Context, req model.ListRequest) ([]model.ProductSummary, model.ListTotal, error) {
+	var products []model.ProductSummary
+	total, err := r.list(ctx, &products, "product_id, name, value, weight", req)
+	return products, total, err
+}
+
+// 一覧の条件で columns を取得して dest に入れ、総数を返す
+func (r *ProductRepository) list(ctx context.Context, dest any, columns string, req model.ListRequest) (model.ListTotal, error) {
+	baseQuery := "SELECT " + columns + " FROM products"
 	args := []interface{}{}
 
 	if req.Search != "" {
@@ -85,18 +126,81 @@ func (r *ProductRepository) ListProducts(ctx context.Context, userID int, req mo
 		args = append(args, searchArg, searchArg)
 	}
 
-	total, err := r.CountProducts(ctx, req)
+	total, err := r.countForList(ctx, req)
 	if err != nil {
-		return nil, 0, err
+		return model.ListTotal{}, err
 	}
 
+	// SortField と SortOrder は ListRequest.Validate で許可された値に限定されている
 	baseQuery += " ORDER BY " + req.SortField + " " + req.SortOrder + " , product_id ASC LIMIT ? OFFSET ?"
 	args = append(args, req.PageSize, req.Offset)
 
-	err = r.db.SelectContext(ctx, &products, baseQuery, args...)
+	if err := r.db.SelectContext(ctx, dest, baseQuery, args...); err != nil {
+		return model.ListTotal{}, translateError(err)
+	}
+	return total, nil
+}
+
+// 商品を1件取得する。存在しない場合は ErrNotFound
+func (r *ProductRepository) FindByID(ctx context.Context, productID model.ProductID) (model.Product, error) {
+	var product model.Product
+	err := r.db.GetContext(ctx, &product, "SELECT product_id, name, value, weight, image, description FROM products WHERE product_id = ?", productID)
+	return product, translateError(err)
+}
+
+// 商品を一括で作成し、生成された商品IDを返す
+// シードデータの投入用。作成後はカタログのバージョンを更新すること
+func (r *ProductRepository) BulkCreate(ctx context.Context, products []model.Product) ([]model.ProductID, error) {
+	if len(products) == 0 {
+		return []model.ProductID{}, nil
+	}
+	placeholders := strings.Repeat("(?, ?, ?, ?, ?),", len(products))
+	query := "INSERT INTO products (name, value, weight, image, description) VALUES " + placeholders[:len(placeholders)-1]
+	args := make([]interface{}, 0, len(products)*5)
+	for _, p := range products {
+		args = append(args, p.Name, p.Value, p.Weight, p.Image, p.Description)
+	}
+	result, err := r.db.ExecContext(ctx, query, args...)
 	if err != nil {
-		return nil, 0, err
+		return nil, translateError(err)
+	}
+	ids, err := insertedIDs[model.ProductID](result, len(products))
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.BumpCatalogVersion(ctx); err != nil {
+		return nil, err
 	}
+	return ids, nil
+}
+
+// 商品カタログのバージョン
+func (r *ProductRepository) CatalogVersion(ctx context.Context) (int64, error) {
+	var version int64
+	err := r.db.GetContext(ctx, &version, "SELECT version FROM catalog_version WHERE id = 1")
+	return version, translateError(err)
+}
 
-	return products, total, nil
+// 商品カタログのバージョンを進め、新しいバージョンを返す
+func (r *ProductRepository) BumpCatalogVersion(ctx context.Context) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "UPDATE catalog_version SET version = LAST_INSERT_ID(version + 1) WHERE id = 1")
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.LastInsertId()
+}
+
+// 商品IDを指定して商品を取得する
+// 存在しないIDは結果に含まれず、順序は保証しない
+func (r *ProductRepository) GetProductsByIDs(ctx context.Context, productIDs []model.ProductID) ([]model.Product, error) {
+	if len(productIDs) == 0 {
+		return []model.Product{}, nil
+	}
+	query, args, err := sqlx.In("SELECT product_id, name, value, weight, image, description FROM products WHERE product_id IN (?)", productIDs)
+	if err != nil {
+		return nil, err
+	}
+	var products []model.Product
+	err = r.db.SelectContext(ctx, &products, r.db.Rebind(query), args...)
+	return products, translateError(err)
 }
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"backend/internal/model"
 	"context"
 	"time"
 
@@ -16,7 +17,7 @@ func NewSessionRepository(db DBTX) *SessionRepository {
 }
 
 // セッションを作成し、セッションIDと有効期限を返す
-func (r *SessionRepository) Create(ctx context.Context, userBusinessID int, duration time.Duration) (string, time.Time, error) {
+func (r *SessionRepository) Create(ctx context.Context, userBusinessID model.UserID, duration time.Duration) (string, time.Time, error) {
 	sessionUUID, err := uuid.NewRandom()
 	if err != nil {
 		return "", time.Time{}, err
@@ -27,14 +28,14 @@ func (r *SessionRepository) Create(ctx context.Context, userBusinessID int, dura
 	query := "INSERT INTO user_sessions (session_uuid, user_id, expires_at) VALUES (?, ?, ?)"
 	_, err = r.db.ExecContext(ctx, query, sessionIDStr, userBusinessID, expiresAt)
 	if err != nil {
-		return "", time.Time{}, err
+		return "", time.Time{}, translateError(err)
 	}
 	return sessionIDStr, expiresAt, nil
 }
 
 // セッションIDからユーザーIDを取得
-func (r *SessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (int, error) {
-	var userID int
+func (r *SessionRepository) FindUserBySessionID(ctx context.Context, sessionID string) (model.UserID, error) {
+	var userID model.UserID
 	query := `
 		SELECT 
 			u.user_id
@@ -43,7 +44,17 @@ func (r *SessionRepository) FindUserBySessionID(ctx context.Context, sessionID s
 		WHERE s.session_uuid = ? AND s.expires_at > ?`
 	err := r.db.GetContext(ctx, &userID, query, sessionID, time.Now())
 	if err != nil {
-		return 0, err
+		return 0, translateError(err)
 	}
 	return userID, nil
 }
+
+// before より前に期限切れになったセッションを最大 limit 件削除し、削除した件数を返す
+// 1回のDELETEで長くロックを取らないよう、呼び出し側で件数が limit 未満になるまで繰り返す
+func (r *SessionRepository) DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error) {
+	result, err := r.db.ExecContext(ctx, "DELETE FROM user_sessions WHERE expires_at <= ? ORDER BY expires_at LIMIT ?", before, limit)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.RowsAffected()
+}
@@ -0,0 +1,90 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"backend/internal/logging"
+)
+
+// しきい値を超えたクエリの累計件数
+var slowQueryCount atomic.Int64
+
+// これまでに検出したスロークエリの件数を返す
+func SlowQueryCount() int64 {
+	return slowQueryCount.Load()
+}
+
+// 実行時間を計測し、しきい値を超えたクエリをログに出すDBTX
+type slowQueryDB struct {
+	db        DBTX
+	threshold time.Duration
+}
+
+// threshold を超えたクエリをログ出力するデコレータを返す
+// threshold が0以下の場合は何もしない
+func WithSlowQueryLog(threshold time.Duration) DBTXWrapper {
+	return func(db DBTX) DBTX {
+		if threshold <= 0 {
+			return db
+		}
+		return &slowQueryDB{db: db, threshold: threshold}
+	}
+}
+
+func (s *slowQueryDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	start := time.Now()
+	err := s.db.GetContext(ctx, dest, query, args...)
+	s.observe(ctx, "Get", query, time.Since(start), err)
+	return err
+}
+
+func (s *slowQueryDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	start := time.Now()
+	err := s.db.SelectContext(ctx, dest, query, args...)
+	s.observe(ctx, "Select", query, time.Since(start), err)
+	return err
+}
+
+func (s *slowQueryDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	start := time.Now()
+	res, err := s.db.ExecContext(ctx, query, args...)
+	s.observe(ctx, "Exec", query, time.Since(start), err)
+	return res, err
+}
+
+func (s *slowQueryDB) Rebind(query string) string {
+	return s.db.Rebind(query)
+}
+
+func (s *slowQueryDB) observe(ctx context.Context, op, query string, elapsed time.Duration, err error) {
+	if elapsed < s.threshold {
+		return
+	}
+	n := slowQueryCount.Add(1)
+	logging.FromContext(ctx).Warn("slow query",
+		"op", op,
+		"duration_ms", elapsed.Milliseconds(),
+		"error", err,
+		"count", n,
+		"sql", redactSQL(query),
+	)
+}
+
+var (
+	sqlStringLiteral  = regexp.MustCompile(`'(?:[^'\\]|\\.|'')*'`)
+	sqlNumericLiteral = regexp.MustCompile(`\b\d+\b`)
+)
+
+// ログ出力用にSQLを1行にまとめ、リテラル値を ? に置き換える
+// 引数(args)はユーザー入力を含むため出力しない
+func redactSQL(query string) string {
+	query = strings.Join(strings.Fields(query), " ")
+	query = sqlStringLiteral.ReplaceAllString(query, "?")
+	query = sqlNumericLiteral.ReplaceAllString(query, "?")
+	return query
+}
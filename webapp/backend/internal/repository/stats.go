@@ -0,0 +1,117 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"time"
+)
+
+// 管理画面などで使う集計
+type StatsRepository struct {
+	db DBTX
+}
+
+func NewStatsRepository(db DBTX) *StatsRepository {
+	return &StatsRepository{db: db}
+}
+
+// since 以降に作成された注文数
+func (r *StatsRepository) CountCreatedSince(ctx context.Context, since time.Time) (int, error) {
+	var n int
+	err := r.db.GetContext(ctx, &n, "SELECT COUNT(*) FROM orders WHERE created_at >= ?", since.UTC())
+	return n, translateError(err)
+}
+
+// since 以降に配送完了した注文数と、作成から配送完了までの平均秒数 (該当なしの場合は nil)
+// arrived_at がない注文はステータス履歴の完了時刻を使う
+func (r *StatsRepository) DeliveredSince(ctx context.Context, since time.Time) (int, *float64, error) {
+	var row struct {
+		Count int             `db:"count"`
+		Avg   sql.NullFloat64 `db:"avg_seconds"`
+	}
+	query := `
+		SELECT COUNT(*) AS count, AVG(TIMESTAMPDIFF(SECOND, created_at, delivered_at)) AS avg_seconds
+		FROM (
+			SELECT created_at, arrived_at AS delivered_at
+			FROM orders
+			WHERE arrived_at >= ?
+			UNION ALL
+			SELECT o.created_at, MIN(h.changed_at) AS delivered_at
+			FROM order_status_history h
+			JOIN orders o ON o.order_id = h.order_id
+			WHERE h.status = 'completed' AND h.changed_at >= ? AND o.arrived_at IS NULL
+			GROUP BY h.order_id, o.created_at
+		) delivered`
+	if err := r.db.GetContext(ctx, &row, query, since.UTC(), since.UTC()); err != nil {
+		return 0, nil, translateError(err)
+	}
+	if !row.Avg.Valid {
+		return row.Count, nil, nil
+	}
+	return row.Count, &row.Avg.Float64, nil
+}
+
+// since 以降にロボットが配送できずに戻した注文の数 (理由ごと)
+func (r *StatsRepository) ReturnsSince(ctx context.Context, since time.Time) (map[string]int, error) {
+	var rows []struct {
+		Reason string `db:"reason"`
+		Count  int    `db:"count"`
+	}
+	query := "SELECT reason, COUNT(*) AS count FROM order_returns WHERE returned_at >= ? GROUP BY reason"
+	if err := r.db.SelectContext(ctx, &rows, query, since.UTC()); err != nil {
+		return nil, translateError(err)
+	}
+	counts := make(map[string]int, len(rows))
+	for _, row := range rows {
+		counts[row.Reason] = row.Count
+	}
+	return counts, nil
+}
+
+// since 以降に作成された注文のうち、作成から配送完了まで (未完了の場合は現在まで) が sla を超えたものを古い順に返す
+// 完了済みで完了時刻が分からない注文は含めない
+func (r *StatsRepository) DelayedOrders(ctx context.Context, since time.Time, sla time.Duration, limit int) ([]model.DelayedOrder, error) {
+	orders := []model.DelayedOrder{}
+	query := `
+		SELECT order_id, shipped_status, created_at, delivered_at, robot_id,
+			(
+				SELECT r.reason FROM order_returns r
+				WHERE r.order_id = x.order_id
+				ORDER BY r.returned_at DESC, r.id DESC
+				LIMIT 1
+			) AS last_return_reason,
+			TIMESTAMPDIFF(SECOND, created_at, COALESCE(delivered_at, UTC_TIMESTAMP())) AS elapsed_seconds
+		FROM (
+			SELECT o.order_id, o.shipped_status, o.created_at, t.robot_id,
+				CASE
+					WHEN o.arrived_at IS NOT NULL THEN o.arrived_at
+					WHEN o.shipped_status = 'completed' THEN (
+						SELECT MIN(h.changed_at) FROM order_status_history h
+						WHERE h.order_id = o.order_id AND h.status = 'completed'
+					)
+				END AS delivered_at
+			FROM orders o
+			LEFT JOIN order_tracking t ON t.order_id = o.order_id
+			WHERE o.created_at >= ?
+		) x
+		WHERE NOT (shipped_status = 'completed' AND delivered_at IS NULL)
+			AND TIMESTAMPDIFF(SECOND, created_at, COALESCE(delivered_at, UTC_TIMESTAMP())) > ?
+		ORDER BY created_at, order_id
+		LIMIT ?`
+	err := r.db.SelectContext(ctx, &orders, query, since.UTC(), int64(sla/time.Second), limit)
+	return orders, translateError(err)
+}
+
+// since 以降に配送計画を取得したか位置を報告したロボットの数
+func (r *StatsRepository) CountActiveRobots(ctx context.Context, since time.Time) (int, error) {
+	var n int
+	query := `
+		SELECT COUNT(*) FROM (
+			SELECT robot_id FROM robot_locations WHERE reported_at >= ?
+			UNION
+			SELECT robot_id FROM order_tracking WHERE planned_at >= ?
+		) active`
+	err := r.db.GetContext(ctx, &n, query, since.UTC(), since.UTC())
+	return n, translateError(err)
+}
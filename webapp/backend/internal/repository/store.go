@@ -2,44 +2,272 @@ package repository
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/model"
 
 	"github.com/jmoiron/sqlx"
 )
 
+type storeOptions struct {
+	wrappers          []DBTXWrapper
+	productCountCache cache.Cache[cache.Entry[int]]
+	productCountTTL   time.Duration
+	productCountStale time.Duration
+	// トランザクション内のStoreとも共有する (NewStore で作る)
+	productCounts *cache.Refresher[int]
+	// 検索の該当件数がこれを超える場合は数え切らずに概数を返す (0: 常に正確に数える)
+	productCountApproxThreshold int
+}
+
+type StoreOption func(*storeOptions)
+
+// DBTX のデコレータを設定する
+// 先頭から順に内側へ適用され、トランザクション内のStoreにも同じものが適用される
+func WithDBTXWrappers(wrappers ...DBTXWrapper) StoreOption {
+	return func(o *storeOptions) {
+		o.wrappers = append(o.wrappers, wrappers...)
+	}
+}
+
+// 商品総数のキャッシュを設定する
+// ttl を過ぎた総数も staleFor の間は返し、その間に1つのリクエストだけが数え直す
+// 未指定の場合はプロセス内のメモリキャッシュ(TTL 60秒、期限切れ後30秒)を使う
+func WithProductCountCache(c cache.Cache[cache.Entry[int]], ttl, staleFor time.Duration) StoreOption {
+	return func(o *storeOptions) {
+		o.productCountCache = c
+		o.productCountTTL = ttl
+		o.productCountStale = staleFor
+	}
+}
+
+// 商品検索の該当件数が threshold を超える場合、正確な件数を数えずに概数とする
+// 一覧の条件で Exact が指定された場合は常に正確に数える
+func WithApproximateProductCount(threshold int) StoreOption {
+	return func(o *storeOptions) {
+		o.productCountApproxThreshold = threshold
+	}
+}
+
+// ユーザー (MySQL: *UserRepository, メモリ: NewMemoryStore)
+type Users interface {
+	FindByUserName(ctx context.Context, userName string) (*model.User, error)
+	BulkCreate(ctx context.Context, users []model.User) ([]model.UserID, error)
+	FindByID(ctx context.Context, userID model.UserID) (*model.User, error)
+	UpdateTimezone(ctx context.Context, userID model.UserID, timezone string) error
+}
+
+// ログインセッション (MySQL: *SessionRepository, メモリ: NewMemoryStore)
+type Sessions interface {
+	Create(ctx context.Context, userID model.UserID, duration time.Duration) (string, time.Time, error)
+	FindUserBySessionID(ctx context.Context, sessionID string) (model.UserID, error)
+	DeleteExpired(ctx context.Context, before time.Time, limit int) (int64, error)
+}
+
+// 商品 (MySQL: *ProductRepository, メモリ: NewMemoryStore)
+type Products interface {
+	CountProducts(ctx context.Context, req model.ListRequest) (int, error)
+	WarmCountCache(ctx context.Context) error
+	InvalidateCountCache(ctx context.Context)
+	IsCountCacheWarm() bool
+	ListProducts(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Product, model.ListTotal, error)
+	ListProductSummaries(ctx context.Context, req model.ListRequest) ([]model.ProductSummary, model.ListTotal, error)
+	FindByID(ctx context.Context, productID model.ProductID) (model.Product, error)
+	// 商品を追加し、カタログのバージョンを進める
+	BulkCreate(ctx context.Context, products []model.Product) ([]model.ProductID, error)
+	CatalogVersion(ctx context.Context) (int64, error)
+	BumpCatalogVersion(ctx context.Context) (int64, error)
+	GetProductsByIDs(ctx context.Context, productIDs []model.ProductID) ([]model.Product, error)
+}
+
+// 注文 (MySQL: *OrderRepository, メモリ: NewMemoryStore)
+type Orders interface {
+	Create(ctx context.Context, order *model.Order) (model.OrderID, error)
+	BulkCreate(ctx context.Context, orders []model.Order) ([]model.OrderID, error)
+	UpdateStatus(ctx context.Context, orderID model.OrderID, newStatus string) error
+	UpdateStatuses(ctx context.Context, orderIDs []model.OrderID, newStatus string) error
+	UpdateStatusesChunked(ctx context.Context, orderIDs []model.OrderID, newStatus string) error
+	RecordStatusHistory(ctx context.Context, orderIDs []model.OrderID, status string) error
+	ClaimForDelivery(ctx context.Context, orderIDs []model.OrderID, robotID, planID string) (int64, error)
+	FindClaimed(ctx context.Context, planID string) (map[model.OrderID]string, error)
+	GetShippingOrders(ctx context.Context, warehouseID int) ([]model.Order, error)
+	ListShippingCandidates(ctx context.Context, afterID model.OrderID, zone string, limit int) ([]model.ShippingCandidate, error)
+	FindByID(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Order, error)
+	FindReceipt(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Receipt, error)
+	ListOrders(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Order, int, error)
+	Import(ctx context.Context, orders []model.Order) error
+	FindIDsByStatus(ctx context.Context, status string, createdBefore time.Time, limit int) ([]model.OrderID, error)
+	LockByStatus(ctx context.Context, orderIDs []model.OrderID, status string) ([]model.OrderID, error)
+	LockDeliveringBy(ctx context.Context, orderIDs []model.OrderID, robotID string) ([]model.OrderID, error)
+	CountReturns(ctx context.Context, orderIDs []model.OrderID) (map[model.OrderID]int, error)
+	RecordReturns(ctx context.Context, robotID string, items []model.ReturnOrderItem) error
+	CountByStatus(ctx context.Context) (map[string]int, error)
+	CountByStatusForUser(ctx context.Context, userID model.UserID) (map[string]int, error)
+}
+
 type Store struct {
-	db          DBTX
-	UserRepo    *UserRepository
-	SessionRepo *SessionRepository
-	ProductRepo *ProductRepository
-	OrderRepo   *OrderRepository
+	db   DBTX
+	conn *sqlx.DB
+	opts storeOptions
+	// トランザクションのネストの深さ (0: トランザクション外)
+	txDepth int
+	// コミット後に実行する処理 (トランザクション内のみ)
+	afterCommit *[]func()
+	UserRepo    Users
+	SessionRepo Sessions
+	ProductRepo Products
+	OrderRepo   Orders
+	OutboxRepo  *OutboxRepository
+	FlagRepo    *FeatureFlagRepository
+	// 倉庫と在庫
+	WarehouseRepo *WarehouseRepository
+	CartRepo      *CartRepository
+	PaymentRepo   *PaymentRepository
+	CouponRepo    *CouponRepository
+	TrackingRepo  *TrackingRepository
+	// 通知の送信待ちと通知設定
+	NotificationRepo *NotificationRepository
+	AddressRepo      *AddressRepository
+	StatsRepo        *StatsRepository
+	AnalyticsRepo    *AnalyticsRepository
+}
+
+func NewStore(db DBTX, opts ...StoreOption) *Store {
+	var o storeOptions
+	for _, opt := range opts {
+		opt(&o)
+	}
+	if o.productCountCache == nil {
+		o.productCountCache = cache.NewMemory[cache.Entry[int]](0)
+		o.productCountTTL = 60 * time.Second
+		o.productCountStale = 30 * time.Second
+	}
+	o.productCounts = cache.NewRefresher(o.productCountCache, o.productCountTTL, o.productCountStale)
+	return newStore(db, o)
 }
 
-func NewStore(db DBTX) *Store {
+// トランザクション内のStoreもキャッシュなどは元のStoreと共有する
+func newStore(db DBTX, o storeOptions) *Store {
+	conn, _ := db.(*sqlx.DB)
+	for _, wrap := range o.wrappers {
+		db = wrap(db)
+	}
 	return &Store{
-		db:          db,
-		UserRepo:    NewUserRepository(db),
-		SessionRepo: NewSessionRepository(db),
-		ProductRepo: NewProductRepository(db),
-		OrderRepo:   NewOrderRepository(db),
+		db:               db,
+		conn:             conn,
+		opts:             o,
+		UserRepo:         NewUserRepository(db),
+		SessionRepo:      NewSessionRepository(db),
+		ProductRepo:      NewProductRepository(db, o.productCounts, o.productCountApproxThreshold),
+		OrderRepo:        NewOrderRepository(db),
+		OutboxRepo:       NewOutboxRepository(db),
+		FlagRepo:         NewFeatureFlagRepository(db),
+		WarehouseRepo:    NewWarehouseRepository(db),
+		CartRepo:         NewCartRepository(db),
+		PaymentRepo:      NewPaymentRepository(db),
+		CouponRepo:       NewCouponRepository(db),
+		TrackingRepo:     NewTrackingRepository(db),
+		NotificationRepo: NewNotificationRepository(db),
+		AddressRepo:      NewAddressRepository(db),
+		StatsRepo:        NewStatsRepository(db),
+		AnalyticsRepo:    NewAnalyticsRepository(db),
 	}
 }
 
+// デッドロック・ロック待ちのタイムアウトでトランザクションをやり直す回数と、やり直すまでの待ち時間の基準値
+const (
+	maxTxRetries   = 3
+	txRetryBackoff = 20 * time.Millisecond
+)
+
+// fn をトランザクション内で実行する
+// すでにトランザクション内のStoreから呼ばれた場合はSAVEPOINTを使い、
+// fn が失敗してもその部分だけを取り消して外側のトランザクションは継続できる
+// デッドロックなどで失敗した場合は、最も外側のトランザクションごと fn をやり直す (fn は複数回呼ばれうる)
+// やり直しても失敗した場合は ErrRetryable を返す
 func (s *Store) ExecTx(ctx context.Context, fn func(txStore *Store) error) error {
-	db, ok := s.db.(*sqlx.DB)
-	if !ok {
+	if s.txDepth > 0 {
+		return s.execSavepoint(ctx, fn)
+	}
+	if s.conn == nil {
 		return fn(s)
 	}
 
-	tx, err := db.BeginTxx(ctx, nil)
+	for attempt := 0; ; attempt++ {
+		err := s.execTx(ctx, fn)
+		if !errors.Is(err, ErrRetryable) || attempt >= maxTxRetries {
+			return err
+		}
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(txRetryBackoff << attempt):
+		}
+	}
+}
+
+func (s *Store) execTx(ctx context.Context, fn func(txStore *Store) error) error {
+	tx, err := s.conn.BeginTxx(ctx, nil)
 	if err != nil {
-		return err
+		return translateError(err)
 	}
 	defer tx.Rollback()
 
-	txStore := NewStore(tx)
+	txStore := newStore(tx, s.opts)
+	txStore.txDepth = 1
+	txStore.afterCommit = &[]func(){}
 	if err := fn(txStore); err != nil {
 		return err
 	}
 
-	return tx.Commit()
+	if err := tx.Commit(); err != nil {
+		return translateError(err)
+	}
+	for _, hook := range *txStore.afterCommit {
+		hook()
+	}
+	return nil
+}
+
+// トランザクションのコミット後に fn を実行する
+// ロールバックされた場合(SAVEPOINTまでの取り消しを含む)は実行しない
+// トランザクション外のStoreから呼んだ場合は即座に実行する
+func (s *Store) AfterCommit(fn func()) {
+	if s.afterCommit == nil {
+		fn()
+		return
+	}
+	*s.afterCommit = append(*s.afterCommit, fn)
+}
+
+// トランザクション内かどうか
+func (s *Store) InTx() bool {
+	return s.txDepth > 0
+}
+
+func (s *Store) execSavepoint(ctx context.Context, fn func(txStore *Store) error) error {
+	name := fmt.Sprintf("sp_%d", s.txDepth)
+	if _, err := s.db.ExecContext(ctx, "SAVEPOINT "+name); err != nil {
+		return translateError(err)
+	}
+
+	nested := *s
+	nested.txDepth = s.txDepth + 1
+	nested.afterCommit = &[]func(){}
+	if err := fn(&nested); err != nil {
+		if _, rbErr := s.db.ExecContext(ctx, "ROLLBACK TO SAVEPOINT "+name); rbErr != nil {
+			return errors.Join(err, &dbError{kind: ErrSavepointLost, err: translateError(rbErr)})
+		}
+		return err
+	}
+
+	if _, err := s.db.ExecContext(ctx, "RELEASE SAVEPOINT "+name); err != nil {
+		return translateError(err)
+	}
+	// 取り消されなかった分だけを外側のトランザクションに引き継ぐ
+	*s.afterCommit = append(*s.afterCommit, *nested.afterCommit...)
+	return nil
 }
@@ -0,0 +1,47 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// クエリ単位のタイムアウトを設定するDBTX
+// リクエスト全体のタイムアウトとは別に、1本の遅いクエリが持ち時間を使い切らないようにする
+type timeoutDB struct {
+	db      DBTX
+	timeout time.Duration
+}
+
+// 各クエリに timeout の期限を設定するデコレータを返す
+// timeout が0以下の場合は何もしない
+func WithQueryTimeout(timeout time.Duration) DBTXWrapper {
+	return func(db DBTX) DBTX {
+		if timeout <= 0 {
+			return db
+		}
+		return &timeoutDB{db: db, timeout: timeout}
+	}
+}
+
+func (t *timeoutDB) GetContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.db.GetContext(ctx, dest, query, args...)
+}
+
+func (t *timeoutDB) SelectContext(ctx context.Context, dest interface{}, query string, args ...interface{}) error {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.db.SelectContext(ctx, dest, query, args...)
+}
+
+func (t *timeoutDB) ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error) {
+	ctx, cancel := context.WithTimeout(ctx, t.timeout)
+	defer cancel()
+	return t.db.ExecContext(ctx, query, args...)
+}
+
+func (t *timeoutDB) Rebind(query string) string {
+	return t.db.Rebind(query)
+}
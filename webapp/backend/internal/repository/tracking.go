@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+	"database/sql"
+	"strings"
+)
+
+type TrackingRepository struct {
+	db DBTX
+}
+
+func NewTrackingRepository(db DBTX) *TrackingRepository {
+	return &TrackingRepository{db: db}
+}
+
+// 注文の追跡情報を記録する
+// 再度配送計画に入った注文はトークンを変えずに担当ロボットと到着予定時刻だけを更新する
+func (r *TrackingRepository) Upsert(ctx context.Context, trackings []model.OrderTracking) error {
+	if len(trackings) == 0 {
+		return nil
+	}
+	placeholders := strings.Repeat("(?, ?, ?, ?, ?),", len(trackings))
+	query := "INSERT INTO order_tracking (order_id, token, robot_id, planned_at, eta) VALUES " + placeholders[:len(placeholders)-1] +
+		" ON DUPLICATE KEY UPDATE robot_id = VALUES(robot_id), planned_at = VALUES(planned_at), eta = VALUES(eta)"
+	args := make([]interface{}, 0, len(trackings)*5)
+	for _, t := range trackings {
+		args = append(args, t.OrderID, t.Token, t.RobotID, t.PlannedAt, t.ETA)
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return translateError(err)
+}
+
+// ユーザー自身の注文の追跡トークンを取得する
+// 他のユーザーの注文や、まだ配送計画に入っていない注文の場合は ErrNotFound
+func (r *TrackingRepository) FindTokenByOrder(ctx context.Context, userID model.UserID, orderID model.OrderID) (string, error) {
+	var token string
+	query := `
+		SELECT t.token
+		FROM order_tracking t
+		JOIN orders o ON t.order_id = o.order_id
+		WHERE t.order_id = ? AND o.user_id = ?`
+	err := r.db.GetContext(ctx, &token, query, orderID, userID)
+	return token, translateError(err)
+}
+
+// トークンから配送状況を取得する。存在しない場合は ErrNotFound
+func (r *TrackingRepository) FindByToken(ctx context.Context, token string) (model.TrackingInfo, model.OrderTracking, error) {
+	var row struct {
+		model.OrderTracking
+		ProductName   string       `db:"product_name"`
+		ShippedStatus string       `db:"shipped_status"`
+		ArrivedAt     sql.NullTime `db:"arrived_at"`
+	}
+	query := `
+		SELECT t.order_id, t.token, t.robot_id, t.planned_at, t.eta, p.name AS product_name, o.shipped_status, o.arrived_at
+		FROM order_tracking t
+		JOIN orders o ON t.order_id = o.order_id
+		JOIN products p ON o.product_id = p.product_id
+		WHERE t.token = ?`
+	if err := r.db.GetContext(ctx, &row, query, token); err != nil {
+		return model.TrackingInfo{}, model.OrderTracking{}, translateError(err)
+	}
+	info := model.TrackingInfo{
+		Token:         row.Token,
+		ProductName:   row.ProductName,
+		ShippedStatus: row.ShippedStatus,
+	}
+	if row.ArrivedAt.Valid {
+		info.ArrivedAt = &row.ArrivedAt.Time
+	}
+	return info, row.OrderTracking, nil
+}
+
+// ロボットの現在位置を記録する
+func (r *TrackingRepository) SaveLocation(ctx context.Context, loc model.RobotLocation) error {
+	query := `
+		INSERT INTO robot_locations (robot_id, latitude, longitude, reported_at) VALUES (?, ?, ?, ?)
+		ON DUPLICATE KEY UPDATE latitude = VALUES(latitude), longitude = VALUES(longitude), reported_at = VALUES(reported_at)`
+	_, err := r.db.ExecContext(ctx, query, loc.RobotID, loc.Latitude, loc.Longitude, loc.ReportedAt)
+	return translateError(err)
+}
+
+// ロボットが最後に報告した位置。報告がない場合は ErrNotFound
+func (r *TrackingRepository) FindLocation(ctx context.Context, robotID string) (model.RobotLocation, error) {
+	var loc model.RobotLocation
+	query := "SELECT robot_id, latitude, longitude, reported_at FROM robot_locations WHERE robot_id = ?"
+	err := r.db.GetContext(ctx, &loc, query, robotID)
+	return loc, translateError(err)
+}
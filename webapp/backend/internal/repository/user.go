@@ -2,8 +2,7 @@ package repository
 
 import (
 	"context"
-	"database/sql"
-	"errors"
+	"strings"
 
 	"backend/internal/model"
 )
@@ -24,10 +23,44 @@ func (r *UserRepository) FindByUserName(ctx context.Context, userName string) (*
 
 	err := r.db.GetContext(ctx, &user, query, userName)
 	if err != nil {
-		if errors.Is(err, sql.ErrNoRows) {
-			return nil, err
-		}
-		return nil, err
+		return nil, translateError(err)
 	}
 	return &user, nil
 }
+
+// ユーザーを一括で作成し、生成されたユーザーIDを返す
+// シードデータの投入用
+func (r *UserRepository) BulkCreate(ctx context.Context, users []model.User) ([]model.UserID, error) {
+	if len(users) == 0 {
+		return []model.UserID{}, nil
+	}
+	placeholders := strings.Repeat("(?, ?),", len(users))
+	query := "INSERT INTO users (password_hash, user_name) VALUES " + placeholders[:len(placeholders)-1]
+	args := make([]interface{}, 0, len(users)*2)
+	for _, u := range users {
+		args = append(args, u.PasswordHash, u.UserName)
+	}
+	result, err := r.db.ExecContext(ctx, query, args...)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return insertedIDs[model.UserID](result, len(users))
+}
+
+// ユーザーIDからユーザー情報を取得
+func (r *UserRepository) FindByID(ctx context.Context, userID model.UserID) (*model.User, error) {
+	var user model.User
+	query := "SELECT user_id, user_name, timezone FROM users WHERE user_id = ?"
+
+	err := r.db.GetContext(ctx, &user, query, userID)
+	if err != nil {
+		return nil, translateError(err)
+	}
+	return &user, nil
+}
+
+// 表示用のタイムゾーンを更新
+func (r *UserRepository) UpdateTimezone(ctx context.Context, userID model.UserID, timezone string) error {
+	_, err := r.db.ExecContext(ctx, "UPDATE users SET timezone = ? WHERE user_id = ?", timezone, userID)
+	return translateError(err)
+}
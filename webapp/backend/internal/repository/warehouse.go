@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"backend/internal/model"
+	"context"
+
+	"github.com/jmoiron/sqlx"
+)
+
+type WarehouseRepository struct {
+	db DBTX
+}
+
+func NewWarehouseRepository(db DBTX) *WarehouseRepository {
+	return &WarehouseRepository{db: db}
+}
+
+func (r *WarehouseRepository) List(ctx context.Context) ([]model.Warehouse, error) {
+	var warehouses []model.Warehouse
+	query := "SELECT warehouse_id, code, name FROM warehouses ORDER BY warehouse_id"
+	err := r.db.SelectContext(ctx, &warehouses, query)
+	return warehouses, translateError(err)
+}
+
+// コードから倉庫を取得する。存在しない場合は ErrNotFound
+func (r *WarehouseRepository) FindByCode(ctx context.Context, code string) (model.Warehouse, error) {
+	var w model.Warehouse
+	query := "SELECT warehouse_id, code, name FROM warehouses WHERE code = ?"
+	err := r.db.GetContext(ctx, &w, query, code)
+	return w, translateError(err)
+}
+
+// 倉庫を作成する。コードが重複する場合は ErrConflict
+func (r *WarehouseRepository) Create(ctx context.Context, code, name string) (model.Warehouse, error) {
+	result, err := r.db.ExecContext(ctx, "INSERT INTO warehouses (code, name) VALUES (?, ?)", code, name)
+	if err != nil {
+		return model.Warehouse{}, translateError(err)
+	}
+	id, err := result.LastInsertId()
+	if err != nil {
+		return model.Warehouse{}, err
+	}
+	return model.Warehouse{WarehouseID: int(id), Code: code, Name: name}, nil
+}
+
+// 商品の倉庫ごとの在庫を取得し、行ロックを取る
+// 注文の割り当てと在庫の引き当てを同じトランザクション内で行うために使う
+func (r *WarehouseRepository) LockStocks(ctx context.Context, productIDs []model.ProductID) ([]model.ProductStock, error) {
+	if len(productIDs) == 0 {
+		return []model.ProductStock{}, nil
+	}
+	query, args, err := sqlx.In(`
+		SELECT product_id, warehouse_id, quantity
+		FROM product_stocks
+		WHERE product_id IN (?)
+		ORDER BY product_id, warehouse_id
+		FOR UPDATE`, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	var stocks []model.ProductStock
+	err = r.db.SelectContext(ctx, &stocks, r.db.Rebind(query), args...)
+	return stocks, translateError(err)
+}
+
+// 在庫を quantity 減らす
+func (r *WarehouseRepository) DecrementStock(ctx context.Context, productID model.ProductID, warehouseID, quantity int) error {
+	query := "UPDATE product_stocks SET quantity = quantity - ? WHERE product_id = ? AND warehouse_id = ? AND quantity >= ?"
+	result, err := r.db.ExecContext(ctx, query, quantity, productID, warehouseID, quantity)
+	if err != nil {
+		return translateError(err)
+	}
+	if n, err := result.RowsAffected(); err == nil && n == 0 {
+		return ErrConflict
+	}
+	return nil
+}
+
+// 在庫数を設定する
+func (r *WarehouseRepository) SetStock(ctx context.Context, productID model.ProductID, warehouseID, quantity int) error {
+	query := `
+		INSERT INTO product_stocks (product_id, warehouse_id, quantity) VALUES (?, ?, ?)
+		ON DUPLICATE KEY UPDATE quantity = VALUES(quantity)`
+	_, err := r.db.ExecContext(ctx, query, productID, warehouseID, quantity)
+	return translateError(err)
+}
+
+// 在庫が threshold を下回っている商品を在庫の少ない順に取得する
+func (r *WarehouseRepository) ListLowStocks(ctx context.Context, threshold int) ([]model.LowStock, error) {
+	stocks := []model.LowStock{}
+	query := `
+		SELECT s.product_id, p.name AS product_name, s.warehouse_id, w.code AS warehouse_code, s.quantity, a.alerted_at
+		FROM product_stocks s
+		JOIN products p ON p.product_id = s.product_id
+		JOIN warehouses w ON w.warehouse_id = s.warehouse_id
+		LEFT JOIN low_stock_alerts a ON a.product_id = s.product_id AND a.warehouse_id = s.warehouse_id
+		WHERE s.quantity < ?
+		ORDER BY s.quantity, s.product_id, s.warehouse_id`
+	err := r.db.SelectContext(ctx, &stocks, query, threshold)
+	return stocks, translateError(err)
+}
+
+// 在庫が threshold を下回っていて、まだアラートを出していないものを最大 limit 件取得する
+func (r *WarehouseRepository) FindUnalertedLowStocks(ctx context.Context, threshold, limit int) ([]model.ProductStock, error) {
+	var stocks []model.ProductStock
+	query := `
+		SELECT s.product_id, s.warehouse_id, s.quantity
+		FROM product_stocks s
+		LEFT JOIN low_stock_alerts a ON a.product_id = s.product_id AND a.warehouse_id = s.warehouse_id
+		WHERE s.quantity < ? AND a.product_id IS NULL
+		ORDER BY s.product_id, s.warehouse_id
+		LIMIT ?`
+	err := r.db.SelectContext(ctx, &stocks, query, threshold, limit)
+	return stocks, translateError(err)
+}
+
+// アラートを出したことを記録する
+// 他のプロセスが先に記録していた場合は false を返す
+func (r *WarehouseRepository) MarkLowStockAlerted(ctx context.Context, stock model.ProductStock) (bool, error) {
+	query := `
+		INSERT IGNORE INTO low_stock_alerts (product_id, warehouse_id, quantity, alerted_at)
+		VALUES (?, ?, ?, UTC_TIMESTAMP(6))`
+	result, err := r.db.ExecContext(ctx, query, stock.ProductID, stock.WarehouseID, stock.Quantity)
+	if err != nil {
+		return false, translateError(err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+	return n == 1, nil
+}
+
+// 在庫が threshold 以上に戻った (または在庫の管理をやめた) もののアラートを解除する
+func (r *WarehouseRepository) ResolveLowStockAlerts(ctx context.Context, threshold int) (int64, error) {
+	query := `
+		DELETE a FROM low_stock_alerts a
+		LEFT JOIN product_stocks s ON s.product_id = a.product_id AND s.warehouse_id = a.warehouse_id
+		WHERE s.product_id IS NULL OR s.quantity >= ?`
+	result, err := r.db.ExecContext(ctx, query, threshold)
+	if err != nil {
+		return 0, translateError(err)
+	}
+	return result.RowsAffected()
+}
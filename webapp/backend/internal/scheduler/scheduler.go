@@ -0,0 +1,145 @@
+package scheduler
+
+import (
+	"backend/internal/logging"
+	"backend/internal/metrics"
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"runtime/debug"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+var ErrUnknownJob = errors.New("unknown job")
+
+// 定期実行するジョブ
+type Job struct {
+	Name     string
+	Interval time.Duration
+	// 実行ごとに [0, Jitter) のランダムな待ち時間を加える
+	// 複数のバックエンドが同時に同じジョブを走らせないようにするため
+	Jitter time.Duration
+	// 1回の実行の制限時間 (0: 制限なし)
+	Timeout time.Duration
+	Run     func(ctx context.Context) error
+}
+
+type jobState struct {
+	Job
+	running atomic.Bool
+}
+
+// 名前付きのジョブを登録し、それぞれの間隔で実行する
+// 同じジョブの実行は重ならず、panicしても他のジョブやプロセスには影響しない
+type Scheduler struct {
+	mu   sync.Mutex
+	jobs map[string]*jobState
+}
+
+func New() *Scheduler {
+	return &Scheduler{jobs: make(map[string]*jobState)}
+}
+
+func (s *Scheduler) Register(job Job) error {
+	if job.Name == "" || job.Run == nil {
+		return errors.New("scheduler: job name and Run are required")
+	}
+	if job.Interval <= 0 {
+		return fmt.Errorf("scheduler: job %s: interval must be positive", job.Name)
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.jobs[job.Name]; ok {
+		return fmt.Errorf("scheduler: job %s already registered", job.Name)
+	}
+	s.jobs[job.Name] = &jobState{Job: job}
+	return nil
+}
+
+// 登録済みのジョブを ctx がキャンセルされるまで実行し、全てのジョブの終了を待って戻る
+// Run の後に登録されたジョブは実行されない
+func (s *Scheduler) Run(ctx context.Context) {
+	s.mu.Lock()
+	jobs := make([]*jobState, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		jobs = append(jobs, j)
+	}
+	s.mu.Unlock()
+
+	var wg sync.WaitGroup
+	for _, j := range jobs {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.loop(ctx, j)
+		}()
+	}
+	wg.Wait()
+}
+
+// ジョブを即座に1回実行する (運用ツールなどからの手動実行用)
+// 同じジョブが実行中の場合は何もせず false を返す
+func (s *Scheduler) RunNow(ctx context.Context, name string) (bool, error) {
+	s.mu.Lock()
+	j, ok := s.jobs[name]
+	s.mu.Unlock()
+	if !ok {
+		return false, fmt.Errorf("%w: %s", ErrUnknownJob, name)
+	}
+	return s.execute(ctx, j)
+}
+
+func (s *Scheduler) loop(ctx context.Context, j *jobState) {
+	for {
+		wait := j.Interval
+		if j.Jitter > 0 {
+			wait += rand.N(j.Jitter)
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		_, _ = s.execute(ctx, j)
+	}
+}
+
+func (s *Scheduler) execute(ctx context.Context, j *jobState) (ran bool, err error) {
+	if !j.running.CompareAndSwap(false, true) {
+		metrics.JobRuns.WithLabelValues(j.Name, "skipped").Inc()
+		return false, nil
+	}
+	defer j.running.Store(false)
+
+	if j.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, j.Timeout)
+		defer cancel()
+	}
+	ctx = logging.With(ctx, "job", j.Name)
+	logger := logging.FromContext(ctx)
+
+	start := time.Now()
+	defer func() {
+		result := "success"
+		if r := recover(); r != nil {
+			result = "panic"
+			err = fmt.Errorf("job %s panicked: %v", j.Name, r)
+			logger.Error("job panicked", "panic", r, "stack", string(debug.Stack()))
+		} else if err != nil {
+			result = "error"
+			logger.Error("job failed", "error", err)
+		} else {
+			metrics.JobLastSuccess.WithLabelValues(j.Name).SetToCurrentTime()
+		}
+		metrics.JobRuns.WithLabelValues(j.Name, result).Inc()
+		metrics.JobDuration.WithLabelValues(j.Name).Observe(time.Since(start).Seconds())
+	}()
+
+	return true, j.Run(ctx)
+}
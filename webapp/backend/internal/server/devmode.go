@@ -0,0 +1,94 @@
+package server
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DEV_MODE で投入するサンプルデータの量
+// ユーザーは e2e テストと同じ user001 〜 (パスワードは全員 devUserPassword)
+const (
+	devUserCount    = 10
+	devUserPassword = "password"
+	devProductCount = 200
+	devOrderCount   = 300
+)
+
+var (
+	devProductAdjectives = []string{"軽量", "頑丈な", "高級", "お徳用", "コンパクト", "業務用", "限定", "折りたたみ式", "防水", "静音"}
+	devProductNouns      = []string{"ノート", "ボールペン", "マグカップ", "収納ボックス", "ケーブル", "モバイルバッテリー", "ブランケット", "水筒", "ハサミ", "スピーカー", "デスクライト", "リュック"}
+)
+
+// メモリのStoreにサンプルのユーザー・商品・注文を入れる
+// 起動のたびに同じデータになるよう乱数の種は固定する
+func seedDevData(ctx context.Context, store *repository.Store) error {
+	rng := rand.New(rand.NewSource(1))
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(devUserPassword), bcrypt.DefaultCost)
+	if err != nil {
+		return err
+	}
+	users := make([]model.User, devUserCount)
+	for i := range users {
+		users[i] = model.User{UserName: fmt.Sprintf("user%03d", i+1), PasswordHash: string(hash)}
+	}
+	userIDs, err := store.UserRepo.BulkCreate(ctx, users)
+	if err != nil {
+		return err
+	}
+
+	products := make([]model.Product, devProductCount)
+	for i := range products {
+		adj := devProductAdjectives[rng.Intn(len(devProductAdjectives))]
+		noun := devProductNouns[rng.Intn(len(devProductNouns))]
+		products[i] = model.Product{
+			Name:        fmt.Sprintf("%s%s %d", adj, noun, i+1),
+			Value:       100 + rng.Intn(50)*100,
+			Weight:      1 + rng.Intn(50),
+			Image:       fmt.Sprintf("chello_%02d.png", 1+rng.Intn(3)),
+			Description: fmt.Sprintf("%sの%sです。開発用のサンプルデータです。", adj, noun),
+		}
+	}
+	productIDs, err := store.ProductRepo.BulkCreate(ctx, products)
+	if err != nil {
+		return err
+	}
+
+	// 直近30日に作成された注文。半分ほどを配送完了、残りを配送待ち・配送中にする
+	now := time.Now().UTC().Truncate(time.Second)
+	orders := make([]model.Order, devOrderCount)
+	for i := range orders {
+		createdAt := now.Add(-time.Duration(rng.Int63n(int64(30 * 24 * time.Hour)))).Truncate(time.Second)
+		p := rng.Intn(len(productIDs))
+		order := model.Order{
+			UserID:    userIDs[rng.Intn(len(userIDs))],
+			ProductID: productIDs[p],
+			Weight:    products[p].Weight,
+			Value:     products[p].Value,
+			CreatedAt: createdAt,
+		}
+		switch r := rng.Float64(); {
+		case r < 0.4:
+			order.ShippedStatus = "shipping"
+		case r < 0.5:
+			order.ShippedStatus = "delivering"
+		default:
+			order.ShippedStatus = "completed"
+			arrivedAt := createdAt.Add(time.Duration(1+rng.Intn(72)) * time.Hour)
+			if arrivedAt.After(now) {
+				arrivedAt = now
+			}
+			order.ArrivedAt = sql.NullTime{Time: arrivedAt, Valid: true}
+		}
+		orders[i] = order
+	}
+	return store.OrderRepo.Import(ctx, orders)
+}
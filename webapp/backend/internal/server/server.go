@@ -1,107 +1,654 @@
 package server
 
 import (
+	"backend/internal/analytics"
+	"backend/internal/apierror"
+	"backend/internal/cache"
+	"backend/internal/config"
 	"backend/internal/db"
+	"backend/internal/event"
+	"backend/internal/featureflag"
+	"backend/internal/graphql"
 	"backend/internal/handler"
+	"backend/internal/metrics"
 	"backend/internal/middleware"
+	"backend/internal/migration"
+	"backend/internal/model"
+	"backend/internal/notification"
+	"backend/internal/openapi"
+	"backend/internal/outbox"
+	"backend/internal/payment"
 	"backend/internal/repository"
+	"backend/internal/scheduler"
 	"backend/internal/service"
-	"log"
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"log/slog"
 	"net/http"
-	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	chimw "github.com/go-chi/chi/v5/middleware"
 	"github.com/jmoiron/sqlx"
+	"github.com/redis/go-redis/v9"
 	"github.com/riandyrn/otelchi"
 )
 
 type Server struct {
-	Router *chi.Mux
+	Router        *chi.Mux
+	cfg           *config.Config
+	db            *sqlx.DB
+	shutdownHooks []func(ctx context.Context) error
+	bgCtx         context.Context
+	bgCancel      context.CancelFunc
+	bgWG          sync.WaitGroup
 }
 
-func NewServer() (*Server, *sqlx.DB, error) {
-	dbConn, err := db.InitDBConnection()
-	if err != nil {
-		return nil, nil, err
+func NewServer(cfg *config.Config) (*Server, error) {
+	// DEV_MODE ではDBに接続しない (dbConn は nil)
+	var dbConn *sqlx.DB
+	if !cfg.DevMode {
+		var err error
+		dbConn, err = db.InitDBConnection(cfg.DB)
+		if err != nil {
+			return nil, err
+		}
+
+		if cfg.Migrate.OnStartup {
+			if err := runMigrations(dbConn, cfg.Migrate.Timeout); err != nil {
+				dbConn.Close()
+				return nil, err
+			}
+		}
+	}
+
+	bgCtx, bgCancel := context.WithCancel(context.Background())
+	s := &Server{
+		cfg:      cfg,
+		db:       dbConn,
+		bgCtx:    bgCtx,
+		bgCancel: bgCancel,
+	}
+
+	if dbConn != nil {
+		metrics.RegisterDBStats(dbConn.DB, "mysql")
 	}
 
-	store := repository.NewStore(dbConn)
+	caches, closeCache := NewCacheFactory(cfg.Cache)
+	s.OnShutdown(func(context.Context) error { return closeCache() })
+	sessionCache := cache.New[model.UserID](caches, CacheSession)
 
-	authService := service.NewAuthService(store)
+	var breaker *repository.CircuitBreaker
+	if cfg.DB.Breaker.Enabled {
+		breaker = repository.NewCircuitBreaker(repository.BreakerConfig{
+			Window:         cfg.DB.Breaker.Window,
+			MinRequests:    cfg.DB.Breaker.MinRequests,
+			FailureRatio:   cfg.DB.Breaker.FailureRatio,
+			SlowThreshold:  cfg.DB.Breaker.SlowThreshold,
+			OpenDuration:   cfg.DB.Breaker.OpenDuration,
+			HalfOpenProbes: cfg.DB.Breaker.HalfOpenProbes,
+		})
+	}
+
+	var store *repository.Store
+	if cfg.DevMode {
+		store = repository.NewMemoryStore()
+		if err := seedDevData(context.Background(), store); err != nil {
+			return nil, fmt.Errorf("failed to seed dev data: %w", err)
+		}
+		slog.Warn("DEV_MODE is enabled. Data is kept in memory and lost on restart", "users", devUserCount, "password", devUserPassword)
+	} else {
+		store = repository.NewStore(dbConn,
+			repository.WithDBTXWrappers(
+				repository.WithQueryTimeout(cfg.DB.QueryTimeout),
+				repository.WithCircuitBreaker(breaker),
+				repository.WithMetrics(),
+				repository.WithSlowQueryLog(cfg.DB.SlowQueryThreshold),
+			),
+			repository.WithProductCountCache(cache.New[cache.Entry[int]](caches, CacheProductCount), cfg.Cache.ProductCountTTL, cfg.Cache.ProductCountStale),
+			repository.WithApproximateProductCount(cfg.Product.CountApproxThreshold),
+		)
+	}
+
+	flagDefaults, err := featureflag.ParseDefaults(cfg.Flags.Defaults)
+	if err != nil {
+		s.closeDB()
+		return nil, fmt.Errorf("FEATURE_FLAGS: %w", err)
+	}
+	if _, ok := flagDefaults[featureflag.OrderWriteBehind]; !ok {
+		flagDefaults[featureflag.OrderWriteBehind] = model.FeatureFlag{Name: featureflag.OrderWriteBehind, Enabled: true, RolloutPercent: 100}
+	}
+	flags := featureflag.New(store.FlagRepo, flagDefaults, cache.New[model.FeatureFlag](caches, CacheFeatureFlag), cfg.Flags.CacheTTL)
+
+	authService := service.NewAuthService(store, cfg.Auth.SessionTTL)
 	orderService := service.NewOrderService(store)
-	productService := service.NewProductService(store)
-	robotService := service.NewRobotService(store)
+	userService := service.NewUserService(store)
+	// サービスが発行するイベントの購読者
+	events := event.NewBus()
+	outbox.Subscribe(events)
+	notification.Subscribe(events)
+	recorder := analytics.NewRecorder(store)
+	recorder.Subscribe(events)
+	events.Subscribe(func(ctx context.Context, e event.Event) {
+		metrics.EventsPublished.WithLabelValues(e.Type()).Inc()
+	})
+
+	// PAYMENT_PROVIDER は config で stub のみ許可している
+	payments := payment.NewStub(cfg.Payment.StubDeclineAbove)
+	trackingService := service.NewTrackingService(store, cfg.Tracking.ETAPerStop)
+	trackingService.Subscribe(events)
+	reportService := service.NewReportService(store, cfg.Tracking.DeliverySLA)
+	reportService.Subscribe(events)
+	productService := service.NewProductService(store, flags, events, payments)
+	if cfg.OrderWriter.Enabled {
+		orderWriter := productService.NewOrderWriter(service.OrderWriterConfig{
+			BufferSize:    cfg.OrderWriter.BufferSize,
+			MaxBatch:      cfg.OrderWriter.MaxBatch,
+			FlushInterval: cfg.OrderWriter.FlushInterval,
+			Timeout:       cfg.OrderWriter.Timeout,
+		})
+		// HTTPの停止後に残りを書き込んで終了する
+		s.Go(orderWriter.Run)
+	}
+	if cfg.DuplicateOrder.Window > 0 {
+		productService.DetectDuplicateOrders(cache.New[[]model.OrderID](caches, CacheRecentOrder), cfg.DuplicateOrder.Window, cfg.DuplicateOrder.Action)
+	}
+	cartService := service.NewCartService(store, productService)
+	robotService := service.NewRobotService(store, flags, events, cfg.Robot.HomeWarehouses, service.PlannerConfig{
+		Solver:         cfg.Robot.PlanSolver,
+		MaxDPCells:     cfg.Robot.PlanMaxDPCells,
+		GreedyTieBreak: cfg.Robot.PlanGreedyTieBreak,
+	}, cfg.Robot.MaxDeliveryAttempts)
+	inventoryService := service.NewInventoryService(store, events, cfg.Stock.LowThreshold)
 
 	authHandler := handler.NewAuthHandler(authService)
 	productHandler := handler.NewProductHandler(productService)
-	orderHandler := handler.NewOrderHandler(orderService)
+	orderHandler := handler.NewOrderHandler(orderService, userService)
+	userHandler := handler.NewUserHandler(userService)
+	cartHandler := handler.NewCartHandler(cartService)
 	robotHandler := handler.NewRobotHandler(robotService)
+	flagHandler := handler.NewFeatureFlagHandler(flags)
+	warehouseHandler := handler.NewWarehouseHandler(service.NewWarehouseService(store))
+	couponHandler := handler.NewCouponHandler(service.NewCouponService(store))
+	trackingHandler := handler.NewTrackingHandler(trackingService)
+	addressHandler := handler.NewAddressHandler(service.NewAddressService(store))
+	reportHandler := handler.NewReportHandler(reportService)
+	inventoryHandler := handler.NewInventoryHandler(inventoryService)
+	graphqlHandler := graphql.NewHandler(orderService, productService, userService)
+	analyticsHandler := handler.NewAnalyticsHandler(service.NewAnalyticsService(store))
+	dashboardHandler := handler.NewDashboardHandler(service.NewDashboardService(store, cache.New[model.DashboardSummary](caches, CacheDashboard), cfg.Cache.DashboardTTL))
+	var readinessChecks []handler.ReadinessCheck
+	if dbConn != nil {
+		readinessChecks = append(readinessChecks,
+			handler.ReadinessCheck{Name: "db", Check: dbConn.PingContext},
+			handler.ReadinessCheck{Name: "migrations", Check: func(ctx context.Context) error {
+				pending, err := migration.Pending(ctx, dbConn)
+				if err != nil {
+					return err
+				}
+				if len(pending) > 0 {
+					return fmt.Errorf("%d migration(s) not applied", len(pending))
+				}
+				return nil
+			}},
+		)
+	}
+	readinessChecks = append(readinessChecks, handler.ReadinessCheck{Name: "cache", Check: func(ctx context.Context) error {
+		if !store.ProductRepo.IsCountCacheWarm() {
+			return errors.New("product count cache is not warmed up yet")
+		}
+		return nil
+	}})
+	healthHandler := handler.NewHealthHandler(2*time.Second, readinessChecks...)
+
+	// 商品総数のキャッシュを温めておく (完了するまでreadyzは503を返す)
+	s.Go(func(ctx context.Context) {
+		ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+		if err := store.ProductRepo.WarmCountCache(ctx); err != nil {
+			slog.Warn("Failed to warm product count cache", "error", err)
+		}
+	})
 
-	userAuthMW := middleware.UserAuthMiddleware(store.SessionRepo)
+	var publisher outbox.Publisher = outbox.LogPublisher{}
+	if cfg.Outbox.WebhookURL != "" {
+		publisher = outbox.NewWebhookPublisher(cfg.Outbox.WebhookURL, cfg.Outbox.WebhookTimeout)
+	}
+	relay := outbox.NewRelay(store, publisher, outbox.RelayConfig{
+		BatchSize:    cfg.Outbox.BatchSize,
+		MaxAttempts:  cfg.Outbox.MaxAttempts,
+		RetryBackoff: cfg.Outbox.RetryBackoff,
+		Lease:        cfg.Outbox.Lease,
+	})
 
-	robotAPIKey := os.Getenv("ROBOT_API_KEY")
-	if robotAPIKey == "" {
-		log.Println("Warning: ROBOT_API_KEY is not set. Using default key 'test-robot-key'")
-		robotAPIKey = "test-robot-key"
+	sched := scheduler.New()
+	if err := sched.Register(scheduler.Job{
+		Name:     "outbox-relay",
+		Interval: cfg.Outbox.PollInterval,
+		Run:      relay.Drain,
+	}); err != nil {
+		s.closeDB()
+		return nil, err
 	}
-	robotAuthMW := middleware.RobotAuthMiddleware(robotAPIKey)
+
+	dispatcher := notification.NewDispatcher(store, notification.SMTPStub{From: cfg.Notify.From}, notification.DispatcherConfig{
+		BatchSize:    cfg.Notify.BatchSize,
+		MaxAttempts:  cfg.Notify.MaxAttempts,
+		RetryBackoff: cfg.Notify.RetryBackoff,
+	})
+	if err := sched.Register(scheduler.Job{
+		Name:     "notification-dispatcher",
+		Interval: cfg.Notify.PollInterval,
+		Run:      dispatcher.Drain,
+	}); err != nil {
+		s.closeDB()
+		return nil, err
+	}
+
+	if err := sched.Register(scheduler.Job{
+		Name:     "low-stock-check",
+		Interval: cfg.Stock.CheckInterval,
+		Jitter:   cfg.Stock.CheckInterval / 10,
+		Run:      inventoryService.CheckLowStock,
+	}); err != nil {
+		s.closeDB()
+		return nil, err
+	}
+
+	if err := sched.Register(scheduler.Job{
+		Name:     "analytics-flush",
+		Interval: cfg.Analytics.FlushInterval,
+		Run:      recorder.Flush,
+	}); err != nil {
+		s.closeDB()
+		return nil, err
+	}
+	// 停止時点で溜まっている件数を書き込む
+	s.OnShutdown(recorder.Flush)
+
+	if err := sched.Register(scheduler.Job{
+		Name:     "session-cleanup",
+		Interval: cfg.Auth.SessionCleanupInterval,
+		Jitter:   cfg.Auth.SessionCleanupInterval / 10,
+		Run:      authService.PurgeExpiredSessions,
+	}); err != nil {
+		s.closeDB()
+		return nil, err
+	}
+
+	userAuthMW := middleware.UserAuthMiddleware(store.SessionRepo, sessionCache, cfg.Auth.SessionCacheTTL)
+
+	if cfg.UsesDefaultRobotAPIKey() {
+		slog.Warn("ROBOT_API_KEY is not set. Using default key 'test-robot-key'")
+	}
+	if cfg.Auth.RobotAPIKeyPrevious != "" {
+		slog.Warn("ROBOT_API_KEY_PREVIOUS is set. Unset it once all robots use the new key")
+	}
+	robotAuthMW := middleware.RobotAuthMiddleware(cfg.Auth.RobotAPIKey, cfg.Auth.RobotAPIKeyPrevious)
+
+	// 管理用APIはpprofやフラグ・在庫の変更を含むため、既定のキーでは公開しない
+	var adminAuthMW func(http.Handler) http.Handler
+	if cfg.Auth.AdminAPIKey == "" {
+		slog.Warn("ADMIN_API_KEY is not set. The admin API (/api/admin) is disabled")
+	} else {
+		adminAuthMW = middleware.AdminAuthMiddleware(cfg.Auth.AdminAPIKey)
+	}
+
+	// 認証より前に置き、セッション確認のDBアクセスも含めて同時実行数を制限する
+	userLimitMW := middleware.ConcurrencyLimit("user", cfg.HTTP.MaxInflightUser, cfg.HTTP.ShedQueueTimeout, cfg.HTTP.ShedRetryAfter)
+	robotLimitMW := middleware.ConcurrencyLimit("robot", cfg.HTTP.MaxInflightRobot, cfg.HTTP.ShedQueueTimeout, cfg.HTTP.ShedRetryAfter)
+
+	// /api/admin/debug/vars で参照できる実行時情報
+	if dbConn != nil {
+		expvar.Publish("db_stats", expvar.Func(func() any { return dbConn.Stats() }))
+	}
+	expvar.Publish("slow_queries", expvar.Func(func() any { return repository.SlowQueryCount() }))
 
 	r := chi.NewRouter()
+	r.Use(chimw.RequestID)
+	r.Use(middleware.RequestLogger)
 	r.Use(otelchi.Middleware(
 		"backend-api",
 		otelchi.WithChiRoutes(r),
 		otelchi.WithFilter(func(req *http.Request) bool {
-			return req.URL.Path != "/api/health"
+			switch req.URL.Path {
+			case "/api/health", "/healthz", "/readyz", "/metrics":
+				return false
+			}
+			return true
 		}),
 	))
+	r.Use(middleware.MetricsMiddleware)
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusNotFound, apierror.CodeNotFound, "Not found")
+	})
+	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
+		apierror.Write(w, r, http.StatusMethodNotAllowed, apierror.CodeMethodNotAllowed, "Method not allowed")
+	})
+
+	// Prometheusのスクレイプ用 (nginxからは公開されない)
+	r.Handle("/metrics", metrics.Handler())
 
 	r.Get("/api/health", func(w http.ResponseWriter, r *http.Request) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("ok"))
 	})
+	r.Get("/healthz", healthHandler.Liveness)
+	r.Get("/readyz", healthHandler.Readiness)
 
-	s := &Server{
-		Router: r,
-	}
+	s.Router = r
+	s.setupRoutes(routes{
+		auth:          authHandler,
+		product:       productHandler,
+		order:         orderHandler,
+		user:          userHandler,
+		cart:          cartHandler,
+		robot:         robotHandler,
+		flag:          flagHandler,
+		warehouse:     warehouseHandler,
+		coupon:        couponHandler,
+		tracking:      trackingHandler,
+		address:       addressHandler,
+		dashboard:     dashboardHandler,
+		report:        reportHandler,
+		inventory:     inventoryHandler,
+		analytics:     analyticsHandler,
+		graphql:       graphqlHandler,
+		userLimit:     userLimitMW,
+		robotLimit:    robotLimitMW,
+		userAuth:      userAuthMW,
+		robotAuth:     robotAuthMW,
+		adminAuth:     adminAuthMW,
+		streamTimeout: middleware.WriteTimeout(cfg.HTTP.StreamWriteTimeout),
+	})
 
-	s.setupRoutes(authHandler, productHandler, orderHandler, robotHandler, userAuthMW, robotAuthMW)
+	// ジョブの登録が全て終わってから開始する
+	s.Go(sched.Run)
 
-	return s, dbConn, nil
+	return s, nil
 }
 
-func (s *Server) setupRoutes(
-	authHandler *handler.AuthHandler,
-	productHandler *handler.ProductHandler,
-	orderHandler *handler.OrderHandler,
-	robotHandler *handler.RobotHandler,
-	userAuthMW func(http.Handler) http.Handler,
-	robotAuthMW func(http.Handler) http.Handler,
-) {
-	s.Router.Post("/api/login", authHandler.Login)
+// ルーティングに使うハンドラとミドルウェア
+type routes struct {
+	auth      *handler.AuthHandler
+	product   *handler.ProductHandler
+	order     *handler.OrderHandler
+	user      *handler.UserHandler
+	cart      *handler.CartHandler
+	robot     *handler.RobotHandler
+	flag      *handler.FeatureFlagHandler
+	warehouse *handler.WarehouseHandler
+	coupon    *handler.CouponHandler
+	tracking  *handler.TrackingHandler
+	address   *handler.AddressHandler
+	dashboard *handler.DashboardHandler
+	report    *handler.ReportHandler
+	inventory *handler.InventoryHandler
+	analytics *handler.AnalyticsHandler
+	graphql   *graphql.Handler
+
+	userLimit  func(http.Handler) http.Handler
+	robotLimit func(http.Handler) http.Handler
+	userAuth   func(http.Handler) http.Handler
+	robotAuth  func(http.Handler) http.Handler
+	adminAuth  func(http.Handler) http.Handler
+	// 長く書き込み続けるエンドポイント用に書き込み期限を延ばす
+	streamTimeout func(http.Handler) http.Handler
+}
+
+func (s *Server) setupRoutes(rt routes) {
+	s.Router.Get("/api/openapi.json", openapi.Handler)
+	s.Router.With(rt.userLimit, openapi.ValidateBody[model.LoginRequest](openapi.LoginRequest)).Post("/api/login", rt.auth.Login)
+
+	// v1はv2と同じサービスを使い、レスポンスの形だけを従来のまま維持する
+	// 追跡リンクを共有できるようにログインを求めない
+	s.Router.With(rt.userLimit).Get("/api/track/{token}", rt.tracking.Track)
 
 	s.Router.Route("/api/v1", func(r chi.Router) {
-		r.Use(userAuthMW)
-		r.Post("/product", productHandler.List)
-		r.Post("/product/post", productHandler.CreateOrders)
-		r.Post("/orders", orderHandler.List)
-		r.Get("/image", productHandler.GetImage)
+		r.Use(middleware.Deprecated("/api/v2"))
+		r.Use(rt.userLimit)
+		r.Use(rt.userAuth)
+		rt.userAPIv1(r)
+	})
+
+	s.Router.Route("/api/v2", func(r chi.Router) {
+		r.Use(rt.userLimit)
+		r.Use(rt.userAuth)
+		rt.userAPIv2(r)
 	})
 
 	s.Router.Route("/api/robot", func(r chi.Router) {
-		r.Use(robotAuthMW)
-		r.Get("/delivery-plan", robotHandler.GetDeliveryPlan)
-		r.Patch("/orders/status", robotHandler.UpdateOrderStatus)
+		r.Use(rt.robotLimit)
+		r.Use(rt.robotAuth)
+		r.With(openapi.ValidateQuery(openapi.CapacityParam)).Get("/delivery-plan", rt.robot.GetDeliveryPlan)
+		r.With(openapi.ValidateBody[model.UpdateOrderStatusRequest](openapi.UpdateOrderStatusRequest)).Patch("/orders/status", rt.robot.UpdateOrderStatus)
+		r.With(openapi.ValidateBody[model.ReturnOrdersRequest](openapi.ReturnOrdersRequest)).Post("/orders/return", rt.robot.ReturnOrders)
+		r.With(openapi.ValidateBody[model.ReportLocationRequest](openapi.ReportLocationRequest)).Put("/location", rt.tracking.ReportLocation)
+		r.With(rt.streamTimeout, validateShippingStream).Get("/shipping-orders/stream", rt.robot.StreamShippingOrders)
 	})
+
+	// ADMIN_API_KEY が未設定の場合は管理用APIを公開しない
+	if rt.adminAuth != nil {
+		s.Router.Route("/api/admin", func(r chi.Router) {
+			r.Use(rt.adminAuth)
+			// pprof (/api/admin/debug/pprof/) と expvar (/api/admin/debug/vars)
+			r.With(rt.streamTimeout).Mount("/debug", chimw.Profiler())
+			r.Get("/flags", rt.flag.List)
+			r.Put("/flags/{name}", rt.flag.Update)
+			r.Post("/catalog/invalidate", rt.product.InvalidateCatalog)
+			r.Get("/warehouses", rt.warehouse.List)
+			r.Post("/warehouses", rt.warehouse.Create)
+			r.Put("/warehouses/{code}/stocks/{productID}", rt.warehouse.SetStock)
+			r.Get("/stocks/low", rt.inventory.LowStock)
+			r.With(rt.streamTimeout, validateShippingStream).Get("/shipping-orders/stream", rt.robot.StreamShippingOrders)
+			r.Get("/coupons", rt.coupon.List)
+			r.Post("/coupons", rt.coupon.Create)
+			r.Get("/dashboard", rt.dashboard.Summary)
+			r.With(openapi.ValidateQuery(openapi.ReportDaysParam)).Get("/reports/sla", rt.report.SLA)
+			r.With(openapi.ValidateQuery(openapi.GranularityParam, openapi.FromParam, openapi.ToParam)).Get("/analytics/orders", rt.analytics.Orders)
+		})
+	}
+}
+
+func (rt routes) userAPIv1(r chi.Router) {
+	r.With(validateList).Post("/product", rt.product.List)
+	r.With(validateCreateOrder).Post("/product/post", rt.product.CreateOrders)
+	r.With(validateList).Post("/orders", rt.order.List)
+	r.With(validateImage).Get("/image", rt.product.GetImage)
 }
 
-func (s *Server) Run() {
-	appPort := os.Getenv("PORT")
-	if appPort == "" {
-		appPort = "8080"
+// レスポンスの形を変える場合はここでv2用のハンドラに差し替える
+func (rt routes) userAPIv2(r chi.Router) {
+	r.With(validateList).Post("/product", rt.product.ListV2)
+	r.With(validateCreateOrder).Post("/product/post", rt.product.CreateOrders)
+	r.With(validateList).Post("/orders", rt.order.ListV2)
+	r.With(validateImage).Get("/image", rt.product.GetImage)
+	r.With(openapi.ValidateQuery(openapi.ProductIDsParam)).Get("/products", rt.product.GetBatch)
+	r.Get("/products/{productID}", rt.product.Get)
+	r.Get("/orders/{orderID}", rt.order.Get)
+	r.Get("/orders/{orderID}/tracking", rt.tracking.OrderTracking)
+	r.Get("/orders/{orderID}/receipt", rt.order.Receipt)
+	r.Method(http.MethodPost, "/graphql", rt.graphql)
+	r.Get("/me", rt.user.Me)
+	r.Get("/me/notifications", rt.user.NotificationPreferences)
+	r.With(openapi.ValidateBody[model.NotificationPreferences](openapi.NotificationPreferences)).Put("/me/notifications", rt.user.UpdateNotificationPreferences)
+	r.With(openapi.ValidateBody[model.UpdateTimezoneRequest](openapi.UpdateTimezoneRequest)).Put("/me/timezone", rt.user.UpdateTimezone)
+	r.Route("/cart", func(r chi.Router) {
+		r.Get("/", rt.cart.Get)
+		r.With(openapi.ValidateBody[model.AddCartItemRequest](openapi.AddCartItemRequest)).Post("/items", rt.cart.AddItem)
+		r.With(openapi.ValidateBody[model.UpdateCartItemRequest](openapi.UpdateCartItemRequest)).Put("/items/{productID}", rt.cart.UpdateItem)
+		r.Delete("/items/{productID}", rt.cart.RemoveItem)
+		r.With(openapi.ValidateQuery(openapi.CouponParam, openapi.NoteParam, openapi.AddressParam)).Post("/checkout", rt.cart.Checkout)
+	})
+	r.Route("/addresses", func(r chi.Router) {
+		validateAddress := openapi.ValidateBody[model.AddressRequest](openapi.AddressRequest)
+		r.Get("/", rt.address.List)
+		r.With(validateAddress).Post("/", rt.address.Create)
+		r.With(validateAddress).Put("/{addressID}", rt.address.Update)
+		r.Delete("/{addressID}", rt.address.Delete)
+		r.Put("/{addressID}/default", rt.address.SetDefault)
+	})
+}
+
+// 仕様(openapi.Spec)に合わせたリクエストの検証
+var (
+	validateList        = openapi.ValidateBody[model.ListRequest](openapi.ListRequest)
+	validateCreateOrder = openapi.ValidateBody[model.CreateOrderRequest](openapi.CreateOrderRequest)
+	validateImage       = openapi.ValidateQuery(openapi.ImagePathParam)
+	// ロボットと管理者の両方に公開する
+	validateShippingStream = openapi.ValidateQuery(openapi.AfterOrderParam, openapi.ZoneParam)
+)
+
+// SIGINT/SIGTERM を受けるまでサーバーを起動する
+// シグナル受信後は新規接続の受付を止め、処理中のリクエスト(トランザクションを含む)の完了を待ってから
+// バックグラウンド処理の停止・DBプールのクローズを行う
+func (s *Server) Run() error {
+	appPort := s.cfg.HTTP.Port
+	httpSrv := &http.Server{
+		Addr:              ":" + appPort,
+		Handler:           s.Router,
+		ReadHeaderTimeout: s.cfg.HTTP.ReadHeaderTimeout,
+		ReadTimeout:       s.cfg.HTTP.ReadTimeout,
+		WriteTimeout:      s.cfg.HTTP.WriteTimeout,
+		IdleTimeout:       s.cfg.HTTP.IdleTimeout,
+		MaxHeaderBytes:    s.cfg.HTTP.MaxHeaderBytes,
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	errCh := make(chan error, 1)
+	go func() {
+		slog.Info("Starting server", "port", appPort)
+		if err := httpSrv.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			slog.Error("Failed to start server", "error", err)
+			return errors.Join(err, s.shutdown(httpSrv))
+		}
+	case <-ctx.Done():
+		slog.Info("Shutdown signal received, draining in-flight requests", "timeout", s.cfg.HTTP.ShutdownTimeout.String())
 	}
+	return s.shutdown(httpSrv)
+}
+
+// バックグラウンド処理を起動する
+// ctx はサーバー停止時にキャンセルされ、Run は fn の終了を待ってからDBを閉じる
+func (s *Server) Go(fn func(ctx context.Context)) {
+	s.bgWG.Add(1)
+	go func() {
+		defer s.bgWG.Done()
+		fn(s.bgCtx)
+	}()
+}
+
+// 停止時に呼び出す処理を登録する (HTTPの停止後・DBのクローズ前に、登録と逆順で呼ばれる)
+func (s *Server) OnShutdown(fn func(ctx context.Context) error) {
+	s.shutdownHooks = append(s.shutdownHooks, fn)
+}
 
-	log.Printf("Starting server on :%s", appPort)
-	if err := http.ListenAndServe(":"+appPort, s.Router); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// リクエストの完了待ち・バックグラウンド処理の終了待ち・停止時の処理は、それぞれ別の時間内で行う
+// 前の段階で時間を使い切っても、残りの段階(注文の書き込みや分析データのフラッシュ)は打ち切られない
+func (s *Server) shutdown(httpSrv *http.Server) error {
+	var errs []error
+	httpCtx, cancelHTTP := context.WithTimeout(context.Background(), s.cfg.HTTP.ShutdownTimeout)
+	defer cancelHTTP()
+	if err := httpSrv.Shutdown(httpCtx); err != nil {
+		errs = append(errs, fmt.Errorf("http shutdown: %w", err))
 	}
+
+	s.bgCancel()
+	done := make(chan struct{})
+	go func() {
+		s.bgWG.Wait()
+		close(done)
+	}()
+	bgCtx, cancelBG := context.WithTimeout(context.Background(), s.cfg.HTTP.ShutdownHookTimeout)
+	defer cancelBG()
+	select {
+	case <-done:
+	case <-bgCtx.Done():
+		errs = append(errs, fmt.Errorf("background workers: %w", bgCtx.Err()))
+	}
+
+	hookCtx, cancelHooks := context.WithTimeout(context.Background(), s.cfg.HTTP.ShutdownHookTimeout)
+	defer cancelHooks()
+	for i := len(s.shutdownHooks) - 1; i >= 0; i-- {
+		if err := s.shutdownHooks[i](hookCtx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := s.closeDB(); err != nil {
+		errs = append(errs, fmt.Errorf("close db: %w", err))
+	}
+	slog.Info("Server stopped")
+	return errors.Join(errs...)
+}
+
+// DBの接続を閉じる (DEV_MODE では何もしない)
+func (s *Server) closeDB() error {
+	if s.db == nil {
+		return nil
+	}
+	return s.db.Close()
+}
+
+func runMigrations(dbConn *sqlx.DB, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	n, err := migration.Up(ctx, dbConn)
+	if err != nil {
+		return fmt.Errorf("failed to apply migrations: %w", err)
+	}
+	slog.Info("Migrations up to date", "applied", n)
+	return nil
+}
+
+// マイグレーションのみを実行して終了する (-migrate フラグ用)
+func Migrate(cfg *config.Config) error {
+	dbConn, err := db.InitDBConnection(cfg.DB)
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close()
+	return runMigrations(dbConn, cfg.Migrate.Timeout)
+}
+
+// 名前付きキャッシュ
+// Redisを使う場合はキーのプレフィックスになるため、運用ツールからも同じ名前で参照する
+const (
+	CacheSession      = "session"
+	CacheProductCount = "product_count"
+	CacheFeatureFlag  = "feature_flag"
+	CacheDashboard    = "dashboard"
+	CacheRecentOrder  = "recent_order"
+)
+
+var CacheNames = []string{CacheSession, CacheProductCount, CacheFeatureFlag, CacheDashboard, CacheRecentOrder}
+
+// キャッシュの生成元と、停止時に接続を閉じる関数を返す
+func NewCacheFactory(cfg config.CacheConfig) (*cache.Factory, func() error) {
+	if cache.Backend(cfg.Backend) != cache.BackendRedis {
+		return cache.NewFactory(cache.BackendMemory, cfg.MaxEntries, nil), func() error { return nil }
+	}
+	client := redis.NewClient(&redis.Options{
+		Addr:     cfg.RedisAddr,
+		Password: cfg.RedisPassword,
+		DB:       cfg.RedisDB,
+	})
+	slog.Info("Using redis cache backend", "addr", cfg.RedisAddr)
+	return cache.NewFactory(cache.BackendRedis, cfg.MaxEntries, client), client.Close
 }
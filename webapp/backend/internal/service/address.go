@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+// 注文時に指定された配送先がユーザーのものではない
+var ErrInvalidAddress = errors.New("invalid address")
+
+// ユーザーあたりの配送先の上限
+const MaxAddressesPerUser = 20
+
+var ErrTooManyAddresses = errors.New("too many addresses")
+
+type AddressService struct {
+	store *repository.Store
+}
+
+func NewAddressService(store *repository.Store) *AddressService {
+	return &AddressService{store: store}
+}
+
+func (s *AddressService) List(ctx context.Context, userID model.UserID) ([]model.Address, error) {
+	var addresses []model.Address
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		addresses, err = s.store.AddressRepo.List(ctx, userID)
+		return err
+	})
+	return addresses, err
+}
+
+// 配送先を作成する
+// 最初の配送先、または is_default を指定した場合は既定の配送先にする
+func (s *AddressService) Create(ctx context.Context, userID model.UserID, req model.AddressRequest) (model.Address, error) {
+	var created model.Address
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		n, err := txStore.AddressRepo.Count(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if n >= MaxAddressesPerUser {
+			return ErrTooManyAddresses
+		}
+		a := addressFromRequest(userID, req)
+		a.IsDefault = req.IsDefault || n == 0
+		id, err := txStore.AddressRepo.Create(ctx, a)
+		if err != nil {
+			return err
+		}
+		if a.IsDefault && n > 0 {
+			if err := txStore.AddressRepo.SetDefault(ctx, userID, id); err != nil {
+				return err
+			}
+		}
+		created, err = txStore.AddressRepo.Find(ctx, userID, id)
+		return err
+	})
+	return created, err
+}
+
+// 配送先を更新する。ユーザーの配送先でない場合は repository.ErrNotFound
+func (s *AddressService) Update(ctx context.Context, userID model.UserID, addressID int64, req model.AddressRequest) (model.Address, error) {
+	var updated model.Address
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if _, err := txStore.AddressRepo.Find(ctx, userID, addressID); err != nil {
+			return err
+		}
+		a := addressFromRequest(userID, req)
+		a.AddressID = addressID
+		if err := txStore.AddressRepo.Update(ctx, a); err != nil {
+			return err
+		}
+		var err error
+		updated, err = txStore.AddressRepo.Find(ctx, userID, addressID)
+		return err
+	})
+	return updated, err
+}
+
+// 配送先を削除する
+// 既定の配送先を削除した場合は、残りのうち最も古いものを既定にする
+func (s *AddressService) Delete(ctx context.Context, userID model.UserID, addressID int64) error {
+	return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		a, err := txStore.AddressRepo.Find(ctx, userID, addressID)
+		if err != nil {
+			return err
+		}
+		if err := txStore.AddressRepo.Delete(ctx, userID, addressID); err != nil {
+			return err
+		}
+		if !a.IsDefault {
+			return nil
+		}
+		rest, err := txStore.AddressRepo.List(ctx, userID)
+		if err != nil || len(rest) == 0 {
+			return err
+		}
+		return txStore.AddressRepo.SetDefault(ctx, userID, rest[0].AddressID)
+	})
+}
+
+// 既定の配送先を変更する
+func (s *AddressService) SetDefault(ctx context.Context, userID model.UserID, addressID int64) error {
+	return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if _, err := txStore.AddressRepo.Find(ctx, userID, addressID); err != nil {
+			return err
+		}
+		return txStore.AddressRepo.SetDefault(ctx, userID, addressID)
+	})
+}
+
+func addressFromRequest(userID model.UserID, req model.AddressRequest) model.Address {
+	return model.Address{
+		UserID:     userID,
+		Label:      req.Label,
+		Recipient:  req.Recipient,
+		PostalCode: req.PostalCode,
+		Prefecture: req.Prefecture,
+		City:       req.City,
+		Line1:      req.Line1,
+		Line2:      req.Line2,
+		Phone:      req.Phone,
+	}
+}
+
+// 注文の配送先を決める
+// addressID が0の場合はユーザーの既定の配送先 (未設定なら0)
+func resolveAddress(ctx context.Context, txStore *repository.Store, userID model.UserID, addressID int64) (int64, error) {
+	if addressID == 0 {
+		id, err := txStore.AddressRepo.FindDefaultID(ctx, userID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, nil
+		}
+		return id, err
+	}
+	if _, err := txStore.AddressRepo.Find(ctx, userID, addressID); err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, ErrInvalidAddress
+		}
+		return 0, err
+	}
+	return addressID, nil
+}
@@ -0,0 +1,75 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/event"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+// 運用ツールから使う操作
+type AdminService struct {
+	store  *repository.Store
+	events *event.Bus
+}
+
+func NewAdminService(store *repository.Store, events *event.Bus) *AdminService {
+	return &AdminService{store: store, events: events}
+}
+
+// 配送中(delivering)のまま olderThan 以上前に作成された注文を探す
+// ordersテーブルには配送開始日時がないため、作成日時で判定する
+func (s *AdminService) FindStuckOrders(ctx context.Context, olderThan time.Duration, limit int) ([]model.OrderID, error) {
+	return s.store.OrderRepo.FindIDsByStatus(ctx, "delivering", time.Now().Add(-olderThan), limit)
+}
+
+// 配送中の注文を配送待ち(shipping)に戻し、次の配送計画の対象にする
+// 既に配送中でなくなった注文はそのままにし、実際に戻した注文のIDを返す
+func (s *AdminService) RequeueOrders(ctx context.Context, orderIDs []model.OrderID) ([]model.OrderID, error) {
+	var requeued []model.OrderID
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			ids, err := txStore.OrderRepo.LockByStatus(ctx, orderIDs, "delivering")
+			if err != nil {
+				return err
+			}
+			if err := txStore.OrderRepo.UpdateStatusesChunked(ctx, ids, "shipping"); err != nil {
+				return err
+			}
+			for _, id := range ids {
+				if err := s.events.Publish(ctx, txStore, event.OrderStatusChanged{OrderID: id, NewStatus: "shipping"}); err != nil {
+					return err
+				}
+			}
+			requeued = ids
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("Requeued orders", "op", "RequeueOrders", "requested", len(orderIDs), "requeued", len(requeued))
+	return requeued, nil
+}
+
+// 商品総数を数え直してキャッシュを置き換える
+func (s *AdminService) RecomputeProductCount(ctx context.Context) (int, error) {
+	// 数え直すのは商品が直接変更された後のため、一覧のETagも無効にする
+	if _, err := s.store.ProductRepo.BumpCatalogVersion(ctx); err != nil {
+		return 0, err
+	}
+	s.store.ProductRepo.InvalidateCountCache(ctx)
+	if err := s.store.ProductRepo.WarmCountCache(ctx); err != nil {
+		return 0, err
+	}
+	return s.store.ProductRepo.CountProducts(ctx, model.ListRequest{})
+}
+
+// ステータスごとの注文数
+func (s *AdminService) CountOrdersByStatus(ctx context.Context) (map[string]int, error) {
+	return s.store.OrderRepo.CountByStatus(ctx)
+}
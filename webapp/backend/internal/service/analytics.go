@@ -0,0 +1,76 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+var ErrInvalidRange = errors.New("invalid range")
+
+const (
+	GranularityHour = "hour"
+	GranularityDay  = "day"
+)
+
+// 集計単位ごとの既定の期間と、指定できる最大の期間
+var granularityRanges = map[string]struct{ def, max time.Duration }{
+	GranularityHour: {def: 48 * time.Hour, max: 31 * 24 * time.Hour},
+	GranularityDay:  {def: 30 * 24 * time.Hour, max: 366 * 24 * time.Hour},
+}
+
+type AnalyticsService struct {
+	store *repository.Store
+}
+
+func NewAnalyticsService(store *repository.Store) *AnalyticsService {
+	return &AnalyticsService{store: store}
+}
+
+// [from, to) の注文数と配送完了数を granularity ごとに返す (UTC)
+// from, to がゼロ値の場合は現在までの既定の期間にする。件数のない期間も0件として含める
+func (s *AnalyticsService) OrderVolume(ctx context.Context, granularity string, from, to time.Time) ([]model.OrderVolume, error) {
+	r, ok := granularityRanges[granularity]
+	if !ok {
+		return nil, ErrInvalidRange
+	}
+	step := time.Hour
+	if granularity == GranularityDay {
+		step = 24 * time.Hour
+	}
+	if to.IsZero() {
+		to = time.Now().UTC().Truncate(step).Add(step)
+	}
+	if from.IsZero() {
+		from = to.Add(-r.def)
+	}
+	from, to = from.UTC().Truncate(step), to.UTC().Truncate(step)
+	if !from.Before(to) || to.Sub(from) > r.max {
+		return nil, ErrInvalidRange
+	}
+
+	var hourly []model.OrderVolume
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		hourly, err = s.store.AnalyticsRepo.Hourly(ctx, from, to)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := make([]model.OrderVolume, 0, to.Sub(from)/step)
+	for t := from; t.Before(to); t = t.Add(step) {
+		volumes = append(volumes, model.OrderVolume{Start: t})
+	}
+	for _, h := range hourly {
+		v := &volumes[h.Start.UTC().Sub(from)/step]
+		v.OrdersCreated += h.OrdersCreated
+		v.DeliveriesCompleted += h.DeliveriesCompleted
+	}
+	return volumes, nil
+}
@@ -2,11 +2,12 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"log"
+	"fmt"
 	"time"
 
+	"backend/internal/logging"
+	"backend/internal/metrics"
 	"backend/internal/repository"
 	"backend/internal/service/utils"
 
@@ -21,47 +22,73 @@ var (
 )
 
 type AuthService struct {
-	store *repository.Store
+	store      *repository.Store
+	sessionTTL time.Duration
 }
 
-func NewAuthService(store *repository.Store) *AuthService {
-	return &AuthService{store: store}
+func NewAuthService(store *repository.Store, sessionTTL time.Duration) *AuthService {
+	return &AuthService{store: store, sessionTTL: sessionTTL}
 }
 
 func (s *AuthService) Login(ctx context.Context, userName, password string) (string, time.Time, error) {
 	ctx, span := otel.Tracer("service.auth").Start(ctx, "AuthService.Login")
 	defer span.End()
+	logger := logging.FromContext(ctx).With("op", "AuthService.Login", "user_name", userName)
 
 	var sessionID string
 	var expiresAt time.Time
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
 		user, err := s.store.UserRepo.FindByUserName(ctx, userName)
 		if err != nil {
-			log.Printf("[Login] ユーザー検索失敗(userName: %s): %v", userName, err)
-			if errors.Is(err, sql.ErrNoRows) {
+			logger.Info("ユーザー検索失敗", "error", err)
+			if errors.Is(err, repository.ErrNotFound) {
 				return ErrUserNotFound
 			}
-			return ErrInternalServer
+			return fmt.Errorf("%w: %w", ErrInternalServer, err)
 		}
 
 		err = bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password))
 		if err != nil {
-			log.Printf("[Login] パスワード検証失敗: %v", err)
+			logger.Info("パスワード検証失敗", "error", err)
 			span.RecordError(err)
 			return ErrInvalidPassword
 		}
 
-		sessionDuration := 24 * time.Hour
-		sessionID, expiresAt, err = s.store.SessionRepo.Create(ctx, user.UserID, sessionDuration)
+		sessionID, expiresAt, err = s.store.SessionRepo.Create(ctx, user.UserID, s.sessionTTL)
 		if err != nil {
-			log.Printf("[Login] セッション生成失敗: %v", err)
-			return ErrInternalServer
+			logger.Error("セッション生成失敗", "error", err)
+			return fmt.Errorf("%w: %w", ErrInternalServer, err)
 		}
 		return nil
 	})
 	if err != nil {
 		return "", time.Time{}, err
 	}
-	log.Printf("Login successful for UserName '%s', session created.", userName)
+	logger.Info("Login successful, session created")
 	return sessionID, expiresAt, nil
 }
+
+// 1回のDELETEで削除するセッションの件数
+const sessionCleanupBatchSize = 1000
+
+// 期限切れのセッションを削除する
+// バックグラウンドジョブから定期的に呼び出す
+func (s *AuthService) PurgeExpiredSessions(ctx context.Context) error {
+	now := time.Now()
+	var total int64
+	for {
+		n, err := s.store.SessionRepo.DeleteExpired(ctx, now, sessionCleanupBatchSize)
+		if err != nil {
+			return err
+		}
+		total += n
+		metrics.SessionsPurged.Add(float64(n))
+		if n < sessionCleanupBatchSize {
+			break
+		}
+	}
+	if total > 0 {
+		logging.FromContext(ctx).Info("Purged expired sessions", "op", "PurgeExpiredSessions", "sessions", total)
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+package service
+
+import (
+	"context"
+	"errors"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+var (
+	ErrCartEmpty           = errors.New("cart is empty")
+	ErrInvalidCartQuantity = errors.New("invalid cart quantity")
+)
+
+// カート内の1商品あたりの数量の上限
+const MaxCartItemQuantity = 100
+
+type CartService struct {
+	store    *repository.Store
+	products *ProductService
+}
+
+func NewCartService(store *repository.Store, products *ProductService) *CartService {
+	return &CartService{store: store, products: products}
+}
+
+func (s *CartService) Get(ctx context.Context, userID model.UserID) (model.Cart, error) {
+	var items []model.CartItem
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		items, err = s.store.CartRepo.List(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return model.Cart{}, err
+	}
+	return newCart(items), nil
+}
+
+// 商品をカートに追加する。すでにカートにある場合は数量を加算する
+func (s *CartService) AddItem(ctx context.Context, userID model.UserID, productID model.ProductID, quantity int) (model.Cart, error) {
+	if quantity < 1 || quantity > MaxCartItemQuantity {
+		return model.Cart{}, ErrInvalidCartQuantity
+	}
+	var items []model.CartItem
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if err := txStore.CartRepo.Add(ctx, userID, productID, quantity); err != nil {
+			return err
+		}
+		var err error
+		items, err = txStore.CartRepo.List(ctx, userID)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if item.ProductID == productID && item.Quantity > MaxCartItemQuantity {
+				return ErrInvalidCartQuantity
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return model.Cart{}, err
+	}
+	return newCart(items), nil
+}
+
+// カート内の商品の数量を変更する。0を指定した場合はカートから削除する
+func (s *CartService) UpdateItem(ctx context.Context, userID model.UserID, productID model.ProductID, quantity int) (model.Cart, error) {
+	if quantity < 0 || quantity > MaxCartItemQuantity {
+		return model.Cart{}, ErrInvalidCartQuantity
+	}
+	if quantity == 0 {
+		return s.RemoveItem(ctx, userID, productID)
+	}
+	var items []model.CartItem
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if err := txStore.CartRepo.SetQuantity(ctx, userID, productID, quantity); err != nil {
+			return err
+		}
+		var err error
+		items, err = txStore.CartRepo.List(ctx, userID)
+		return err
+	})
+	if err != nil {
+		return model.Cart{}, err
+	}
+	return newCart(items), nil
+}
+
+func (s *CartService) RemoveItem(ctx context.Context, userID model.UserID, productID model.ProductID) (model.Cart, error) {
+	if err := s.store.CartRepo.Remove(ctx, userID, productID); err != nil {
+		return model.Cart{}, err
+	}
+	return s.Get(ctx, userID)
+}
+
+// カートの中身を注文に変換し、カートを空にする
+// 注文の作成とカートの削除は同じトランザクションで行うため、決済の失敗などで注文を作成できなかった場合はカートが残る
+func (s *CartService) Checkout(ctx context.Context, userID model.UserID, opts model.OrderOptions) ([]model.OrderID, error) {
+	var orderIDs []model.OrderID
+	// 注文の作成はこのトランザクション内のSAVEPOINTで行われるため、与信はここで管理する
+	// (コミットに失敗した場合や、デッドロックでやり直す場合に取り消す)
+	var authorizationID string
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		if authorizationID != "" {
+			s.products.voidPayment(ctx, authorizationID)
+			authorizationID = ""
+		}
+		items, err := txStore.CartRepo.ListForUpdate(ctx, userID)
+		if err != nil {
+			return err
+		}
+		if len(items) == 0 {
+			return ErrCartEmpty
+		}
+		requestItems := make([]model.RequestItem, len(items))
+		for i, item := range items {
+			requestItems[i] = model.RequestItem{ProductID: item.ProductID, Quantity: item.Quantity}
+		}
+		// 決済を伴う注文の作成をトランザクション内の最後の処理にする
+		// (注文の作成後に失敗して決済だけが残ることがないように)
+		if err := txStore.CartRepo.Clear(ctx, userID); err != nil {
+			return err
+		}
+		orderIDs, err = s.products.createOrdersIn(ctx, txStore, userID, requestItems, opts, &authorizationID)
+		return err
+	})
+	if err != nil {
+		if authorizationID != "" {
+			s.products.voidPayment(ctx, authorizationID)
+		}
+		return nil, err
+	}
+	logging.FromContext(ctx).Info("Checked out cart", "op", "Checkout", "orders", len(orderIDs))
+	return orderIDs, nil
+}
+
+func newCart(items []model.CartItem) model.Cart {
+	cart := model.Cart{Items: items}
+	for _, item := range items {
+		cart.TotalValue += item.Value * item.Quantity
+		cart.TotalWeight += item.Weight * item.Quantity
+	}
+	return cart
+}
@@ -0,0 +1,129 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"strings"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var ErrInvalidCoupon = errors.New("invalid coupon")
+
+var couponCodePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9_-]{0,31}$`)
+
+// クーポンが使えない理由
+type CouponError struct {
+	Reason string
+}
+
+func (e *CouponError) Error() string {
+	return "coupon not applicable: " + e.Reason
+}
+
+type CouponService struct {
+	store *repository.Store
+}
+
+func NewCouponService(store *repository.Store) *CouponService {
+	return &CouponService{store: store}
+}
+
+func (s *CouponService) List(ctx context.Context) ([]model.Coupon, error) {
+	return s.store.CouponRepo.List(ctx)
+}
+
+func (s *CouponService) Create(ctx context.Context, req model.CreateCouponRequest) (model.Coupon, error) {
+	c := model.Coupon{
+		Code:           strings.ToUpper(req.Code),
+		DiscountType:   req.DiscountType,
+		Value:          req.Value,
+		ExpiresAt:      req.ExpiresAt,
+		MaxRedemptions: req.MaxRedemptions,
+		MaxPerUser:     req.MaxPerUser,
+		CreatedAt:      time.Now().UTC(),
+	}
+	if !couponCodePattern.MatchString(c.Code) || c.Value <= 0 || c.MaxRedemptions < 0 || c.MaxPerUser < 0 {
+		return model.Coupon{}, ErrInvalidCoupon
+	}
+	switch c.DiscountType {
+	case model.CouponPercent:
+		if c.Value > 100 {
+			return model.Coupon{}, ErrInvalidCoupon
+		}
+	case model.CouponFixed:
+	default:
+		return model.Coupon{}, ErrInvalidCoupon
+	}
+	if c.ExpiresAt != nil {
+		t := c.ExpiresAt.UTC()
+		c.ExpiresAt = &t
+	}
+	if err := s.store.CouponRepo.Create(ctx, c); err != nil {
+		return model.Coupon{}, err
+	}
+	logging.FromContext(ctx).Info("Coupon created", "op", "CreateCoupon", "code", c.Code)
+	return c, nil
+}
+
+// クーポンの有効性を確認し、amount に対する割引額を返す
+// クーポンの行をロックするため、使用の記録(CouponRepo.Redeem)まで同じトランザクション内で行うこと
+func applyCoupon(ctx context.Context, txStore *repository.Store, code string, userID model.UserID, amount int) (int, error) {
+	c, err := txStore.CouponRepo.Lock(ctx, strings.ToUpper(code))
+	if err != nil {
+		if errors.Is(err, repository.ErrNotFound) {
+			return 0, &CouponError{Reason: "unknown coupon code"}
+		}
+		return 0, err
+	}
+	if c.ExpiresAt != nil && !time.Now().Before(*c.ExpiresAt) {
+		return 0, &CouponError{Reason: "coupon has expired"}
+	}
+	if c.MaxRedemptions > 0 && c.RedeemedCount >= c.MaxRedemptions {
+		return 0, &CouponError{Reason: "coupon has been fully redeemed"}
+	}
+	if c.MaxPerUser > 0 {
+		used, err := txStore.CouponRepo.CountRedemptions(ctx, c.Code, userID)
+		if err != nil {
+			return 0, err
+		}
+		if used >= c.MaxPerUser {
+			return 0, &CouponError{Reason: "coupon usage limit reached for this user"}
+		}
+	}
+
+	discount := c.Value
+	if c.DiscountType == model.CouponPercent {
+		discount = amount * c.Value / 100
+	}
+	return min(discount, amount), nil
+}
+
+// 割引額を注文ごとに価格の比率で割り振る
+// 端数は先頭の注文から1ずつ割り当て、どの注文も価格を超えて割り引かない
+func allocateDiscount(prices []int, discount int) []int {
+	shares := make([]int, len(prices))
+	total := 0
+	for _, p := range prices {
+		total += p
+	}
+	if total == 0 || discount == 0 {
+		return shares
+	}
+	rest := discount
+	for i, p := range prices {
+		shares[i] = discount * p / total
+		rest -= shares[i]
+	}
+	for i := 0; rest > 0; i = (i + 1) % len(prices) {
+		if shares[i] < prices[i] {
+			shares[i]++
+			rest--
+		}
+	}
+	return shares
+}
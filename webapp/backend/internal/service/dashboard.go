@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+// この時間内に配送計画の取得か位置の報告があったロボットを稼働中とみなす
+const activeRobotWindow = 15 * time.Minute
+
+type DashboardService struct {
+	store *repository.Store
+	// 集計は重いため、短い時間だけ結果を使い回す
+	cache cache.Cache[model.DashboardSummary]
+	ttl   time.Duration
+}
+
+func NewDashboardService(store *repository.Store, c cache.Cache[model.DashboardSummary], ttl time.Duration) *DashboardService {
+	return &DashboardService{store: store, cache: c, ttl: ttl}
+}
+
+const dashboardCacheKey = "summary"
+
+func (s *DashboardService) Summary(ctx context.Context) (model.DashboardSummary, error) {
+	if summary, ok := s.cache.Get(ctx, dashboardCacheKey); ok {
+		return summary, nil
+	}
+
+	now := time.Now().UTC()
+	dayAgo := now.Add(-24 * time.Hour)
+	summary := model.DashboardSummary{GeneratedAt: now}
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		if summary.OrdersByStatus, err = s.store.OrderRepo.CountByStatus(ctx); err != nil {
+			return err
+		}
+		if summary.CreatedLast24h, err = s.store.StatsRepo.CountCreatedSince(ctx, dayAgo); err != nil {
+			return err
+		}
+		if summary.CompletedLast24h, summary.AvgDeliverySeconds, err = s.store.StatsRepo.DeliveredSince(ctx, dayAgo); err != nil {
+			return err
+		}
+		if summary.ReturnsLast24h, err = s.store.StatsRepo.ReturnsSince(ctx, dayAgo); err != nil {
+			return err
+		}
+		summary.ActiveRobots, err = s.store.StatsRepo.CountActiveRobots(ctx, now.Add(-activeRobotWindow))
+		return err
+	})
+	if err != nil {
+		return model.DashboardSummary{}, err
+	}
+
+	s.cache.Set(ctx, dashboardCacheKey, summary, s.ttl)
+	return summary, nil
+}
@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+
+	"backend/internal/event"
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+// 1回のチェックで新たにアラートを出す最大件数
+const lowStockBatchSize = 500
+
+// 在庫の不足を検知する
+type InventoryService struct {
+	store  *repository.Store
+	events *event.Bus
+	// 在庫がこの数を下回ったらアラートを出す
+	threshold int
+}
+
+func NewInventoryService(store *repository.Store, events *event.Bus, threshold int) *InventoryService {
+	return &InventoryService{store: store, events: events, threshold: threshold}
+}
+
+// 在庫が閾値を下回った商品ごとに StockLow を発行する
+// 同じ在庫については閾値以上に戻るまで再度発行しない。スケジューラから定期的に呼び出す
+func (s *InventoryService) CheckLowStock(ctx context.Context) error {
+	return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		resolved, err := txStore.WarehouseRepo.ResolveLowStockAlerts(ctx, s.threshold)
+		if err != nil {
+			return err
+		}
+		stocks, err := txStore.WarehouseRepo.FindUnalertedLowStocks(ctx, s.threshold, lowStockBatchSize)
+		if err != nil {
+			return err
+		}
+		var raised int
+		for _, stock := range stocks {
+			marked, err := txStore.WarehouseRepo.MarkLowStockAlerted(ctx, stock)
+			if err != nil {
+				return err
+			}
+			if !marked {
+				continue
+			}
+			err = s.events.Publish(ctx, txStore, event.StockLow{
+				ProductID:   stock.ProductID,
+				WarehouseID: stock.WarehouseID,
+				Quantity:    stock.Quantity,
+				Threshold:   s.threshold,
+			})
+			if err != nil {
+				return err
+			}
+			raised++
+		}
+		if raised > 0 || resolved > 0 {
+			logging.FromContext(ctx).Warn("Low stock alerts updated",
+				"op", "CheckLowStock", "raised", raised, "resolved", resolved, "threshold", s.threshold)
+		}
+		return nil
+	})
+}
+
+// 現在、在庫が閾値を下回っている商品
+func (s *InventoryService) ListLowStock(ctx context.Context) ([]model.LowStock, error) {
+	var stocks []model.LowStock
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		stocks, err = s.store.WarehouseRepo.ListLowStocks(ctx, s.threshold)
+		return err
+	})
+	return stocks, err
+}
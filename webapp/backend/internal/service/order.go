@@ -16,7 +16,7 @@ func NewOrderService(store *repository.Store) *OrderService {
 }
 
 // ユーザーの注文履歴を取得
-func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.ListRequest) ([]model.Order, int, error) {
+func (s *OrderService) FetchOrders(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Order, int, error) {
 	var orders []model.Order
 	var total int
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
@@ -32,3 +32,36 @@ func (s *OrderService) FetchOrders(ctx context.Context, userID int, req model.Li
 	}
 	return orders, total, nil
 }
+
+// ユーザー自身の注文を1件取得する
+func (s *OrderService) GetOrder(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Order, error) {
+	var order model.Order
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		order, err = s.store.OrderRepo.FindByID(ctx, userID, orderID)
+		return err
+	})
+	return order, err
+}
+
+// ユーザー自身の注文の領収書
+func (s *OrderService) GetReceipt(ctx context.Context, userID model.UserID, orderID model.OrderID) (model.Receipt, error) {
+	var receipt model.Receipt
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		receipt, err = s.store.OrderRepo.FindReceipt(ctx, userID, orderID)
+		return err
+	})
+	return receipt, err
+}
+
+// ユーザーの注文のステータスごとの件数
+func (s *OrderService) CountByStatus(ctx context.Context, userID model.UserID) (map[string]int, error) {
+	var counts map[string]int
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		counts, err = s.store.OrderRepo.CountByStatusForUser(ctx, userID)
+		return err
+	})
+	return counts, err
+}
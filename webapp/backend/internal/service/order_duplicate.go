@@ -0,0 +1,92 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"backend/internal/cache"
+	"backend/internal/logging"
+	"backend/internal/metrics"
+	"backend/internal/model"
+)
+
+// 重複した注文の扱い
+const (
+	// 作成せずに *DuplicateOrderError を返す
+	DuplicateReject = "reject"
+	// 作成し、重複の疑いがあることを返す
+	DuplicateFlag = "flag"
+)
+
+// 直前に同じ内容の注文があったため作成しなかった
+type DuplicateOrderError struct {
+	// 直前の注文のID (作成中の場合は空)
+	OrderIDs []model.OrderID
+}
+
+func (e *DuplicateOrderError) Error() string {
+	return "duplicate order"
+}
+
+// 同じユーザーから短い間隔で同じ内容の注文が来た場合に、二重送信とみなす
+// 判定はキャッシュに残した直前の注文で行う (リクエストの再送を前提とした冪等キーとは別の仕組み)
+type duplicateDetector struct {
+	// ユーザーと注文内容ごとの直前の注文ID (作成中は空)
+	recent cache.Cache[[]model.OrderID]
+	window time.Duration
+	action string
+}
+
+// 二重送信された注文を検出する
+// window の間に同じユーザーから同じ商品・数量の注文が来た場合、action に応じて拒否するか重複の疑いを返す
+func (s *ProductService) DetectDuplicateOrders(recent cache.Cache[[]model.OrderID], window time.Duration, action string) {
+	s.duplicates = &duplicateDetector{recent: recent, window: window, action: action}
+}
+
+// ユーザーと注文内容 (商品ごとの数量。並び順は問わない) から決まるキー
+func duplicateKey(userID model.UserID, items []model.RequestItem) string {
+	quantities := make(map[model.ProductID]int, len(items))
+	for _, item := range items {
+		if item.Quantity > 0 {
+			quantities[item.ProductID] += item.Quantity
+		}
+	}
+	productIDs := make([]model.ProductID, 0, len(quantities))
+	for id := range quantities {
+		productIDs = append(productIDs, id)
+	}
+	sort.Slice(productIDs, func(i, j int) bool { return productIDs[i] < productIDs[j] })
+
+	var b strings.Builder
+	b.WriteString(userID.String())
+	for _, id := range productIDs {
+		fmt.Fprintf(&b, ":%d*%d", id, quantities[id])
+	}
+	return b.String()
+}
+
+// 直前に同じ注文があるか調べ、なければ作成中として記録する
+// 重複の場合は直前の注文ID (作成中であれば空) と true を返す
+func (d *duplicateDetector) check(ctx context.Context, key string) ([]model.OrderID, bool) {
+	// 同時に届いた同じ注文も重複とみなすため、作成を始める前にアトミックに記録する
+	if d.recent.Add(ctx, key, []model.OrderID{}, d.window) {
+		return nil, false
+	}
+	// 確認の後に記録が消えていた場合も、作成中の注文があったものとして扱う
+	prev, _ := d.recent.Get(ctx, key)
+	metrics.DuplicateOrders.WithLabelValues(d.action).Inc()
+	logging.FromContext(ctx).Warn("Duplicate order detected", "op", "CreateOrders", "action", d.action, "previous_order_ids", prev)
+	return prev, true
+}
+
+// 作成の結果を記録する。失敗した場合は再度注文できるよう記録を消す
+func (d *duplicateDetector) record(ctx context.Context, key string, orderIDs []model.OrderID, err error) {
+	if err != nil {
+		d.recent.Delete(ctx, key)
+		return
+	}
+	d.recent.Set(ctx, key, orderIDs, d.window)
+}
@@ -0,0 +1,255 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/metrics"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+// バッファが一杯、または Run が動いていないため受け付けられなかった
+var errOrderBufferFull = errors.New("order buffer is full")
+
+// 1回のINSERTで作成する注文の最大数
+const maxOrdersPerInsert = 1000
+
+type OrderWriterConfig struct {
+	// 溜めておける注文リクエストの数。一杯の場合はその場で注文を作成する
+	BufferSize int
+	// 1つのトランザクションにまとめる注文リクエストの最大数
+	MaxBatch int
+	// 最初のリクエストを受けてから、後続を待ってまとめる最大の時間
+	FlushInterval time.Duration
+	// まとめた書き込み1回にかける最大の時間。失敗して1件ずつ作成し直す場合は1件ごとにこの時間まで待つ
+	Timeout time.Duration
+}
+
+// 注文の作成を溜めてまとめて書き込む (write-behind)
+// 複数のリクエストの注文を1つのトランザクションで作成し、注文はリクエストをまたいでまとめてバルクINSERTする
+// 呼び出し元にはコミット後に注文IDを返すため、注文が失われることはない
+// リクエストごとの在庫の引き当て・クーポン・与信はSAVEPOINT内で行い、失敗してもそのリクエストだけを取り消す
+// 決済の確定はまとめた書き込みのコミット後に行うため、ロールバックして作成し直しても二重に確定されない
+type OrderWriter struct {
+	products *ProductService
+	cfg      OrderWriterConfig
+	queue    chan *orderJob
+
+	// Run が動いている間だけ受け付ける
+	mu      sync.RWMutex
+	running bool
+}
+
+// 溜めている注文リクエスト
+type orderJob struct {
+	ctx    context.Context
+	userID model.UserID
+	items  []model.RequestItem
+	opts   model.OrderOptions
+	// 結果を1回だけ送る
+	done chan orderResult
+}
+
+type orderResult struct {
+	orderIDs []model.OrderID
+	err      error
+}
+
+// 注文の作成に OrderWriter を使うようにする
+// 書き込みは Run を起動している間だけ行う
+func (s *ProductService) NewOrderWriter(cfg OrderWriterConfig) *OrderWriter {
+	w := &OrderWriter{
+		products: s,
+		cfg:      cfg,
+		queue:    make(chan *orderJob, cfg.BufferSize),
+	}
+	s.writer = w
+	return w
+}
+
+// 注文リクエストを溜め、書き込みがコミットされるのを待つ
+// 溜められない場合は errOrderBufferFull を返す
+// ctx がキャンセルされた場合は結果を待たずに ctx のエラーを返す (書き込み中だった注文は作成されることがある)
+func (w *OrderWriter) Submit(ctx context.Context, userID model.UserID, items []model.RequestItem, opts model.OrderOptions) ([]model.OrderID, error) {
+	job := &orderJob{ctx: ctx, userID: userID, items: items, opts: opts, done: make(chan orderResult, 1)}
+
+	w.mu.RLock()
+	accepted := false
+	if w.running {
+		select {
+		case w.queue <- job:
+			accepted = true
+		default:
+		}
+	}
+	w.mu.RUnlock()
+	if !accepted {
+		return nil, errOrderBufferFull
+	}
+
+	select {
+	case res := <-job.done:
+		return res.orderIDs, res.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// 溜まった注文リクエストを書き込む。ctx がキャンセルされたら受け付けを止め、残っているものを書き込んでから終了する
+func (w *OrderWriter) Run(ctx context.Context) {
+	w.mu.Lock()
+	w.running = true
+	w.mu.Unlock()
+	for {
+		select {
+		case job := <-w.queue:
+			w.flush(w.collect(job))
+		case <-ctx.Done():
+			w.mu.Lock()
+			w.running = false
+			w.mu.Unlock()
+			for {
+				select {
+				case job := <-w.queue:
+					w.flush(w.collect(job))
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+// first に続けて、MaxBatch 件か FlushInterval が経つまで注文リクエストを集める
+func (w *OrderWriter) collect(first *orderJob) []*orderJob {
+	jobs := []*orderJob{first}
+	timer := time.NewTimer(w.cfg.FlushInterval)
+	defer timer.Stop()
+	for len(jobs) < w.cfg.MaxBatch {
+		select {
+		case job := <-w.queue:
+			jobs = append(jobs, job)
+		case <-timer.C:
+			return jobs
+		}
+	}
+	return jobs
+}
+
+// jobs を1つのトランザクションで書き込み、それぞれに結果を返す
+// まとめた書き込み自体が失敗した場合は、1件ずつ通常の方法で作成し直す
+func (w *OrderWriter) flush(jobs []*orderJob) {
+	s := w.products
+	// 待っている間にキャンセルされたリクエストは作成しない
+	pending := make([]*orderJob, 0, len(jobs))
+	for _, job := range jobs {
+		if err := job.ctx.Err(); err != nil {
+			job.done <- orderResult{err: err}
+			continue
+		}
+		pending = append(pending, job)
+	}
+	if len(pending) == 0 {
+		return
+	}
+	metrics.OrderWriteBatchSize.Observe(float64(len(pending)))
+
+	// 呼び出し元のキャンセルでは止めず、Timeout で打ち切る (リクエストの値は引き継ぐ)
+	deadline := time.Now().Add(w.cfg.Timeout)
+	ctx, cancel := context.WithDeadline(context.Background(), deadline)
+	defer cancel()
+	jobCtxs := make([]context.Context, len(pending))
+	for i, job := range pending {
+		var cancelJob context.CancelFunc
+		jobCtxs[i], cancelJob = detach(job.ctx, deadline)
+		defer cancelJob()
+	}
+
+	prepared := make([]preparedOrders, len(pending))
+	failed := make([]error, len(pending))
+	created := make([][]model.OrderID, len(pending))
+
+	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+		// デッドロックなどでトランザクションをやり直す場合は、前回の与信を取り消して最初から組み立て直す
+		for i := range pending {
+			if prepared[i].authorizationID != "" {
+				s.voidPayment(jobCtxs[i], prepared[i].authorizationID)
+			}
+			prepared[i], failed[i], created[i] = preparedOrders{}, nil, nil
+		}
+
+		var orders []model.Order
+		for i, job := range pending {
+			ctx := jobCtxs[i]
+			err := txStore.ExecTx(ctx, func(spStore *repository.Store) error {
+				var err error
+				prepared[i], err = s.prepareOrders(ctx, spStore, job.userID, job.items, job.opts)
+				return err
+			})
+			if err != nil {
+				// トランザクション自体が失われた場合は、後続をトランザクション外で実行しないよう全体を失敗させる
+				if repository.TxAborted(err) {
+					return err
+				}
+				failed[i] = err
+				continue
+			}
+			orders = append(orders, prepared[i].orders...)
+		}
+
+		// バルクINSERTで一括作成 (プレースホルダの上限を超えないよう分ける)
+		orderIDs := make([]model.OrderID, 0, len(orders))
+		for start := 0; start < len(orders); start += maxOrdersPerInsert {
+			ids, err := txStore.OrderRepo.BulkCreate(ctx, orders[start:min(start+maxOrdersPerInsert, len(orders))])
+			if err != nil {
+				return err
+			}
+			orderIDs = append(orderIDs, ids...)
+		}
+		for i := range pending {
+			if failed[i] != nil || len(prepared[i].orders) == 0 {
+				continue
+			}
+			created[i], orderIDs = orderIDs[:len(prepared[i].orders)], orderIDs[len(prepared[i].orders):]
+			if err := s.completeOrders(jobCtxs[i], txStore, prepared[i], created[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	for i, job := range pending {
+		if prepared[i].authorizationID != "" && (err != nil || failed[i] != nil) {
+			s.voidPayment(job.ctx, prepared[i].authorizationID)
+		}
+	}
+	if err != nil {
+		logging.FromContext(pending[0].ctx).Warn("Batched order write failed, creating orders one by one", "op", "CreateOrders", "requests", len(pending), "error", err)
+		metrics.OrderWriteFallbacks.WithLabelValues("batch_failed").Add(float64(len(pending)))
+		for _, job := range pending {
+			ctx, cancel := detach(job.ctx, time.Now().Add(w.cfg.Timeout))
+			orderIDs, err := s.CreateOrdersIn(ctx, s.store, job.userID, job.items, job.opts)
+			cancel()
+			job.done <- orderResult{orderIDs: orderIDs, err: err}
+		}
+		return
+	}
+
+	for i, job := range pending {
+		if failed[i] != nil {
+			job.done <- orderResult{err: failed[i]}
+			continue
+		}
+		logging.FromContext(job.ctx).Info("Created orders", "op", "CreateOrders", "orders", len(created[i]), "batched_requests", len(pending))
+		job.done <- orderResult{orderIDs: created[i]}
+	}
+}
+
+// job の値 (ロガーなど) を引き継ぎ、呼び出し元のキャンセルでは止まらずに deadline で打ち切られるコンテキスト
+func detach(job context.Context, deadline time.Time) (context.Context, context.CancelFunc) {
+	return context.WithDeadline(context.WithoutCancel(job), deadline)
+}
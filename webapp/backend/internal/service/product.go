@@ -2,56 +2,392 @@ package service
 
 import (
 	"context"
-	"log"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"strings"
 
+	"backend/internal/event"
+	"backend/internal/featureflag"
+	"backend/internal/logging"
+	"backend/internal/metrics"
 	"backend/internal/model"
+	"backend/internal/payment"
 	"backend/internal/repository"
 )
 
 type ProductService struct {
-	store *repository.Store
+	store    *repository.Store
+	flags    *featureflag.Flags
+	events   *event.Bus
+	payments payment.Provider
+	// nil でなければ注文の作成をまとめて書き込む
+	writer *OrderWriter
+	// nil でなければ二重送信された注文を検出する
+	duplicates *duplicateDetector
 }
 
-func NewProductService(store *repository.Store) *ProductService {
-	return &ProductService{store: store}
+func NewProductService(store *repository.Store, flags *featureflag.Flags, events *event.Bus, payments payment.Provider) *ProductService {
+	return &ProductService{store: store, flags: flags, events: events, payments: payments}
 }
 
-func (s *ProductService) CreateOrders(ctx context.Context, userID int, items []model.RequestItem) ([]string, error) {
-	var insertedOrderIDs []string
+// 商品データを直接変更した後に呼び出し、一覧のETagと総数のキャッシュを無効にする
+// バージョンはDBにあるため、どのサーバーから呼び出しても全てのサーバーのETagが変わる
+// 商品の追加(ProductRepo.BulkCreate)ではリポジトリがバージョンを進める
+func (s *ProductService) BumpCatalogVersion(ctx context.Context) (int64, error) {
+	v, err := s.store.ProductRepo.BumpCatalogVersion(ctx)
+	if err != nil {
+		return 0, err
+	}
+	s.store.ProductRepo.InvalidateCountCache(ctx)
+	return v, nil
+}
 
-	err := s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-		// 注文リストを構築
-		var ordersToInsert []model.Order
-		for _, item := range items {
-			for i := 0; i < item.Quantity; i++ {
-				ordersToInsert = append(ordersToInsert, model.Order{
-					UserID:    userID,
-					ProductID: item.ProductID,
-				})
-			}
+// 商品一覧のETag
+// カタログのバージョンと検索条件から決まるため、一覧を取得せずに304を返せる
+// バージョンを取得できない場合は空文字列を返す (ETagを付けない)
+func (s *ProductService) ListETag(ctx context.Context, req model.ListRequest) string {
+	version, err := s.store.ProductRepo.CatalogVersion(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Failed to get catalog version", "error", err)
+		return ""
+	}
+	h := fnv.New64a()
+	fmt.Fprintf(h, "%s\x00%s\x00%d\x00%d\x00%s\x00%s\x00%t", req.Search, req.Type, req.Offset, req.PageSize, req.SortField, req.SortOrder, req.Exact)
+	return fmt.Sprintf(`W/"%x-%x"`, version, h.Sum64())
+}
+
+// 注文を作成する
+// 二重送信の検出を有効にしている場合、直前に同じ注文があれば拒否する (*DuplicateOrderError) か、
+// 作成した上で duplicate に true を返す
+func (s *ProductService) CreateOrders(ctx context.Context, userID model.UserID, items []model.RequestItem, opts model.OrderOptions) (orderIDs []model.OrderID, duplicate bool, err error) {
+	if s.duplicates == nil {
+		orderIDs, err = s.createOrders(ctx, userID, items, opts)
+		return orderIDs, false, err
+	}
+
+	key := duplicateKey(userID, items)
+	prev, duplicate := s.duplicates.check(ctx, key)
+	if duplicate && s.duplicates.action == DuplicateReject {
+		return nil, true, &DuplicateOrderError{OrderIDs: prev}
+	}
+	orderIDs, err = s.createOrders(ctx, userID, items, opts)
+	// 重複として作成した注文が失敗しても、直前の注文の記録は残す
+	if err == nil || !duplicate {
+		s.duplicates.record(ctx, key, orderIDs, err)
+	}
+	return orderIDs, duplicate, err
+}
+
+// OrderWriter を使う場合は他のリクエストとまとめて書き込み、溜められなければその場で作成する
+func (s *ProductService) createOrders(ctx context.Context, userID model.UserID, items []model.RequestItem, opts model.OrderOptions) ([]model.OrderID, error) {
+	// まとめて書き込むかはユーザーごとに段階的に切り替えられる
+	if s.writer != nil && s.flags.Enabled(ctx, featureflag.OrderWriteBehind, userID.String()) {
+		orderIDs, err := s.writer.Submit(ctx, userID, items, opts)
+		if !errors.Is(err, errOrderBufferFull) {
+			return orderIDs, err
 		}
+		metrics.OrderWriteFallbacks.WithLabelValues("buffer_full").Inc()
+	}
+	return s.CreateOrdersIn(ctx, s.store, userID, items, opts)
+}
 
-		if len(ordersToInsert) == 0 {
-			return nil
+// store のトランザクション内で注文を作成する
+// 呼び出し元のトランザクション内のStoreを渡すと、注文の作成もそのトランザクションに含まれる
+// (その場合、外側のトランザクションが失敗しても与信は取り消されないため、与信を管理する呼び出し元は createOrdersIn を使う)
+// 決済の与信が取れない場合は注文を作成せず、payment.ErrDeclined を返す
+// クーポンが使えない場合は *CouponError、配送先がユーザーのものでない場合は ErrInvalidAddress、在庫が足りない場合は *OutOfStockError を返す
+func (s *ProductService) CreateOrdersIn(ctx context.Context, store *repository.Store, userID model.UserID, items []model.RequestItem, opts model.OrderOptions) ([]model.OrderID, error) {
+	// 与信を取った後に失敗した場合に取り消すための与信ID
+	var authorizationID string
+	orderIDs, err := s.createOrdersIn(ctx, store, userID, items, opts, &authorizationID)
+	if err != nil {
+		if authorizationID != "" {
+			s.voidPayment(ctx, authorizationID)
+		}
+		return nil, err
+	}
+	return orderIDs, nil
+}
+
+// CreateOrdersIn の本体。取った与信のIDを authorizationID に入れ、失敗した場合の取り消しは呼び出し元が行う
+// 呼び出し元のトランザクションがコミットされずに終わる場合も、呼び出し元が与信を取り消す必要がある
+func (s *ProductService) createOrdersIn(ctx context.Context, store *repository.Store, userID model.UserID, items []model.RequestItem, opts model.OrderOptions, authorizationID *string) ([]model.OrderID, error) {
+	var insertedOrderIDs []model.OrderID
+	err := store.ExecTx(ctx, func(txStore *repository.Store) error {
+		// デッドロックなどでトランザクションをやり直す場合は、前回の与信を取り消してから取り直す
+		if *authorizationID != "" {
+			s.voidPayment(ctx, *authorizationID)
+			*authorizationID = ""
+		}
+		p, err := s.prepareOrders(ctx, txStore, userID, items, opts)
+		*authorizationID = p.authorizationID
+		if err != nil || len(p.orders) == 0 {
+			return err
 		}
 
 		// バルクINSERTで一括作成
-		orderIDs, err := txStore.OrderRepo.BulkCreate(ctx, ordersToInsert)
+		orderIDs, err := txStore.OrderRepo.BulkCreate(ctx, p.orders)
 		if err != nil {
 			return err
 		}
 		insertedOrderIDs = orderIDs
-		return nil
+		return s.completeOrders(ctx, txStore, p, orderIDs)
 	})
-
 	if err != nil {
 		return nil, err
 	}
-	log.Printf("Created %d orders for user %d", len(insertedOrderIDs), userID)
+	logging.FromContext(ctx).Info("Created orders", "op", "CreateOrders", "orders", len(insertedOrderIDs))
 	return insertedOrderIDs, nil
 }
 
-func (s *ProductService) FetchProducts(ctx context.Context, userID int, req model.ListRequest) ([]model.Product, int, error) {
+// INSERT する直前まで組み立てた1回分の注文
+type preparedOrders struct {
+	userID          model.UserID
+	orders          []model.Order
+	amount          int
+	authorizationID string
+	paymentID       int64
+}
+
+// 在庫の引き当て・クーポンの適用・決済の与信を行い、INSERT する注文を組み立てる
+// 与信を取った後に失敗した場合も、取り消せるよう authorizationID を入れて返す
+func (s *ProductService) prepareOrders(ctx context.Context, txStore *repository.Store, userID model.UserID, items []model.RequestItem, opts model.OrderOptions) (preparedOrders, error) {
+	p := preparedOrders{userID: userID}
+	couponCode := strings.ToUpper(opts.CouponCode)
+
+	addressID, err := resolveAddress(ctx, txStore, userID, opts.AddressID)
+	if err != nil {
+		return p, err
+	}
+	// 注文リストを構築
+	warehouses, err := s.assignWarehouses(ctx, txStore, items)
+	if err != nil {
+		return p, err
+	}
+	var ordersToInsert []model.Order
+	for i, item := range items {
+		for j := 0; j < item.Quantity; j++ {
+			ordersToInsert = append(ordersToInsert, model.Order{
+				UserID:      userID,
+				ProductID:   item.ProductID,
+				WarehouseID: warehouses[i],
+				Note:        opts.Note,
+				AddressID:   addressID,
+			})
+		}
+	}
+
+	if len(ordersToInsert) == 0 {
+		return p, nil
+	}
+
+	attrs, err := productAttributes(ctx, txStore, items)
+	if err != nil {
+		return p, err
+	}
+	// 後から商品が変更されても計画や履歴が変わらないよう、注文時点の重さと価値を注文に写す
+	amount := 0
+	for i, o := range ordersToInsert {
+		ordersToInsert[i].Weight = attrs[o.ProductID].Weight
+		ordersToInsert[i].Value = attrs[o.ProductID].Value
+		amount += attrs[o.ProductID].Value
+	}
+	discount := 0
+	if couponCode != "" {
+		if discount, err = applyCoupon(ctx, txStore, couponCode, userID, amount); err != nil {
+			return p, err
+		}
+		orderPrices := make([]int, len(ordersToInsert))
+		for i, o := range ordersToInsert {
+			orderPrices[i] = o.Value
+		}
+		for i, share := range allocateDiscount(orderPrices, discount) {
+			ordersToInsert[i].Discount = share
+			ordersToInsert[i].CouponCode = couponCode
+		}
+		amount -= discount
+	}
+
+	p.authorizationID, err = s.payments.Authorize(ctx, userID, amount)
+	if err != nil {
+		return p, fmt.Errorf("authorize payment: %w", err)
+	}
+	paymentID, err := txStore.PaymentRepo.Create(ctx, model.Payment{
+		UserID:          userID,
+		Provider:        s.payments.Name(),
+		AuthorizationID: p.authorizationID,
+		Amount:          amount,
+		Status:          model.PaymentAuthorized,
+	})
+	if err != nil {
+		return p, err
+	}
+	for i := range ordersToInsert {
+		ordersToInsert[i].PaymentID = paymentID
+	}
+	if couponCode != "" {
+		if err := txStore.CouponRepo.Redeem(ctx, couponCode, userID, paymentID, discount); err != nil {
+			return p, err
+		}
+	}
+	p.orders = ordersToInsert
+	p.amount = amount
+	p.paymentID = paymentID
+	return p, nil
+}
+
+// INSERT した注文の作成のイベントを発行し、コミット後に決済を確定するよう登録する
+// 確定は取り消せない外部の処理のため、トランザクションがロールバックされうる間は行わない
+func (s *ProductService) completeOrders(ctx context.Context, txStore *repository.Store, p preparedOrders, orderIDs []model.OrderID) error {
+	if err := s.events.Publish(ctx, txStore, event.OrdersCreated{
+		UserID:   p.userID,
+		OrderIDs: model.OrderIDStrings(orderIDs),
+	}); err != nil {
+		return err
+	}
+	txStore.AfterCommit(func() { s.capturePayment(ctx, p) })
+	return nil
+}
+
+// コミット済みの注文の決済を確定し、決済の記録を captured にする
+// 注文は作成済みのため、失敗しても取り消さずに与信のまま残し、ログから確定し直せるようにする
+func (s *ProductService) capturePayment(ctx context.Context, p preparedOrders) {
+	ctx = context.WithoutCancel(ctx)
+	log := logging.FromContext(ctx)
+	if err := s.payments.Capture(ctx, p.authorizationID, p.amount); err != nil {
+		log.Error("Failed to capture payment", "op", "CreateOrders", "payment_id", p.paymentID, "authorization_id", p.authorizationID, "error", err)
+		return
+	}
+	if err := s.store.PaymentRepo.UpdateStatus(ctx, p.paymentID, model.PaymentCaptured); err != nil {
+		log.Error("Failed to mark payment as captured", "op", "CreateOrders", "payment_id", p.paymentID, "error", err)
+	}
+}
+
+// 注文する商品の重さと価格 (商品IDごと)
+// キャッシュは商品の変更直後に古い値を返しうるため、トランザクション内でDBから読む
+func productAttributes(ctx context.Context, txStore *repository.Store, items []model.RequestItem) (map[model.ProductID]model.ProductAttributes, error) {
+	productIDs := make([]model.ProductID, 0, len(items))
+	for _, item := range items {
+		if item.Quantity > 0 {
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	products, err := txStore.ProductRepo.GetProductsByIDs(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	attrs := make(map[model.ProductID]model.ProductAttributes, len(products))
+	for _, p := range products {
+		attrs[p.ProductID] = model.ProductAttributes{Weight: p.Weight, Value: p.Value}
+	}
+	return attrs, nil
+}
+
+// 注文の作成に失敗した場合に与信を取り消す
+// 取り消しに失敗しても注文の作成エラーを優先して返すため、ログに残すだけにする
+func (s *ProductService) voidPayment(ctx context.Context, authorizationID string) {
+	ctx = context.WithoutCancel(ctx)
+	if err := s.payments.Void(ctx, authorizationID); err != nil {
+		logging.FromContext(ctx).Error("Failed to void payment", "op", "CreateOrders", "authorization_id", authorizationID, "error", err)
+	}
+}
+
+// 在庫を管理している商品で、明細の数量をまかなえる倉庫がない
+type OutOfStockError struct {
+	ProductIDs []model.ProductID
+}
+
+func (e *OutOfStockError) Error() string {
+	return fmt.Sprintf("out of stock: %v", e.ProductIDs)
+}
+
+// 注文の明細ごとに出荷元の倉庫を決め、在庫を引き当てる
+// 明細の数量を1つの倉庫でまかなえる場合は在庫の最も多い倉庫を選ぶ
+// 在庫を管理していない (在庫の行がない) 商品は既定の倉庫に割り当て、在庫の行があってもどの倉庫でも足りない場合は *OutOfStockError を返す
+func (s *ProductService) assignWarehouses(ctx context.Context, txStore *repository.Store, items []model.RequestItem) ([]int, error) {
+	productIDs := make([]model.ProductID, 0, len(items))
+	for _, item := range items {
+		if item.Quantity > 0 {
+			productIDs = append(productIDs, item.ProductID)
+		}
+	}
+	stocks, err := txStore.WarehouseRepo.LockStocks(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	available := make(map[model.ProductID][]model.ProductStock, len(stocks))
+	for _, st := range stocks {
+		available[st.ProductID] = append(available[st.ProductID], st)
+	}
+
+	warehouses := make([]int, len(items))
+	var outOfStock []model.ProductID
+	for i, item := range items {
+		warehouses[i] = model.DefaultWarehouseID
+		if item.Quantity <= 0 || len(available[item.ProductID]) == 0 {
+			continue
+		}
+		best := -1
+		for k, st := range available[item.ProductID] {
+			if st.Quantity >= item.Quantity && (best < 0 || st.Quantity > available[item.ProductID][best].Quantity) {
+				best = k
+			}
+		}
+		if best < 0 {
+			outOfStock = append(outOfStock, item.ProductID)
+			continue
+		}
+		st := &available[item.ProductID][best]
+		if err := txStore.WarehouseRepo.DecrementStock(ctx, st.ProductID, st.WarehouseID, item.Quantity); err != nil {
+			return nil, err
+		}
+		st.Quantity -= item.Quantity
+		warehouses[i] = st.WarehouseID
+	}
+	if len(outOfStock) > 0 {
+		return nil, &OutOfStockError{ProductIDs: outOfStock}
+	}
+	return warehouses, nil
+}
+
+func (s *ProductService) FetchProducts(ctx context.Context, userID model.UserID, req model.ListRequest) ([]model.Product, model.ListTotal, error) {
 	products, total, err := s.store.ProductRepo.ListProducts(ctx, userID, req)
 	return products, total, err
 }
+
+// 一覧用の商品情報 (画像と説明文を含まない) を取得する
+func (s *ProductService) FetchProductSummaries(ctx context.Context, req model.ListRequest) ([]model.ProductSummary, model.ListTotal, error) {
+	return s.store.ProductRepo.ListProductSummaries(ctx, req)
+}
+
+// 商品の詳細 (画像と説明文を含む) を取得する
+func (s *ProductService) GetProduct(ctx context.Context, productID model.ProductID) (model.Product, error) {
+	return s.store.ProductRepo.FindByID(ctx, productID)
+}
+
+// 商品IDを指定して商品を取得する。存在しないIDは結果に含まれず、順序は保証しない
+func (s *ProductService) GetProductsByIDs(ctx context.Context, productIDs []model.ProductID) ([]model.Product, error) {
+	return s.store.ProductRepo.GetProductsByIDs(ctx, productIDs)
+}
+
+// 商品IDを指定して、指定した順に商品を返す
+// 存在しないIDは結果に含まれず、重複したIDは最初の位置にだけ含まれる
+func (s *ProductService) GetProductsInOrder(ctx context.Context, productIDs []model.ProductID) ([]model.Product, error) {
+	products, err := s.store.ProductRepo.GetProductsByIDs(ctx, productIDs)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[model.ProductID]model.Product, len(products))
+	for _, p := range products {
+		byID[p.ProductID] = p
+	}
+	ordered := make([]model.Product, 0, len(products))
+	for _, id := range productIDs {
+		if p, ok := byID[id]; ok {
+			ordered = append(ordered, p)
+			delete(byID, id)
+		}
+	}
+	return ordered, nil
+}
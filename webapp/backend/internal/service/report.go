@@ -0,0 +1,80 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"backend/internal/event"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+// SLAレポートに含める注文数の上限
+const maxSLAReportOrders = 10_000
+
+type ReportService struct {
+	store *repository.Store
+	// 作成から配送完了までの目標時間
+	sla time.Duration
+}
+
+func NewReportService(store *repository.Store, sla time.Duration) *ReportService {
+	return &ReportService{store: store, sla: sla}
+}
+
+// 注文ステータスの変更を、同じトランザクション内で履歴に記録する
+func (s *ReportService) Subscribe(bus *event.Bus) {
+	bus.SubscribeTx(func(ctx context.Context, tx *repository.Store, e event.Event) error {
+		switch e := e.(type) {
+		case event.OrderStatusChanged:
+			return tx.OrderRepo.RecordStatusHistory(ctx, []model.OrderID{e.OrderID}, e.NewStatus)
+		case event.PlanGenerated:
+			return tx.OrderRepo.RecordStatusHistory(ctx, e.OrderIDs, "delivering")
+		}
+		return nil
+	}, event.TypeOrderStatusChanged, event.TypePlanGenerated)
+}
+
+// 直近 days 日に作成された注文のうち、SLAを超えたものを作成日とロボットごとにまとめる
+func (s *ReportService) SLAReport(ctx context.Context, days int) (model.SLAReport, error) {
+	now := time.Now().UTC()
+	from := now.Truncate(24*time.Hour).AddDate(0, 0, -(days - 1))
+	report := model.SLAReport{SLASeconds: int64(s.sla / time.Second), From: from, Groups: []model.SLAReportGroup{}}
+
+	var orders []model.DelayedOrder
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		orders, err = s.store.StatsRepo.DelayedOrders(ctx, from, s.sla, maxSLAReportOrders+1)
+		return err
+	})
+	if err != nil {
+		return model.SLAReport{}, err
+	}
+	if len(orders) > maxSLAReportOrders {
+		orders = orders[:maxSLAReportOrders]
+		report.Truncated = true
+	}
+
+	// 注文は作成日時順なので、日付ごとにロボットの出現順でまとめる
+	type groupKey struct {
+		date  string
+		robot string
+	}
+	index := make(map[groupKey]int)
+	for _, o := range orders {
+		key := groupKey{date: o.CreatedAt.UTC().Format(time.DateOnly)}
+		if o.RobotID != nil {
+			key.robot = *o.RobotID
+		}
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Groups)
+			index[key] = i
+			report.Groups = append(report.Groups, model.SLAReportGroup{Date: key.date, RobotID: o.RobotID})
+		}
+		report.Groups[i].Count++
+		report.Groups[i].Orders = append(report.Groups[i].Orders, o)
+	}
+	return report, nil
+}
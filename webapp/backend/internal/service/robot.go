@@ -1,50 +1,153 @@
 package service
 
 import (
+	"backend/internal/event"
+	"backend/internal/featureflag"
+	"backend/internal/logging"
 	"backend/internal/model"
 	"backend/internal/repository"
 	"backend/internal/service/utils"
 	"context"
-	"log"
+	"errors"
+	"fmt"
 	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/google/uuid"
+	"golang.org/x/sync/singleflight"
+)
+
+// 配送計画の解き方
+const (
+	// 表のサイズが MaxDPCells 以下ならDP、超えればGreedy
+	SolverAuto = "auto"
+	// 常にDP (表のサイズの上限がないため、注文数 × 積載量 に比例したメモリを使う)
+	SolverDP = "dp"
+	// 常にGreedy
+	SolverGreedy = "greedy"
 )
 
+// Greedyで価値/重さの比が同じ注文の並べ方
+const (
+	// 決めない (ソートの結果に任せる)
+	TieBreakNone = "none"
+	// 注文IDの小さい (古い) 注文を先にする
+	TieBreakOldest = "oldest"
+	// 軽い注文を先にする
+	TieBreakLighter = "lighter"
+)
+
+type PlannerConfig struct {
+	Solver string
+	// 注文数 × 積載量 がこれを超える場合、SolverAuto ではGreedyで解く
+	MaxDPCells     int64
+	GreedyTieBreak string
+}
+
 type RobotService struct {
-	store *repository.Store
+	store  *repository.Store
+	flags  *featureflag.Flags
+	events *event.Bus
+	// ロボットIDごとの担当倉庫のコード。登録のないロボットは全ての倉庫が対象
+	homeWarehouses map[string]string
+	// 同時に来た配送計画の作成で、配送待ちの注文の取得を1回にまとめる
+	shippingReads singleflight.Group
+	planner       PlannerConfig
+	// 配送できずに戻された回数がこれに達した注文は failed にする
+	maxDeliveryAttempts int
 }
 
-func NewRobotService(store *repository.Store) *RobotService {
-	return &RobotService{store: store}
+func NewRobotService(store *repository.Store, flags *featureflag.Flags, events *event.Bus, homeWarehouses map[string]string, planner PlannerConfig, maxDeliveryAttempts int) *RobotService {
+	return &RobotService{store: store, flags: flags, events: events, homeWarehouses: homeWarehouses, planner: planner, maxDeliveryAttempts: maxDeliveryAttempts}
 }
 
+// ロボットの担当倉庫のIDを返す (0: 全ての倉庫)
+func (s *RobotService) homeWarehouseID(ctx context.Context, robotID string) (int, error) {
+	code, ok := s.homeWarehouses[robotID]
+	if !ok {
+		return 0, nil
+	}
+	w, err := s.store.WarehouseRepo.FindByCode(ctx, code)
+	if err != nil {
+		return 0, fmt.Errorf("home warehouse %q of %s: %w", code, robotID, err)
+	}
+	return w.WarehouseID, nil
+}
+
+// 他の計画と注文を取り合った場合に、配送計画を作り直す回数の上限
+const maxPlanAttempts = 3
+
+var errPlanConflict = errors.New("orders claimed by another plan")
+
 // 注意：このメソッドは、現在、ordersテーブルのshipped_statusが"shipping"になっている注文"全件"を対象に配送計画を立てます。
 // 注文の取得件数を制限した場合、ペナルティの対象になります。
 func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string, capacity int) (*model.DeliveryPlan, error) {
 	var plan model.DeliveryPlan
 
 	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
-		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
-			orders, err := txStore.OrderRepo.GetShippingOrders(ctx)
+		warehouseID, err := s.homeWarehouseID(ctx, robotID)
+		if err != nil {
+			return err
+		}
+		planner := s.planner
+		// フラグが有効なロボットは設定によらずGreedyで解く
+		if s.flags.Enabled(ctx, featureflag.RobotPlanGreedy, robotID) {
+			planner.Solver = SolverGreedy
+		}
+		for attempt := 1; ; attempt++ {
+			// 同時に計画を作ったロボットと同じ注文を選んで取り合いに負けた場合は、
+			// 共有の取得結果は古い可能性があるため、作り直すときは自分で取得する
+			var orders []model.Order
+			if attempt == 1 {
+				orders, err = s.sharedShippingOrders(ctx, warehouseID)
+			} else {
+				orders, err = s.store.OrderRepo.GetShippingOrders(ctx, warehouseID)
+			}
 			if err != nil {
 				return err
 			}
-			plan, err = selectOrdersForDelivery(ctx, orders, robotID, capacity)
+			plan, err = selectOrdersForDelivery(ctx, orders, robotID, capacity, planner)
 			if err != nil {
 				return err
 			}
-			if len(plan.Orders) > 0 {
-				orderIDs := make([]int64, len(plan.Orders))
+			err = s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+				if len(plan.Orders) == 0 {
+					return nil
+				}
+				lost, err := claimPlan(ctx, txStore, &plan)
+				if err != nil {
+					return err
+				}
+				// 最後の試行では確保できた分だけで計画とする
+				if lost > 0 && attempt < maxPlanAttempts {
+					return errPlanConflict
+				}
+				if len(plan.Orders) == 0 {
+					return nil
+				}
+				orderIDs := make([]model.OrderID, len(plan.Orders))
 				for i, order := range plan.Orders {
 					orderIDs[i] = order.OrderID
 				}
-
-				if err := txStore.OrderRepo.UpdateStatusesChunked(ctx, orderIDs, "delivering"); err != nil {
+				if err := s.events.Publish(ctx, txStore, event.PlanGenerated{
+					RobotID:     robotID,
+					OrderIDs:    orderIDs,
+					TotalWeight: plan.TotalWeight,
+					TotalValue:  plan.TotalValue,
+				}); err != nil {
 					return err
 				}
-				log.Printf("Updated status to 'delivering' for %d orders", len(orderIDs))
+				logging.FromContext(ctx).Info("Updated status to 'delivering'",
+					"op", "GenerateDeliveryPlan", "orders", len(orderIDs), "attempt", attempt)
+				return nil
+			})
+			if !errors.Is(err, errPlanConflict) {
+				return err
 			}
-			return nil
-		})
+			logging.FromContext(ctx).Info("Retrying delivery plan after losing orders to another plan",
+				"op", "GenerateDeliveryPlan", "attempt", attempt)
+		}
 	})
 	if err != nil {
 		return nil, err
@@ -52,15 +155,108 @@ func (s *RobotService) GenerateDeliveryPlan(ctx context.Context, robotID string,
 	return &plan, nil
 }
 
-func (s *RobotService) UpdateOrderStatus(ctx context.Context, orderID int64, newStatus string) error {
+// 配送待ちの注文を取得する。同じ倉庫について取得中のものがあれば、その結果を共有する
+// 返したスライスは他の呼び出し元と共有しているため変更しないこと
+func (s *RobotService) sharedShippingOrders(ctx context.Context, warehouseID int) ([]model.Order, error) {
+	v, err, _ := s.shippingReads.Do(strconv.Itoa(warehouseID), func() (any, error) {
+		// 最初の呼び出し元がキャンセルされても、結果を待っている他の呼び出し元には影響させない
+		return s.store.OrderRepo.GetShippingOrders(context.WithoutCancel(ctx), warehouseID)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return v.([]model.Order), nil
+}
+
+// 計画の注文を1つのUPDATEで確保し、確保できた注文とその備考だけを読み戻す
+// 同時に作成された他の計画が先に確保した注文は計画から外して合計を計算し直し、外した件数を返す
+func claimPlan(ctx context.Context, txStore *repository.Store, plan *model.DeliveryPlan) (int, error) {
+	planID := uuid.NewString()
+	orderIDs := make([]model.OrderID, len(plan.Orders))
+	for i, order := range plan.Orders {
+		orderIDs[i] = order.OrderID
+	}
+	n, err := txStore.OrderRepo.ClaimForDelivery(ctx, orderIDs, plan.RobotID, planID)
+	if err != nil {
+		return 0, err
+	}
+	claimed, err := txStore.OrderRepo.FindClaimed(ctx, planID)
+	if err != nil {
+		return 0, err
+	}
+
+	kept := plan.Orders[:0]
+	plan.TotalWeight, plan.TotalValue = 0, 0
+	for _, order := range plan.Orders {
+		note, ok := claimed[order.OrderID]
+		if !ok {
+			continue
+		}
+		order.Note = note
+		kept = append(kept, order)
+		plan.TotalWeight += order.Weight
+		plan.TotalValue += order.Value
+	}
+	lost := len(plan.Orders) - len(kept)
+	if lost > 0 {
+		logging.FromContext(ctx).Warn("Some orders were claimed by another plan",
+			"op", "GenerateDeliveryPlan", "claimed", n, "lost", lost)
+	}
+	plan.Orders = kept
+	if len(kept) == 0 {
+		plan.Orders = nil
+	}
+	return lost, nil
+}
+
+// 1回のクエリで取得する配送待ちの注文の件数
+const shippingCandidatePageSize = 1000
+
+// 注文IDが afterID より大きい配送待ちの注文を、注文ID順にページごとに fn へ渡す
+// 全体を1つのスナップショットとしては読まないため、途中で状態が変わった注文は含まれないことがある
+// fn がエラーを返した場合はそこで止める
+func (s *RobotService) EachShippingCandidate(ctx context.Context, afterID model.OrderID, zone string, fn func([]model.ShippingCandidate) error) error {
+	for {
+		var page []model.ShippingCandidate
+		err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+			var err error
+			page, err = s.store.OrderRepo.ListShippingCandidates(ctx, afterID, zone, shippingCandidatePageSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+		if err := fn(page); err != nil {
+			return err
+		}
+		if len(page) < shippingCandidatePageSize {
+			return nil
+		}
+		afterID = page[len(page)-1].OrderID
+	}
+}
+
+func (s *RobotService) UpdateOrderStatus(ctx context.Context, orderID model.OrderID, newStatus string) error {
 	return utils.WithTimeout(ctx, func(ctx context.Context) error {
-		return s.store.OrderRepo.UpdateStatus(ctx, orderID, newStatus)
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			if err := txStore.OrderRepo.UpdateStatus(ctx, orderID, newStatus); err != nil {
+				return err
+			}
+			return s.events.Publish(ctx, txStore, event.OrderStatusChanged{
+				OrderID:   orderID,
+				NewStatus: newStatus,
+			})
+		})
 	})
 }
 
-func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID string, robotCapacity int) (model.DeliveryPlan, error) {
+// planner.Solver に従ってDPかGreedyで選ぶ
+func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID string, robotCapacity int, planner PlannerConfig) (model.DeliveryPlan, error) {
 	// Use dynamic programming 0/1 knapsack when feasible; fall back to greedy when
-	// n*capacity is too large to avoid excessive memory/time usage.
+	// n*capacity exceeds planner.MaxDPCells to avoid excessive memory/time usage.
 	n := len(orders)
 	if n == 0 || robotCapacity <= 0 {
 		return model.DeliveryPlan{RobotID: robotID, TotalWeight: 0, TotalValue: 0, Orders: nil}, nil
@@ -80,9 +276,15 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 	n = len(orders)
 
 	// If DP table would be too large, fallback to greedy heuristic
-	// 閾値を下げて高速なGreedyアルゴリズムを優先
-	const maxCells = 500_000 // threshold for n * capacity
-	if int64(n)*int64(robotCapacity) > maxCells {
+	useGreedy := false
+	switch planner.Solver {
+	case SolverGreedy:
+		useGreedy = true
+	case SolverDP:
+	default:
+		useGreedy = int64(n)*int64(robotCapacity) > planner.MaxDPCells
+	}
+	if useGreedy {
 		// Greedy by value/weight ratio
 		type itemWithRatio struct {
 			o     model.Order
@@ -96,9 +298,29 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 			}
 			items = append(items, itemWithRatio{o, r})
 		}
-		sort.Slice(items, func(i, j int) bool {
-			return items[i].ratio > items[j].ratio
-		})
+		switch planner.GreedyTieBreak {
+		case TieBreakOldest:
+			sort.Slice(items, func(i, j int) bool {
+				if items[i].ratio != items[j].ratio {
+					return items[i].ratio > items[j].ratio
+				}
+				return items[i].o.OrderID < items[j].o.OrderID
+			})
+		case TieBreakLighter:
+			sort.Slice(items, func(i, j int) bool {
+				if items[i].ratio != items[j].ratio {
+					return items[i].ratio > items[j].ratio
+				}
+				if items[i].o.Weight != items[j].o.Weight {
+					return items[i].o.Weight < items[j].o.Weight
+				}
+				return items[i].o.OrderID < items[j].o.OrderID
+			})
+		default:
+			sort.Slice(items, func(i, j int) bool {
+				return items[i].ratio > items[j].ratio
+			})
+		}
 		var bestSet []model.Order
 		capLeft := robotCapacity
 		totalValue := 0
@@ -125,11 +347,9 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 
 	// DP 0/1 knapsack
 	cap := robotCapacity
-	dp := make([]int, cap+1)
-	keep := make([][]bool, n)
-	for i := 0; i < n; i++ {
-		keep[i] = make([]bool, cap+1)
-	}
+	bufs := knapsackPool.Get().(*knapsackBuffers)
+	defer knapsackPool.Put(bufs)
+	dp, keep := bufs.reset(n, cap)
 
 	// iterate items
 	checkEvery := 4096
@@ -151,7 +371,7 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 			}
 			if dp[c-w]+v > dp[c] {
 				dp[c] = dp[c-w] + v
-				keep[i][c] = true
+				keep.set(i, c)
 			}
 		}
 	}
@@ -173,7 +393,7 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 		if c <= 0 {
 			break
 		}
-		if keep[i][c] {
+		if keep.get(i, c) {
 			bestSet = append(bestSet, orders[i])
 			c -= orders[i].Weight
 		}
@@ -199,3 +419,43 @@ func selectOrdersForDelivery(ctx context.Context, orders []model.Order, robotID
 
 	return model.DeliveryPlan{RobotID: robotID, TotalWeight: totalWeight, TotalValue: totalValue, Orders: bestSet}, nil
 }
+
+// DPの表の1行ごとに、各容量でその注文を選んだかを1ビットずつ持つ
+type keepBits struct {
+	words []uint64
+	// 1行あたりのワード数
+	stride int
+}
+
+func (k keepBits) set(i, c int) {
+	k.words[i*k.stride+c>>6] |= 1 << (c & 63)
+}
+
+func (k keepBits) get(i, c int) bool {
+	return k.words[i*k.stride+c>>6]&(1<<(c&63)) != 0
+}
+
+// 配送計画の作成ごとにDPの表を確保し直さないよう、呼び出しをまたいで使い回す
+type knapsackBuffers struct {
+	dp   []int
+	keep []uint64
+}
+
+var knapsackPool = sync.Pool{New: func() any { return new(knapsackBuffers) }}
+
+// n 件・容量 capacity 用にゼロクリアした表を返す
+func (b *knapsackBuffers) reset(n, capacity int) ([]int, keepBits) {
+	if cap(b.dp) < capacity+1 {
+		b.dp = make([]int, capacity+1)
+	}
+	b.dp = b.dp[:capacity+1]
+	clear(b.dp)
+
+	stride := (capacity + 64) / 64
+	if cap(b.keep) < n*stride {
+		b.keep = make([]uint64, n*stride)
+	}
+	b.keep = b.keep[:n*stride]
+	clear(b.keep)
+	return b.dp, keepBits{words: b.keep, stride: stride}
+}
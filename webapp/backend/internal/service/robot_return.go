@@ -0,0 +1,91 @@
+package service
+
+import (
+	"context"
+
+	"backend/internal/event"
+	"backend/internal/logging"
+	"backend/internal/metrics"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+// ロボットが配送できなかった注文を戻す
+// 配送の試行回数が maxDeliveryAttempts に達した注文は failed にし、それ以外は配送待ち(shipping)に戻して次の配送計画の対象にする
+// robotID のロボットが配送中でない注文は何もせずに Skipped に入れる。同じ注文が複数ある場合は最初の理由を使う
+func (s *RobotService) ReturnOrders(ctx context.Context, robotID string, items []model.ReturnOrderItem) (model.ReturnOrdersResult, error) {
+	reasons := make(map[model.OrderID]string, len(items))
+	orderIDs := make([]model.OrderID, 0, len(items))
+	for _, item := range items {
+		if _, ok := reasons[item.OrderID]; ok {
+			continue
+		}
+		reasons[item.OrderID] = item.Reason
+		orderIDs = append(orderIDs, item.OrderID)
+	}
+
+	var result model.ReturnOrdersResult
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.ExecTx(ctx, func(txStore *repository.Store) error {
+			ids, err := txStore.OrderRepo.LockDeliveringBy(ctx, orderIDs, robotID)
+			if err != nil {
+				return err
+			}
+			attempts, err := txStore.OrderRepo.CountReturns(ctx, ids)
+			if err != nil {
+				return err
+			}
+
+			locked := make(map[model.OrderID]bool, len(ids))
+			returns := make([]model.ReturnOrderItem, len(ids))
+			result = model.ReturnOrdersResult{Requeued: []model.OrderID{}, Failed: []model.OrderID{}, Skipped: []model.OrderID{}}
+			for i, id := range ids {
+				locked[id] = true
+				returns[i] = model.ReturnOrderItem{OrderID: id, Reason: reasons[id]}
+				// 今回の分を含めた試行回数
+				if attempts[id]+1 >= s.maxDeliveryAttempts {
+					result.Failed = append(result.Failed, id)
+				} else {
+					result.Requeued = append(result.Requeued, id)
+				}
+			}
+			for _, id := range orderIDs {
+				if !locked[id] {
+					result.Skipped = append(result.Skipped, id)
+				}
+			}
+
+			if err := txStore.OrderRepo.RecordReturns(ctx, robotID, returns); err != nil {
+				return err
+			}
+			for _, group := range []struct {
+				ids    []model.OrderID
+				status string
+			}{{result.Requeued, "shipping"}, {result.Failed, "failed"}} {
+				if err := txStore.OrderRepo.UpdateStatusesChunked(ctx, group.ids, group.status); err != nil {
+					return err
+				}
+				for _, id := range group.ids {
+					if err := s.events.Publish(ctx, txStore, event.OrderStatusChanged{OrderID: id, NewStatus: group.status}); err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return model.ReturnOrdersResult{}, err
+	}
+
+	for _, id := range result.Requeued {
+		metrics.OrderReturns.WithLabelValues(reasons[id], "requeued").Inc()
+	}
+	for _, id := range result.Failed {
+		metrics.OrderReturns.WithLabelValues(reasons[id], "failed").Inc()
+	}
+	logging.FromContext(ctx).Info("Returned undeliverable orders", "op", "ReturnOrders",
+		"requested", len(orderIDs), "requeued", len(result.Requeued), "failed", len(result.Failed), "skipped", len(result.Skipped))
+	return result, nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"regexp"
+	"time"
+
+	"backend/internal/event"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+var ErrInvalidLocation = errors.New("invalid location")
+
+var trackingTokenPattern = regexp.MustCompile(`^[0-9a-f]{32}$`)
+
+type TrackingService struct {
+	store *repository.Store
+	// 配送計画の1件あたりの所要時間の見積もり。計画内の順番に応じて到着予定時刻を決める
+	etaPerStop time.Duration
+}
+
+func NewTrackingService(store *repository.Store, etaPerStop time.Duration) *TrackingService {
+	return &TrackingService{store: store, etaPerStop: etaPerStop}
+}
+
+// 配送計画の作成時に、同じトランザクション内で注文ごとの追跡トークンを発行する
+func (s *TrackingService) Subscribe(bus *event.Bus) {
+	bus.SubscribeTx(func(ctx context.Context, tx *repository.Store, e event.Event) error {
+		plan, ok := e.(event.PlanGenerated)
+		if !ok {
+			return nil
+		}
+		now := time.Now().UTC()
+		trackings := make([]model.OrderTracking, len(plan.OrderIDs))
+		for i, orderID := range plan.OrderIDs {
+			token, err := newTrackingToken()
+			if err != nil {
+				return err
+			}
+			trackings[i] = model.OrderTracking{
+				OrderID:   orderID,
+				Token:     token,
+				RobotID:   plan.RobotID,
+				PlannedAt: now,
+				ETA:       now.Add(time.Duration(i+1) * s.etaPerStop),
+			}
+		}
+		return tx.TrackingRepo.Upsert(ctx, trackings)
+	}, event.TypePlanGenerated)
+}
+
+func newTrackingToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// ユーザー自身の注文の追跡トークン
+func (s *TrackingService) TokenForOrder(ctx context.Context, userID model.UserID, orderID model.OrderID) (string, error) {
+	var token string
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		token, err = s.store.TrackingRepo.FindTokenByOrder(ctx, userID, orderID)
+		return err
+	})
+	return token, err
+}
+
+// トークンから配送状況を取得する。トークンが不正な場合は repository.ErrNotFound
+func (s *TrackingService) Track(ctx context.Context, token string) (model.TrackingInfo, error) {
+	if !trackingTokenPattern.MatchString(token) {
+		return model.TrackingInfo{}, repository.ErrNotFound
+	}
+	var info model.TrackingInfo
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var tracking model.OrderTracking
+		var err error
+		info, tracking, err = s.store.TrackingRepo.FindByToken(ctx, token)
+		if err != nil {
+			return err
+		}
+		// 配送完了後や、配送できずに戻された後は到着予定と位置を返さない
+		if info.ShippedStatus != "delivering" {
+			return nil
+		}
+		info.ETA = &tracking.ETA
+		loc, err := s.store.TrackingRepo.FindLocation(ctx, tracking.RobotID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		info.Location = &loc
+		return nil
+	})
+	return info, err
+}
+
+// ロボットの現在位置を記録する
+func (s *TrackingService) ReportLocation(ctx context.Context, robotID string, latitude, longitude float64) error {
+	if latitude < -90 || latitude > 90 || longitude < -180 || longitude > 180 {
+		return ErrInvalidLocation
+	}
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.TrackingRepo.SaveLocation(ctx, model.RobotLocation{
+			RobotID:    robotID,
+			Latitude:   latitude,
+			Longitude:  longitude,
+			ReportedAt: time.Now().UTC(),
+		})
+	})
+}
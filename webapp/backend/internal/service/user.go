@@ -0,0 +1,95 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net/mail"
+	"time"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+	"backend/internal/service/utils"
+)
+
+var (
+	ErrInvalidTimezone = errors.New("invalid timezone")
+	ErrInvalidEmail    = errors.New("invalid email address")
+)
+
+type UserService struct {
+	store *repository.Store
+}
+
+func NewUserService(store *repository.Store) *UserService {
+	return &UserService{store: store}
+}
+
+func (s *UserService) Profile(ctx context.Context, userID model.UserID) (model.UserProfile, error) {
+	var profile model.UserProfile
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		user, err := s.store.UserRepo.FindByID(ctx, userID)
+		if err != nil {
+			return err
+		}
+		profile = model.UserProfile{UserID: user.UserID, UserName: user.UserName, Timezone: user.Timezone}
+		return nil
+	})
+	return profile, err
+}
+
+// ユーザーの表示用タイムゾーン
+// 設定値が読み込めない場合(tzdataから削除された名前など)はUTCを返す
+func (s *UserService) Location(ctx context.Context, userID model.UserID) (*time.Location, error) {
+	profile, err := s.Profile(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, err := loadLocation(profile.Timezone)
+	if err != nil {
+		logging.FromContext(ctx).Warn("Unknown timezone, falling back to UTC", "op", "UserService.Location", "timezone", profile.Timezone)
+		return time.UTC, nil
+	}
+	return loc, nil
+}
+
+func (s *UserService) UpdateTimezone(ctx context.Context, userID model.UserID, timezone string) error {
+	if _, err := loadLocation(timezone); err != nil {
+		return ErrInvalidTimezone
+	}
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.UserRepo.UpdateTimezone(ctx, userID, timezone)
+	})
+}
+
+// IANAのタイムゾーン名だけを受け付ける
+// "Local" はサーバーの設定に依存するため使わせない
+func loadLocation(name string) (*time.Location, error) {
+	if name == "" || name == "Local" || len(name) > 64 {
+		return nil, ErrInvalidTimezone
+	}
+	return time.LoadLocation(name)
+}
+
+func (s *UserService) NotificationPreferences(ctx context.Context, userID model.UserID) (model.NotificationPreferences, error) {
+	var prefs model.NotificationPreferences
+	err := utils.WithTimeout(ctx, func(ctx context.Context) error {
+		var err error
+		prefs, err = s.store.NotificationRepo.GetPreferences(ctx, userID)
+		return err
+	})
+	return prefs, err
+}
+
+// 通知設定を更新する。メールアドレスを空にすると通知しない
+func (s *UserService) UpdateNotificationPreferences(ctx context.Context, userID model.UserID, prefs model.NotificationPreferences) error {
+	if prefs.Email != "" {
+		addr, err := mail.ParseAddress(prefs.Email)
+		if err != nil || addr.Address != prefs.Email || len(prefs.Email) > 255 {
+			return ErrInvalidEmail
+		}
+	}
+	return utils.WithTimeout(ctx, func(ctx context.Context) error {
+		return s.store.NotificationRepo.SavePreferences(ctx, userID, prefs)
+	})
+}
@@ -2,8 +2,10 @@ package utils
 
 import (
 	"context"
-	"log"
+	"errors"
 	"time"
+
+	"backend/internal/logging"
 )
 
 var defaultTimeout = 120 * time.Second
@@ -27,7 +29,12 @@ func WithTimeout(parent context.Context, fn func(ctx context.Context) error) err
 	case err := <-done:
 		return err
 	case <-ctx.Done():
-		log.Printf("処理がタイムアウトしました (timeout=%s)", timeout)
+		// 呼び出し元のキャンセル (クライアントの切断など) はタイムアウトとして扱わない
+		if errors.Is(parent.Err(), context.Canceled) {
+			logging.FromContext(parent).Info("呼び出し元のキャンセルにより処理を中断しました")
+			return parent.Err()
+		}
+		logging.FromContext(parent).Warn("処理がタイムアウトしました", "timeout", timeout.String())
 		return ctx.Err()
 	}
 }
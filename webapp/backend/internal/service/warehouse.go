@@ -0,0 +1,55 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"regexp"
+
+	"backend/internal/logging"
+	"backend/internal/model"
+	"backend/internal/repository"
+)
+
+var ErrInvalidWarehouse = errors.New("invalid warehouse")
+
+var warehouseCodePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+
+type WarehouseService struct {
+	store *repository.Store
+}
+
+func NewWarehouseService(store *repository.Store) *WarehouseService {
+	return &WarehouseService{store: store}
+}
+
+func (s *WarehouseService) List(ctx context.Context) ([]model.Warehouse, error) {
+	return s.store.WarehouseRepo.List(ctx)
+}
+
+func (s *WarehouseService) Create(ctx context.Context, code, name string) (model.Warehouse, error) {
+	if !warehouseCodePattern.MatchString(code) || name == "" || len(name) > 255 {
+		return model.Warehouse{}, ErrInvalidWarehouse
+	}
+	w, err := s.store.WarehouseRepo.Create(ctx, code, name)
+	if err != nil {
+		return model.Warehouse{}, err
+	}
+	logging.FromContext(ctx).Info("Warehouse created", "op", "CreateWarehouse", "warehouse", code)
+	return w, nil
+}
+
+// 倉庫 code における商品の在庫数を設定する
+func (s *WarehouseService) SetStock(ctx context.Context, code string, productID model.ProductID, quantity int) error {
+	if quantity < 0 {
+		return ErrInvalidWarehouse
+	}
+	w, err := s.store.WarehouseRepo.FindByCode(ctx, code)
+	if err != nil {
+		return err
+	}
+	if err := s.store.WarehouseRepo.SetStock(ctx, productID, w.WarehouseID, quantity); err != nil {
+		return err
+	}
+	logging.FromContext(ctx).Info("Stock updated", "op", "SetStock", "warehouse", code, "product_id", productID, "quantity", quantity)
+	return nil
+}